@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// eventRegistry — события из ABI-файлов -abi и -abi-dir, сгруппированные по topic0.
+// Под одним topic0 может быть несколько событий с разным числом индексированных
+// параметров (например, ERC20 и ERC721 Transfer), их различает число топиков.
+type eventRegistry struct {
+	events map[common.Hash][]abi.Event
+	// sums — числовые параметры -event-sum по имени события.
+	sums map[string][]string
+}
+
+// loadEventRegistry разбирает ABI из sources: файлы и каталоги (все *.json в них).
+// Непустой names оставляет только перечисленные события (имя или полная сигнатура),
+// sums — параметры "Event.param", значения которых суммируются по событию.
+func loadEventRegistry(sources, names, sums []string) (*eventRegistry, error) {
+	var paths []string
+	for _, source := range sources {
+		info, err := os.Stat(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ABI: %w", err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, source)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(source, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ABI directory: %w", err)
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = false
+	}
+
+	registry := &eventRegistry{events: make(map[common.Hash][]abi.Event), sums: make(map[string][]string)}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ABI: %w", err)
+		}
+		parsed, err := abi.JSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ABI %s: %w", path, err)
+		}
+
+		for _, event := range parsed.Events {
+			if event.Anonymous {
+				continue
+			}
+			if len(wanted) > 0 {
+				_, byName := wanted[event.Name]
+				_, bySig := wanted[event.Sig]
+				if !byName && !bySig {
+					continue
+				}
+				wanted[event.Name], wanted[event.Sig] = true, true
+			}
+			registry.add(event)
+		}
+	}
+
+	for _, name := range names {
+		if !wanted[name] {
+			return nil, fmt.Errorf("event %q not found in the ABI files", name)
+		}
+	}
+	if len(registry.events) == 0 {
+		return nil, fmt.Errorf("no non-anonymous events found in %s", strings.Join(sources, ", "))
+	}
+
+	for _, sum := range sums {
+		if err := registry.addSum(sum); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// addSum проверяет "Event.param": событие известно и у каждого его варианта есть целочисленный параметр.
+func (r *eventRegistry) addSum(raw string) error {
+	name, param, ok := strings.Cut(raw, ".")
+	if !ok || name == "" || param == "" {
+		return fmt.Errorf("invalid -event-sum %q: expected Event.param", raw)
+	}
+
+	found := false
+	for _, events := range r.events {
+		for _, event := range events {
+			if event.Name != name {
+				continue
+			}
+			found = true
+			input, ok := eventInput(event, param)
+			if !ok {
+				return fmt.Errorf("invalid -event-sum %q: event %s has no parameter %s", raw, event.Sig, param)
+			}
+			if input.Type.T != abi.UintTy && input.Type.T != abi.IntTy {
+				return fmt.Errorf("invalid -event-sum %q: parameter %s is %s, not an integer", raw, param, input.Type.String())
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("invalid -event-sum %q: event %s not found in the ABI files", raw, name)
+	}
+
+	r.sums[name] = append(r.sums[name], param)
+	return nil
+}
+
+func eventInput(event abi.Event, name string) (abi.Argument, bool) {
+	for _, input := range event.Inputs {
+		if input.Name == name {
+			return input, true
+		}
+	}
+	return abi.Argument{}, false
+}
+
+func (r *eventRegistry) add(event abi.Event) {
+	for _, known := range r.events[event.ID] {
+		if indexedCount(known) == indexedCount(event) {
+			return
+		}
+	}
+	r.events[event.ID] = append(r.events[event.ID], event)
+}
+
+func indexedCount(event abi.Event) int {
+	n := 0
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			n++
+		}
+	}
+	return n
+}
+
+// Topics — фильтр для FilterLogs: любой из известных topic0.
+func (r *eventRegistry) Topics() [][]common.Hash {
+	ids := make([]common.Hash, 0, len(r.events))
+	for id := range r.events {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+	return [][]common.Hash{ids}
+}
+
+func (r *eventRegistry) Names() string {
+	var names []string
+	for _, events := range r.events {
+		for _, event := range events {
+			names = append(names, strings.TrimPrefix(event.String(), "event "))
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Match находит событие лога и возвращает его индексированные address-параметры в порядке объявления.
+func (r *eventRegistry) Match(vLog types.Log) (abi.Event, []common.Address, bool) {
+	if len(vLog.Topics) == 0 {
+		return abi.Event{}, nil, false
+	}
+
+	for _, event := range r.events[vLog.Topics[0]] {
+		if indexedCount(event) != len(vLog.Topics)-1 {
+			continue
+		}
+
+		var addresses []common.Address
+		topic := 1
+		for _, input := range event.Inputs {
+			if !input.Indexed {
+				continue
+			}
+			if input.Type.T == abi.AddressTy {
+				addresses = append(addresses, common.BytesToAddress(vLog.Topics[topic].Bytes()))
+			}
+			topic++
+		}
+		return event, addresses, true
+	}
+	return abi.Event{}, nil, false
+}
+
+// addEvent засчитывает событие каждому его индексированному адресу.
+func (c *transferCounter) addEvent(vLog types.Log) {
+	event, addresses, ok := c.opts.Events.Match(vLog)
+	if !ok {
+		return
+	}
+
+	c.countTransfer(vLog.BlockNumber)
+	c.countTokenEvent(vLog.Address)
+	if c.opts.TopEvents {
+		c.countEventStats(event, vLog, addresses)
+	}
+	for _, address := range addresses {
+		c.count(address, vLog, nil, nil, 1)
+	}
+}
+
+// parseList разбирает значения -abi, -events и -event-sum, разделённые запятыми.
+func parseList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
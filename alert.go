@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// parseAddressList разбирает список адресов через запятую.
+func parseAddressList(raw string) ([]common.Address, error) {
+	var addresses []common.Address
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !common.IsHexAddress(item) {
+			return nil, fmt.Errorf("invalid address %q", item)
+		}
+		addresses = append(addresses, common.HexToAddress(item))
+	}
+	return addresses, nil
+}
+
+type watchAlert struct {
+	Address   common.Address
+	Count     int
+	Threshold int
+}
+
+// alerter следит за адресами из -watchlist и срабатывает один раз на адрес.
+type alerter struct {
+	thresholds map[common.Address]int
+	fired      map[common.Address]bool
+}
+
+// newAlerter принимает записи вида "0xaddr" или "0xaddr=N"; без N используется общий порог.
+func newAlerter(watchlist string, defaultThreshold int) (*alerter, error) {
+	a := &alerter{
+		thresholds: make(map[common.Address]int),
+		fired:      make(map[common.Address]bool),
+	}
+
+	for _, item := range strings.Split(watchlist, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		threshold := defaultThreshold
+		rawAddress, rawThreshold, hasThreshold := strings.Cut(item, "=")
+		if hasThreshold {
+			parsed, err := strconv.Atoi(strings.TrimSpace(rawThreshold))
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid threshold in watchlist entry %q", item)
+			}
+			threshold = parsed
+		}
+		if threshold <= 0 {
+			return nil, fmt.Errorf("watchlist entry %q has no threshold: set -alert-threshold or use 0xaddr=N", item)
+		}
+
+		addresses, err := parseAddressList(rawAddress)
+		if err != nil {
+			return nil, err
+		}
+		if len(addresses) != 1 {
+			return nil, fmt.Errorf("invalid watchlist entry %q", item)
+		}
+		a.thresholds[addresses[0]] = threshold
+	}
+
+	return a, nil
+}
+
+func (a *alerter) Check(metrics []Metric) []watchAlert {
+	var alerts []watchAlert
+	for _, m := range metrics {
+		threshold, ok := a.thresholds[m.Address]
+		if !ok || a.fired[m.Address] || m.Count < threshold {
+			continue
+		}
+		a.fired[m.Address] = true
+		alerts = append(alerts, watchAlert{Address: m.Address, Count: m.Count, Threshold: threshold})
+	}
+	return alerts
+}
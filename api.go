@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"getBlock/metric"
+)
+
+// transferCounter — движок metric.Scanner в CLI: main считает диапазоны через Scanner.
+var _ metric.Counter = (*transferCounter)(nil)
+
+// CountTransfers считает ERC20 Transfer в блоках [from, to] тем же счётчиком, что и CLI
+// (отправитель и получатель, по одному разу за перевод), и возвращает несортированную карту.
+//
+// Порядок операций: FilterLogs по topic0 Transfer → разбор каждого лога → подсчёт.
+// Нулевой адрес (минты и сжигания) в карте остаётся; его отбрасывает уже сортировка
+// SortAddressesByCount, которую вызывает TopAddresses.
+func CountTransfers(ctx context.Context, client *ethclient.Client, from, to *big.Int) (map[common.Address]int, ScanStats, error) {
+	counter := newTransferCounter(client, scanOptions{
+		Direction:   directionBoth,
+		Standard:    standardERC20,
+		CountMode:   countModeSum,
+		TopTokensBy: tokensByAddresses,
+	})
+	return metric.NewScanner(client, metric.WithCounter(counter)).CountTransfers(ctx, from, to)
+}
+
+// TopAddresses — CountTransfers и сортировка по убыванию числа переводов.
+func TopAddresses(ctx context.Context, client *ethclient.Client, from, to *big.Int) ([]Metric, error) {
+	counts, _, err := CountTransfers(ctx, client, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return metric.SortAddressesByCount(counts)
+}
+
+// Counts — копия сырых счётчиков переводов по адресам, с нулевым адресом.
+func (c *transferCounter) Counts() map[common.Address]int {
+	counts := make(map[common.Address]int, len(c.counts))
+	for address, count := range c.counts {
+		counts[address] = count
+	}
+	return counts
+}
+
+// defaultStreamWindow — окно в блоках, которым CountRange идёт без -chunk-size: один запрос на весь
+// диапазон держал бы в памяти все его логи до подсчёта.
+const defaultStreamWindow = 2000
+
+// CountRange запрашивает логи блоков [from, to] (окнами -chunk-size или defaultStreamWindow, с делением
+// окон, которые упираются в лимит провайдера) и добавляет их в счётчик по мере получения.
+func (c *transferCounter) CountRange(ctx context.Context, client metric.Client, from, to *big.Int) error {
+	if from.Cmp(to) <= 0 {
+		c.progress = newScanProgress(from.Uint64(), to.Uint64())
+		defer func() { c.progress = nil }()
+	}
+	if c.opts.Store != nil && c.opts.ChunkSize > 0 {
+		return c.countCheckpointed(ctx, client, from, to)
+	}
+
+	c.SetRange(from.Uint64(), to.Uint64())
+	if err := c.streamLogs(ctx, client, from, to); err != nil {
+		return err
+	}
+	c.warnAdded()
+	return nil
+}
+
+// streamLogs разбирает [from, to] пачками по -workers окон -chunk-size (без него — окнами
+// defaultStreamWindow): пока одна пачка разбирается, следующая уже запрашивается, и в памяти
+// не больше трёх пачек логов (в разборе, в очереди и в запросе), каким бы длинным ни был диапазон.
+func (c *transferCounter) streamLogs(ctx context.Context, client metric.Client, from, to *big.Int) error {
+	type batch struct {
+		logs []types.Log
+		err  error
+	}
+	batches := make(chan batch, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	// Прежде чем вернуться, дожидаемся выхода загрузчика: он пишет в c.progress и c.audit.
+	defer func() {
+		cancel()
+		for range batches {
+		}
+	}()
+
+	size := new(big.Int).SetUint64(defaultStreamWindow)
+	if c.opts.ChunkSize > 0 {
+		size.SetUint64(c.opts.ChunkSize * uint64(c.fetchPool.concurrency))
+	}
+	go func() {
+		defer close(batches)
+		for start := new(big.Int).Set(from); start.Cmp(to) <= 0; {
+			end := new(big.Int).Sub(new(big.Int).Add(start, size), big.NewInt(1))
+			if end.Cmp(to) > 0 {
+				end = new(big.Int).Set(to)
+			}
+			logs, err := c.fetchLogs(ctx, client, start, end)
+			select {
+			case batches <- batch{logs, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+			start = new(big.Int).Add(end, big.NewInt(1))
+		}
+	}()
+
+	for b := range batches {
+		if b.err != nil {
+			return b.err
+		}
+		if err := c.addLogs(ctx, b.logs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddLogs подгружает данные обогащения для пачки логов и добавляет их по одному.
+func (c *transferCounter) AddLogs(ctx context.Context, logs []types.Log) error {
+	if err := c.addLogs(ctx, logs); err != nil {
+		return err
+	}
+	c.warnAdded()
+	return nil
+}
+
+func (c *transferCounter) addLogs(ctx context.Context, logs []types.Log) error {
+	if err := c.Prefetch(ctx, logs); err != nil {
+		return err
+	}
+
+	for _, vLog := range logs {
+		c.progress.Decoded()
+		if err := c.Add(ctx, vLog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// warnAdded печатает предупреждения по всем добавленным логам.
+func (c *transferCounter) warnAdded() {
+	c.warnFailedDecimals()
+	c.warnUnpackFailures()
+	if duplicates := c.stats.Snapshot().Duplicates; duplicates > 0 {
+		logger.Info("dropped duplicate logs", "count", duplicates)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCountTransfers(t *testing.T) {
+	logs := []types.Log{
+		transferLog(testAddress(1), testAddress(2), 5, 10, 0),
+		transferLog(testAddress(1), testAddress(3), 5, 11, 0),
+		transferLog(common.Address{}, testAddress(1), 5, 12, 0),
+	}
+	client := dialTestRPC(t, func(method string, _ []json.RawMessage) (any, error) {
+		if method != "eth_getLogs" {
+			return nil, fmt.Errorf("unexpected %s", method)
+		}
+		return logs, nil
+	})
+
+	counts, stats, err := CountTransfers(context.Background(), client, big.NewInt(10), big.NewInt(12))
+	if err != nil {
+		t.Fatalf("CountTransfers: %v", err)
+	}
+	want := map[common.Address]int{testAddress(1): 3, testAddress(2): 1, testAddress(3): 1, {}: 1}
+	if fmt.Sprint(counts) != fmt.Sprint(want) {
+		t.Errorf("counts = %v, want %v with the zero address kept", counts, want)
+	}
+	if stats.FromBlock != 10 || stats.ToBlock != 12 || stats.Logs != 3 || stats.Transfers != 3 {
+		t.Errorf("stats = %+v, want blocks 10-12 with 3 logs and 3 transfers", stats)
+	}
+
+	top, err := TopAddresses(context.Background(), client, big.NewInt(10), big.NewInt(12))
+	if err != nil {
+		t.Fatalf("TopAddresses: %v", err)
+	}
+	if len(top) != 3 || top[0].Address != testAddress(1) || top[0].Count != 3 {
+		t.Errorf("TopAddresses = %+v, want %s first and no zero address", top, testAddress(1).Hex())
+	}
+}
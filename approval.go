@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var approvalEventHash = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+
+// maxUint256 — «безлимитный» approve, который выдают большинство интерфейсов по умолчанию.
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+type ApprovalEvent struct {
+	Owner   common.Address
+	Spender common.Address
+	// Value — разрешённая сумма; nil, если data не 32 байта.
+	Value *big.Int
+}
+
+// DecodeApproval достаёт owner (topic1) и spender (topic2) из ERC20 Approval, value — из data.
+func DecodeApproval(vLog types.Log) (ApprovalEvent, error) {
+	if len(vLog.Topics) != 3 {
+		return ApprovalEvent{}, fmt.Errorf("log is not an ERC20 Approval: expected 3 topics, got %d", len(vLog.Topics))
+	}
+	if vLog.Topics[0] != approvalEventHash {
+		return ApprovalEvent{}, fmt.Errorf("log is not an ERC20 Approval: unexpected topic0 %s", vLog.Topics[0].Hex())
+	}
+
+	approval := ApprovalEvent{
+		Owner:   common.BytesToAddress(vLog.Topics[1].Bytes()),
+		Spender: common.BytesToAddress(vLog.Topics[2].Bytes()),
+	}
+	if len(vLog.Data) == 32 {
+		approval.Value = new(big.Int).SetBytes(vLog.Data)
+	}
+	return approval, nil
+}
+
+// addApproval считает выданные владельцем approve на адреса из -approvals-to.
+func (c *transferCounter) addApproval(vLog types.Log) {
+	approval, err := DecodeApproval(vLog)
+	if err != nil {
+		return
+	}
+
+	for _, spender := range c.opts.ApprovalSpenders {
+		if spender == approval.Spender {
+			c.countTransfer(vLog.BlockNumber)
+			c.countTokenEvent(vLog.Address)
+			c.count(approval.Owner, vLog, nil, nil, 1)
+			return
+		}
+	}
+}
+
+// SpenderMetric — сколько approve получил spender (роутер, бридж) и от скольких владельцев.
+type SpenderMetric struct {
+	Spender   common.Address
+	Approvals int
+	Unlimited int
+	Owners    int
+}
+
+type spenderStats struct {
+	approvals int
+	unlimited int
+	owners    map[common.Address]struct{}
+}
+
+// countSpender учитывает Approval для -top-spenders. Отзывы (value = 0) не считаются:
+// для мониторинга важны выданные разрешения.
+func (c *transferCounter) countSpender(vLog types.Log) {
+	approval, err := DecodeApproval(vLog)
+	if err != nil || approval.Value != nil && approval.Value.Sign() == 0 {
+		return
+	}
+
+	stats, ok := c.spenders[approval.Spender]
+	if !ok {
+		stats = &spenderStats{owners: make(map[common.Address]struct{})}
+		c.spenders[approval.Spender] = stats
+	}
+	stats.approvals++
+	if approval.Value != nil && approval.Value.Cmp(maxUint256) == 0 {
+		stats.unlimited++
+	}
+	stats.owners[approval.Owner] = struct{}{}
+}
+
+// SpenderMetrics — spender'ы по убыванию числа полученных approve, затем владельцев.
+func (c *transferCounter) SpenderMetrics() []SpenderMetric {
+	spenders := make([]SpenderMetric, 0, len(c.spenders))
+	for spender, stats := range c.spenders {
+		spenders = append(spenders, SpenderMetric{Spender: spender, Approvals: stats.approvals, Unlimited: stats.unlimited, Owners: len(stats.owners)})
+	}
+	sort.Slice(spenders, func(i, j int) bool {
+		if spenders[i].Approvals != spenders[j].Approvals {
+			return spenders[i].Approvals > spenders[j].Approvals
+		}
+		if spenders[i].Owners != spenders[j].Owners {
+			return spenders[i].Owners > spenders[j].Owners
+		}
+		return bytes.Compare(spenders[i].Spender.Bytes(), spenders[j].Spender.Bytes()) < 0
+	})
+	return spenders
+}
+
+func writeSpenderMetrics(w io.Writer, spenders []SpenderMetric, opts outputOptions) error {
+	if len(spenders) > topN {
+		spenders = spenders[:topN]
+	}
+
+	if opts.AddressesOnly {
+		for _, s := range spenders {
+			if _, err := fmt.Fprintln(w, s.Spender.Hex()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Spender", "Approvals", "Unlimited", "Unique owners"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, s := range spenders {
+			if err := writeMarkdownRow(w, []string{s.Spender.Hex(), fmt.Sprint(s.Approvals), fmt.Sprint(s.Unlimited), fmt.Sprint(s.Owners)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, s := range spenders {
+		if _, err := fmt.Fprintf(w, "spender %v received %v approvals (%v unlimited) from %v unique owners\n", s.Spender, s.Approvals, s.Unlimited, s.Owners); err != nil {
+			return err
+		}
+	}
+	return nil
+}
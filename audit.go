@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// auditWindow — один запрос FilterLogs в том виде, в каком он ушёл к ноде, и число логов в ответе.
+type auditWindow struct {
+	FromBlock string     `json:"from_block,omitempty"`
+	ToBlock   string     `json:"to_block,omitempty"`
+	BlockHash string     `json:"block_hash,omitempty"`
+	Addresses []string   `json:"addresses,omitempty"`
+	Topics    [][]string `json:"topics"`
+	Logs      int        `json:"logs"`
+}
+
+// auditRecord — содержимое файла -audit; по нему можно повторить скан и сверить прогоны.
+type auditRecord struct {
+	Provider string        `json:"provider,omitempty"`
+	Version  string        `json:"version"`
+	Windows  []auditWindow `json:"windows"`
+	Retries  int           `json:"retries"`
+	Stats    ScanStats     `json:"stats"`
+}
+
+type auditLog struct {
+	mu      sync.Mutex
+	windows []auditWindow
+}
+
+func (a *auditLog) Record(query ethereum.FilterQuery, logs int) {
+	window := auditWindow{Logs: logs, Topics: make([][]string, len(query.Topics))}
+	if query.FromBlock != nil {
+		window.FromBlock = query.FromBlock.String()
+	}
+	if query.ToBlock != nil {
+		window.ToBlock = query.ToBlock.String()
+	}
+	if query.BlockHash != nil {
+		window.BlockHash = query.BlockHash.Hex()
+	}
+	for _, address := range query.Addresses {
+		window.Addresses = append(window.Addresses, address.Hex())
+	}
+	for i, position := range query.Topics {
+		window.Topics[i] = hashStrings(position)
+	}
+
+	a.mu.Lock()
+	a.windows = append(a.windows, window)
+	a.mu.Unlock()
+}
+
+func hashStrings(hashes []common.Hash) []string {
+	out := make([]string, len(hashes))
+	for i, hash := range hashes {
+		out[i] = hash.Hex()
+	}
+	return out
+}
+
+// auditPath — файл рядом с -out или stderr, если вывод идёт в stdout.
+func auditPath(outPath string) string {
+	if outPath == "" {
+		return ""
+	}
+	return outPath + ".audit.json"
+}
+
+func (a *auditLog) Write(path, providerURL string, stats ScanStats) error {
+	a.mu.Lock()
+	record := auditRecord{
+		Version: versionString(),
+		Windows: append([]auditWindow{}, a.windows...),
+		Stats:   stats,
+	}
+	a.mu.Unlock()
+	if providerURL != "" {
+		record.Provider = redactURL(providerURL)
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err = os.Stderr.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
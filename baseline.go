@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// BaselineDiff — изменение одной строки рейтинга относительно сохранённого снимка.
+type BaselineDiff struct {
+	Label    string
+	Baseline int
+	Current  int
+	// New — строки нет в снимке; Dropped — строка была в снимке, но выпала из текущего топа.
+	New, Dropped bool
+}
+
+func (d BaselineDiff) Delta() int {
+	return d.Current - d.Baseline
+}
+
+// diffBaseline сравнивает показанный топ с сохранённым: новые, выпавшие и изменение count
+// для общих строк. Текущий count выпавших берётся из полного рейтинга, если адрес в нём есть.
+func diffBaseline(baseline, top, all []Metric) []BaselineDiff {
+	current := make(map[string]int, len(all))
+	for _, m := range all {
+		current[m.Label()] = m.Count
+	}
+	inTop := make(map[string]bool, len(top))
+	for _, m := range top {
+		inTop[m.Label()] = true
+	}
+
+	inBaseline := make(map[string]bool, len(baseline))
+	var diffs []BaselineDiff
+	for _, m := range baseline {
+		label := m.Label()
+		inBaseline[label] = true
+		diffs = append(diffs, BaselineDiff{Label: label, Baseline: m.Count, Current: current[label], Dropped: !inTop[label]})
+	}
+	for _, m := range top {
+		if label := m.Label(); !inBaseline[label] {
+			diffs = append(diffs, BaselineDiff{Label: label, Current: m.Count, New: true})
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return abs(diffs[i].Delta()) > abs(diffs[j].Delta())
+	})
+	return diffs
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func writeBaselineDiff(w io.Writer, diffs []BaselineDiff) error {
+	if _, err := fmt.Fprintln(w, "changes against baseline:"); err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		var line string
+		switch {
+		case d.New:
+			line = fmt.Sprintf("  new      %v: %d transfers", d.Label, d.Current)
+		case d.Dropped:
+			line = fmt.Sprintf("  dropped  %v: %d -> %d (%+d)", d.Label, d.Baseline, d.Current, d.Delta())
+		default:
+			line = fmt.Sprintf("  changed  %v: %d -> %d (%+d)", d.Label, d.Baseline, d.Current, d.Delta())
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
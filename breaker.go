@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается без обращения к RPC, пока предохранитель разомкнут.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// circuitBreaker — http.RoundTripper, который после threshold подряд идущих ошибок
+// перестаёт слать запросы на cooldown, а затем пропускает один пробный запрос.
+type circuitBreaker struct {
+	next      http.RoundTripper
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(next http.RoundTripper, threshold int, cooldown time.Duration) *circuitBreaker {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &circuitBreaker{next: next, threshold: threshold, cooldown: cooldown}
+}
+
+func (b *circuitBreaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+
+	resp, err := b.next.RoundTrip(req)
+	switch {
+	case err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+		b.release()
+	case err != nil:
+		b.record(false)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		b.record(false)
+	default:
+		b.record(true)
+	}
+	return resp, err
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return fmt.Errorf("%w after %d consecutive failures, retry after %s", ErrCircuitOpen, b.failures, b.openUntil.Format(time.RFC3339))
+	}
+
+	// Полуоткрытое состояние: пропускаем один пробный запрос.
+	b.probing = true
+	return nil
+}
+
+// Open сообщает, что предохранитель разомкнут и до конца cooldown запрос не пропустит.
+func (b *circuitBreaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures >= b.threshold && (time.Now().Before(b.openUntil) || b.probing)
+}
+
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc — http.RoundTripper из функции для тестов транспортов.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func statusResponse(code int) *http.Response {
+	return &http.Response{StatusCode: code, Status: http.StatusText(code), Body: io.NopCloser(strings.NewReader("{}")), Header: make(http.Header)}
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	var calls int
+	status := http.StatusServiceUnavailable
+	var fail error
+	breaker := newCircuitBreaker(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		if fail != nil {
+			return nil, fail
+		}
+		return statusResponse(status), nil
+	}), 2, time.Hour)
+	req, _ := http.NewRequest(http.MethodPost, "http://rpc.invalid", nil)
+
+	steps := []struct {
+		name     string
+		setup    func()
+		wantOpen bool
+		calls    int
+	}{
+		{"first failure keeps the circuit closed", nil, false, 1},
+		{"threshold reached", nil, false, 2},
+		{"open circuit does not call the endpoint", nil, true, 2},
+		{"half-open trial fails and reopens", func() { breaker.openUntil = time.Now() }, false, 3},
+		{"reopened", nil, true, 3},
+		{"half-open trial succeeds", func() { breaker.openUntil, status = time.Now(), http.StatusOK }, false, 4},
+		{"closed again", nil, false, 5},
+		{"canceled requests are not failures", func() { fail = context.Canceled }, false, 6},
+		{"one network error is below the threshold", func() { fail = errors.New("connection reset") }, false, 7},
+	}
+	for _, step := range steps {
+		if step.setup != nil {
+			step.setup()
+		}
+		_, err := breaker.RoundTrip(req)
+		if open := errors.Is(err, ErrCircuitOpen); open != step.wantOpen {
+			t.Fatalf("%s: err = %v, want open %v", step.name, err, step.wantOpen)
+		}
+		if calls != step.calls {
+			t.Fatalf("%s: endpoint called %d times, want %d", step.name, calls, step.calls)
+		}
+	}
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	release := make(chan struct{})
+	breaker := newCircuitBreaker(roundTripFunc(func(*http.Request) (*http.Response, error) {
+		<-release
+		return statusResponse(http.StatusOK), nil
+	}), 1, time.Hour)
+	breaker.failures, breaker.openUntil = 1, time.Now()
+	req, _ := http.NewRequest(http.MethodPost, "http://rpc.invalid", nil)
+
+	done := make(chan error)
+	go func() {
+		_, err := breaker.RoundTrip(req)
+		done <- err
+	}()
+	for {
+		breaker.mu.Lock()
+		probing := breaker.probing
+		breaker.mu.Unlock()
+		if probing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := breaker.RoundTrip(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request during the trial: err = %v, want ErrCircuitOpen", err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("trial request: %v", err)
+	}
+}
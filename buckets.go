@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	bucketBlock = "block"
+	bucketHour  = "hour"
+	bucketDay   = "day"
+)
+
+func validateBucket(bucket string) error {
+	switch bucket {
+	case "", bucketBlock, bucketHour, bucketDay:
+		return nil
+	}
+	return fmt.Errorf("invalid -bucket %q: expected block, hour or day", bucket)
+}
+
+// Bucket — активность за один блок, час или сутки (UTC): переводы и различные адреса.
+// Для -bucket block Start пустое.
+type Bucket struct {
+	Start     time.Time
+	FromBlock uint64
+	ToBlock   uint64
+	Transfers int
+	Addresses int
+}
+
+// countTransfer засчитывает перевод в статистику прогона и в блок для -bucket.
+func (c *transferCounter) countTransfer(block uint64) {
+	c.stats.IncTransfers()
+	if c.opts.Bucket != "" {
+		c.blockTransfers[block]++
+	}
+}
+
+func (c *transferCounter) trackActive(address common.Address, block uint64) {
+	if address == (common.Address{}) && !c.opts.CountZero {
+		return
+	}
+
+	active, ok := c.blockActive[block]
+	if !ok {
+		active = make(map[common.Address]struct{})
+		c.blockActive[block] = active
+	}
+	active[address] = struct{}{}
+}
+
+// Buckets сводит активность по блокам в окна -bucket. Для hour и day время блока берётся
+// из его заголовка, так что запрашиваются только блоки, в которых были переводы.
+func (c *transferCounter) Buckets(ctx context.Context) ([]Bucket, error) {
+	blocks := make([]uint64, 0, len(c.blockTransfers))
+	for block := range c.blockTransfers {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+
+	times := make([]time.Time, len(blocks))
+	if c.opts.Bucket != bucketBlock {
+		err := c.pool.Run(ctx, len(blocks), func(ctx context.Context, i int) error {
+			header, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(blocks[i]))
+			if err != nil {
+				return fmt.Errorf("failed to retrieve header of block %d: %w", blocks[i], err)
+			}
+			start := time.Unix(int64(header.Time), 0).UTC()
+			if c.opts.Bucket == bucketHour {
+				start = start.Truncate(time.Hour)
+			} else {
+				start = time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			}
+			times[i] = start
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buckets []Bucket
+	var active map[common.Address]struct{}
+	for i, block := range blocks {
+		if len(buckets) == 0 || c.opts.Bucket == bucketBlock || !times[i].Equal(buckets[len(buckets)-1].Start) {
+			buckets = append(buckets, Bucket{Start: times[i], FromBlock: block})
+			active = make(map[common.Address]struct{})
+		}
+		b := &buckets[len(buckets)-1]
+		b.ToBlock = block
+		b.Transfers += c.blockTransfers[block]
+		for address := range c.blockActive[block] {
+			active[address] = struct{}{}
+		}
+		b.Addresses = len(active)
+	}
+	return buckets, nil
+}
+
+func (b Bucket) label() string {
+	if b.Start.IsZero() {
+		return fmt.Sprintf("block %d", b.FromBlock)
+	}
+	return b.Start.Format(time.RFC3339)
+}
+
+// writeBuckets печатает ряд -bucket после рейтинга; в -format csv — отдельной таблицей после пустой строки.
+func writeBuckets(w io.Writer, buckets []Bucket, opts outputOptions) error {
+	switch opts.Format {
+	case formatCSV:
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"bucket", "from_block", "to_block", "transfers", "active_addresses"}); err != nil {
+			return err
+		}
+		for _, b := range buckets {
+			if err := cw.Write([]string{b.label(), fmt.Sprint(b.FromBlock), fmt.Sprint(b.ToBlock), fmt.Sprint(b.Transfers), fmt.Sprint(b.Addresses)}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case formatMarkdown:
+		if err := writeMarkdownRow(w, []string{"Bucket", "Blocks", "Transfers", "Active addresses"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, b := range buckets {
+			if err := writeMarkdownRow(w, []string{b.label(), fmt.Sprintf("%d-%d", b.FromBlock, b.ToBlock), fmt.Sprint(b.Transfers), fmt.Sprint(b.Addresses)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, b := range buckets {
+		span := ""
+		if !b.Start.IsZero() {
+			span = fmt.Sprintf(" (blocks %d-%d)", b.FromBlock, b.ToBlock)
+		}
+		if _, err := fmt.Fprintf(w, "%v%v: %v transfers, %v active addresses\n", b.label(), span, b.Transfers, b.Addresses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
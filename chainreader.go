@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// ChainReader — то немногое от узла, что нужно скану диапазона и -watch: заголовки, логи и подписка.
+// Его реализует *ethclient.Client, а для детерминированных прогонов без сети — fixtureChain.
+type ChainReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+}
+
+var _ ChainReader = (*ethclient.Client)(nil)
+
+const (
+	// fixtureGenesisTime и fixtureBlockTime задают время синтетических заголовков fixtureChain.
+	fixtureGenesisTime = 1700000000
+	fixtureBlockTime   = 12
+)
+
+// fixtureChain — ChainReader поверх заранее известных логов (например, из -logs-file). Логи блоков
+// до head отдаются FilterLogs, логи после head — новым подпискам, как будто эти блоки только что вышли.
+// Заголовки синтетические: номер и время head-блока определяются только номером, поэтому
+// одинаковые логи всегда дают одинаковые хеши и время.
+type fixtureChain struct {
+	head   uint64
+	logs   []types.Log
+	future []types.Log
+}
+
+// newFixtureChain делит logs по head; head 0 — последний блок среди логов. Логам без BlockHash
+// проставляется хеш синтетического заголовка, чтобы проверки реорганизации видели согласованную цепочку.
+func newFixtureChain(logs []types.Log, head uint64) *fixtureChain {
+	chain := &fixtureChain{head: head}
+	if head == 0 {
+		for _, vLog := range logs {
+			if vLog.BlockNumber > chain.head {
+				chain.head = vLog.BlockNumber
+			}
+		}
+	}
+
+	sorted := append([]types.Log(nil), logs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].BlockNumber != sorted[j].BlockNumber {
+			return sorted[i].BlockNumber < sorted[j].BlockNumber
+		}
+		return sorted[i].Index < sorted[j].Index
+	})
+	for _, vLog := range sorted {
+		if vLog.BlockHash == (common.Hash{}) {
+			vLog.BlockHash = fixtureHeader(vLog.BlockNumber).Hash()
+		}
+		if vLog.BlockNumber > chain.head {
+			chain.future = append(chain.future, vLog)
+		} else {
+			chain.logs = append(chain.logs, vLog)
+		}
+	}
+	return chain
+}
+
+func fixtureHeader(number uint64) *types.Header {
+	return &types.Header{
+		Number:     new(big.Int).SetUint64(number),
+		Time:       fixtureGenesisTime + number*fixtureBlockTime,
+		Difficulty: new(big.Int),
+	}
+}
+
+func (c *fixtureChain) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		return fixtureHeader(c.head), nil
+	}
+	if number.Sign() < 0 || !number.IsUint64() || number.Uint64() > c.head {
+		return nil, ethereum.NotFound
+	}
+	return fixtureHeader(number.Uint64()), nil
+}
+
+func (c *fixtureChain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	from, to := uint64(0), c.head
+	if query.FromBlock != nil {
+		from = query.FromBlock.Uint64()
+	}
+	if query.ToBlock != nil {
+		to = query.ToBlock.Uint64()
+	}
+
+	var matched []types.Log
+	for _, vLog := range c.logs {
+		if query.BlockHash != nil {
+			if vLog.BlockHash != *query.BlockHash {
+				continue
+			}
+		} else if vLog.BlockNumber < from || vLog.BlockNumber > to {
+			continue
+		}
+		if matchesQuery(vLog, query) {
+			matched = append(matched, vLog)
+		}
+	}
+	return matched, nil
+}
+
+// SubscribeFilterLogs отправляет подписчику логи после head и держит подписку открытой до Unsubscribe.
+func (c *fixtureChain) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		for _, vLog := range c.future {
+			if !matchesQuery(vLog, query) {
+				continue
+			}
+			select {
+			case ch <- vLog:
+			case <-quit:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		<-quit
+		return nil
+	}), nil
+}
+
+// matchesQuery применяет фильтры адресов и топиков так же, как eth_getLogs: пустая позиция — любой топик.
+func matchesQuery(vLog types.Log, query ethereum.FilterQuery) bool {
+	if len(query.Addresses) > 0 {
+		found := false
+		for _, address := range query.Addresses {
+			if vLog.Address == address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for i, alternatives := range query.Topics {
+		if len(alternatives) == 0 {
+			continue
+		}
+		if i >= len(vLog.Topics) {
+			return false
+		}
+		found := false
+		for _, topic := range alternatives {
+			if vLog.Topics[i] == topic {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
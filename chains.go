@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// chainInfo — сеть -chain: chain ID для проверки ноды и переменная окружения с ключом GetBlock.
+type chainInfo struct {
+	Name   string
+	ID     uint64
+	KeyEnv string
+}
+
+var knownChains = map[string]chainInfo{
+	"ethereum": {Name: "ethereum", ID: 1, KeyEnv: "ETH_API_KEY"},
+	"polygon":  {Name: "polygon", ID: 137, KeyEnv: "POLYGON_API_KEY"},
+	"bsc":      {Name: "bsc", ID: 56, KeyEnv: "BSC_API_KEY"},
+	"arbitrum": {Name: "arbitrum", ID: 42161, KeyEnv: "ARBITRUM_API_KEY"},
+	"base":     {Name: "base", ID: 8453, KeyEnv: "BASE_API_KEY"},
+}
+
+// chainConfig — сеть из секции chains конфига; дополняет или переопределяет встроенные.
+type chainConfig struct {
+	RPCURL  string `yaml:"rpc-url"`
+	WSURL   string `yaml:"ws-url"`
+	ChainID uint64 `yaml:"chain-id"`
+}
+
+// resolveChain находит сеть по имени и её RPC URL: из конфига или
+// https://go.getblock.io/$<KEY_ENV> для встроенных сетей.
+func resolveChain(name string, configured map[string]chainConfig) (chainInfo, chainConfig, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	chain, known := knownChains[name]
+	endpoint, fromConfig := configured[name]
+	if !known && !fromConfig {
+		names := make([]string, 0, len(knownChains)+len(configured))
+		for known := range knownChains {
+			names = append(names, known)
+		}
+		for configured := range configured {
+			if _, ok := knownChains[configured]; !ok {
+				names = append(names, configured)
+			}
+		}
+		sort.Strings(names)
+		return chainInfo{}, chainConfig{}, fmt.Errorf("unknown chain %q: expected one of %s or a chains entry in -config", name, strings.Join(names, ", "))
+	}
+
+	if !known && endpoint.ChainID == 0 {
+		return chainInfo{}, chainConfig{}, fmt.Errorf("chain %q from -config needs a chain-id", name)
+	}
+
+	chain.Name = name
+	if endpoint.ChainID != 0 {
+		chain.ID = endpoint.ChainID
+	}
+	if endpoint.RPCURL == "" && chain.KeyEnv != "" {
+		endpoint.RPCURL = fmt.Sprintf("https://go.getblock.io/%s", os.Getenv(chain.KeyEnv))
+	}
+	if endpoint.RPCURL == "" {
+		return chainInfo{}, chainConfig{}, fmt.Errorf("chain %q has no rpc-url in -config", name)
+	}
+	return chain, endpoint, nil
+}
+
+// checkChainID сверяет chain ID ноды с ожидаемым, чтобы не посчитать не ту сеть.
+func checkChainID(ctx context.Context, client *ethclient.Client, chain chainInfo) error {
+	if chain.ID == 0 {
+		return nil
+	}
+	id, err := client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the chain ID: %w", err)
+	}
+	if !id.IsUint64() || id.Uint64() != chain.ID {
+		return fmt.Errorf("RPC endpoint serves chain ID %v, but -chain %s expects %d", id, chain.Name, chain.ID)
+	}
+	return nil
+}
+
+// tagChain помечает строки рейтинга сетью -chain.
+func tagChain(metrics []Metric, chain string) {
+	for i := range metrics {
+		metrics[i].Chain = chain
+	}
+}
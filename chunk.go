@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+// tooManyResultsMarkers — фрагменты ошибок, которыми провайдеры отвечают на слишком
+// большой eth_getLogs (Infura, Alchemy, geth, Erigon и другие формулируют по-разному).
+var tooManyResultsMarkers = []string{
+	"query returned more than",
+	"more than 10000 results",
+	"log response size exceeded",
+	"response size exceeded",
+	"block range is too wide",
+	"block range too large",
+	"exceed maximum block range",
+	"too many logs",
+	"query timeout exceeded",
+}
+
+func isTooManyResults(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, marker := range tooManyResultsMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchLogs запрашивает логи [from, to] окнами по -chunk-size блоков (0 — одним запросом)
+// в -workers горутин. Окна собираются по порядку блоков, поэтому результат не зависит
+// от того, какой воркер ответил первым.
+func (c *transferCounter) fetchLogs(ctx context.Context, client metric.Client, from, to *big.Int) ([]types.Log, error) {
+	var windows [][2]*big.Int
+	for start := new(big.Int).Set(from); start.Cmp(to) <= 0; {
+		end := new(big.Int).Set(to)
+		if c.opts.ChunkSize > 0 {
+			if chunkEnd := new(big.Int).Add(start, new(big.Int).SetUint64(c.opts.ChunkSize-1)); chunkEnd.Cmp(to) < 0 {
+				end = chunkEnd
+			}
+		}
+		windows = append(windows, [2]*big.Int{start, end})
+		start = new(big.Int).Add(end, big.NewInt(1))
+	}
+
+	chunks := make([][]types.Log, len(windows))
+	err := c.fetchPool.Run(ctx, len(windows), func(ctx context.Context, i int) error {
+		chunk, err := c.fetchWindow(ctx, client, windows[i][0], windows[i][1])
+		if err != nil && c.opts.PartialOK && ctx.Err() == nil {
+			c.skipWindow(windows[i][0].Uint64(), windows[i][1].Uint64(), err)
+			chunk, err = nil, nil
+		}
+		chunks[i] = chunk
+		if err == nil {
+			c.progress.Fetched(new(big.Int).Sub(windows[i][1], windows[i][0]).Uint64()+1, len(chunk))
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mergeChunks(chunks), nil
+}
+
+// mergeChunks склеивает логи окон по порядку; срез выделяется один раз под все окна, иначе на
+// широком скане с тысячами логов в окне append многократно копирует уже собранное.
+func mergeChunks(chunks [][]types.Log) []types.Log {
+	total := 0
+	for _, chunk := range chunks {
+		total += len(chunk)
+	}
+	if len(chunks) == 1 {
+		return chunks[0]
+	}
+	logs := make([]types.Log, 0, total)
+	for _, chunk := range chunks {
+		logs = append(logs, chunk...)
+	}
+	return logs
+}
+
+func (c *transferCounter) skipWindow(from, to uint64, err error) {
+	c.failedMu.Lock()
+	defer c.failedMu.Unlock()
+	c.failed = append(c.failed, FailedRange{From: from, To: to, Err: err})
+}
+
+// failedRanges — пропущенные окна по порядку блоков.
+func (c *transferCounter) failedRanges() []FailedRange {
+	c.failedMu.Lock()
+	defer c.failedMu.Unlock()
+	failed := append([]FailedRange(nil), c.failed...)
+	sort.Slice(failed, func(i, j int) bool { return failed[i].From < failed[j].From })
+	return failed
+}
+
+// warnFailedRanges сообщает о пробелах скана -partial-ok: сколько окон и блоков пропущено и почему.
+func (c *transferCounter) warnFailedRanges() {
+	failed := c.failedRanges()
+	if len(failed) == 0 {
+		return
+	}
+	var blocks uint64
+	gaps := make([]string, len(failed))
+	for i, r := range failed {
+		blocks += r.To - r.From + 1
+		gaps[i] = fmt.Sprintf("%d-%d (%v)", r.From, r.To, r.Err)
+	}
+	logger.Warn("-partial-ok skipped block ranges that failed, the ranking misses their transfers",
+		"ranges", len(failed), "blocks", blocks, "failed", strings.Join(gaps, "; "))
+}
+
+// fetchWindow запрашивает одно окно. Окно, на которое провайдер ответил ошибкой о превышении
+// лимита, делится пополам, пока не станет одним блоком; ошибка для одного блока возвращается как есть.
+
+func (c *transferCounter) fetchWindow(ctx context.Context, client metric.Client, from, to *big.Int) ([]types.Log, error) {
+	query := ethereum.FilterQuery{
+		FromBlock: from,
+		ToBlock:   to,
+		Addresses: c.opts.Contracts,
+		Topics:    c.opts.transferTopics(),
+	}
+
+	requestCtx, cancel := withRequestTimeout(ctx, c.opts.RequestTimeout)
+	logs, err := client.FilterLogs(requestCtx, query)
+	cancel()
+	if err != nil && from.Cmp(to) < 0 && ctx.Err() == nil && !errors.Is(err, ErrCircuitOpen) && isTooManyResults(err) {
+		middle := new(big.Int).Rsh(new(big.Int).Add(from, to), 1)
+		logger.Info("blocks exceed the provider's log limit, splitting the window", "from", from, "to", to, "split_at", middle, "err", err)
+
+		// Половины идут через тот же лимит -fetch-rps, что и исходные окна.
+		if err := c.fetchPool.wait(ctx); err != nil {
+			return nil, err
+		}
+		left, err := c.fetchWindow(ctx, client, from, middle)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.fetchPool.wait(ctx); err != nil {
+			return nil, err
+		}
+		right, err := c.fetchWindow(ctx, client, new(big.Int).Add(middle, big.NewInt(1)), to)
+		if err != nil {
+			return nil, err
+		}
+		return append(left, right...), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter logs of blocks %v-%v: %w", from, to, err)
+	}
+	c.audit.Record(query, len(logs))
+
+	if c.opts.MaxLogs > 0 && len(logs) > c.opts.MaxLogs {
+		return nil, fmt.Errorf("blocks %v-%v returned %d logs, more than -max-logs %d: narrow the range or raise the limit",
+			from, to, len(logs), c.opts.MaxLogs)
+	}
+	return logs, nil
+}
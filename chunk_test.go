@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// windowChain записывает окна FilterLogs поверх fixtureChain; с maxWindow > 0 отвечает на более
+// широкие окна ошибкой лимита провайдера, на окна, начинающиеся с блока из failFrom, — ошибкой узла.
+// onWindow, если задан, вызывается перед ответом на каждое окно.
+type windowChain struct {
+	*fixtureChain
+	maxWindow uint64
+	failFrom  map[uint64]bool
+	onWindow  func(from uint64)
+
+	mu      sync.Mutex
+	windows [][2]uint64
+}
+
+func (c *windowChain) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := query.FromBlock.Uint64(), query.ToBlock.Uint64()
+	if c.maxWindow > 0 && to-from+1 > c.maxWindow {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	if c.failFrom[from] {
+		return nil, errors.New("internal error")
+	}
+	if c.onWindow != nil {
+		c.onWindow(from)
+	}
+	c.mu.Lock()
+	c.windows = append(c.windows, [2]uint64{from, to})
+	c.mu.Unlock()
+	return c.fixtureChain.FilterLogs(ctx, query)
+}
+
+// blockLogs — по переводу в каждом блоке 0..n-1 между разными адресами, чтобы потерянное или
+// повторённое окно меняло итог.
+func blockLogs(n int) []types.Log {
+	logs := make([]types.Log, 0, n)
+	for block := 0; block < n; block++ {
+		logs = append(logs, transferLog(testAddress(byte(block%7+1)), testAddress(byte(block%5+0x10)), 1, uint64(block), 0))
+	}
+	return logs
+}
+
+func countWindows(t *testing.T, chain *windowChain, chunkSize uint64, workers int, from, to int64) map[string]int {
+	t.Helper()
+	opts := testScanOptions()
+	opts.ChunkSize, opts.Workers = chunkSize, workers
+	counter := newTransferCounter(nil, opts)
+	if err := counter.CountRange(context.Background(), chain, big.NewInt(from), big.NewInt(to)); err != nil {
+		t.Fatalf("CountRange: %v", err)
+	}
+	counts := make(map[string]int)
+	for address, n := range counter.counts {
+		counts[address.Hex()] = n
+	}
+	return counts
+}
+
+func TestChunkBoundaries(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  int64
+		chunkSize uint64
+		workers   int
+		windows   [][2]uint64
+	}{
+		{"exact multiple", 1, 8, 4, 1, [][2]uint64{{1, 4}, {5, 8}}},
+		{"one more than a multiple", 1, 9, 4, 1, [][2]uint64{{1, 4}, {5, 8}, {9, 9}}},
+		{"smaller than a chunk", 1, 3, 4, 1, [][2]uint64{{1, 3}}},
+		{"single block", 5, 5, 4, 1, [][2]uint64{{5, 5}}},
+		{"chunk of one block", 3, 5, 1, 1, [][2]uint64{{3, 3}, {4, 4}, {5, 5}}},
+		{"parallel batches", 0, 19, 3, 2, [][2]uint64{{0, 2}, {3, 5}, {6, 8}, {9, 11}, {12, 14}, {15, 17}, {18, 19}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := blockLogs(20)
+			baseline := countWindows(t, &windowChain{fixtureChain: newFixtureChain(logs, 0)}, 0, 1, tt.from, tt.to)
+
+			chain := &windowChain{fixtureChain: newFixtureChain(logs, 0)}
+			got := countWindows(t, chain, tt.chunkSize, tt.workers, tt.from, tt.to)
+			if fmt.Sprint(got) != fmt.Sprint(baseline) {
+				t.Errorf("chunked counts %v differ from a single window %v", got, baseline)
+			}
+
+			sort.Slice(chain.windows, func(i, j int) bool { return chain.windows[i][0] < chain.windows[j][0] })
+			if fmt.Sprint(chain.windows) != fmt.Sprint(tt.windows) {
+				t.Errorf("windows %v, want %v", chain.windows, tt.windows)
+			}
+		})
+	}
+}
+
+func TestChunkSplitsOnProviderLimit(t *testing.T) {
+	logs := blockLogs(20)
+	baseline := countWindows(t, &windowChain{fixtureChain: newFixtureChain(logs, 0)}, 0, 1, 0, 19)
+
+	chain := &windowChain{fixtureChain: newFixtureChain(logs, 0), maxWindow: 3}
+	got := countWindows(t, chain, 10, 1, 0, 19)
+	if fmt.Sprint(got) != fmt.Sprint(baseline) {
+		t.Errorf("split counts %v differ from a single window %v", got, baseline)
+	}
+	var covered uint64
+	for _, w := range chain.windows {
+		if w[1]-w[0]+1 > 3 {
+			t.Errorf("window %v is wider than the provider allows", w)
+		}
+		covered += w[1] - w[0] + 1
+	}
+	if covered != 20 {
+		t.Errorf("windows cover %d blocks, want 20", covered)
+	}
+}
+
+func TestStreamsWithoutChunkSize(t *testing.T) {
+	const batches = 4
+	logs := blockLogs(batches * defaultStreamWindow)
+	counter := newTransferCounter(nil, testScanOptions())
+
+	// Четвёртое окно запрашивается, только когда вторая пачка ушла в разбор, то есть первая уже посчитана.
+	var counted int
+	chain := &windowChain{fixtureChain: newFixtureChain(logs, 0)}
+	chain.onWindow = func(from uint64) {
+		if from == (batches-1)*defaultStreamWindow {
+			counted = counter.stats.Snapshot().Logs
+		}
+	}
+	if err := counter.CountRange(context.Background(), chain, big.NewInt(0), big.NewInt(int64(len(logs)-1))); err != nil {
+		t.Fatalf("CountRange: %v", err)
+	}
+
+	if len(chain.windows) != batches {
+		t.Fatalf("got windows %v, want %d of %d blocks", chain.windows, batches, defaultStreamWindow)
+	}
+	for i, w := range chain.windows {
+		if want := [2]uint64{uint64(i * defaultStreamWindow), uint64((i+1)*defaultStreamWindow - 1)}; w != want {
+			t.Errorf("window %d is %v, want %v", i, w, want)
+		}
+	}
+	if counted < defaultStreamWindow {
+		t.Errorf("%d logs counted before the last window was requested, want the first batch of %d", counted, defaultStreamWindow)
+	}
+	if got := counter.stats.Snapshot().Logs; got != len(logs) {
+		t.Errorf("counted %d logs, want %d", got, len(logs))
+	}
+}
+
+func BenchmarkMergeChunks(b *testing.B) {
+	for _, size := range []struct{ windows, logs int }{{10, 1000}, {100, 1000}, {1000, 100}} {
+		chunks := make([][]types.Log, size.windows)
+		for i := range chunks {
+			chunks[i] = make([]types.Log, size.logs)
+		}
+		b.Run(fmt.Sprintf("%dx%d", size.windows, size.logs), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				mergeChunks(chunks)
+			}
+		})
+	}
+}
+
+func TestPartialOK(t *testing.T) {
+	logs := blockLogs(12)
+	newChain := func() *windowChain {
+		return &windowChain{fixtureChain: newFixtureChain(logs, 0), failFrom: map[uint64]bool{4: true, 8: true}}
+	}
+	scan := func(partialOK bool) (*transferCounter, error) {
+		opts := testScanOptions()
+		opts.ChunkSize, opts.Workers, opts.PartialOK = 4, 2, partialOK
+		counter := newTransferCounter(nil, opts)
+		return counter, counter.CountRange(context.Background(), newChain(), big.NewInt(0), big.NewInt(11))
+	}
+
+	if _, err := scan(false); err == nil {
+		t.Fatal("without -partial-ok a failed window must stop the scan")
+	}
+
+	counter, err := scan(true)
+	if err != nil {
+		t.Fatalf("CountRange with -partial-ok: %v", err)
+	}
+	stats := counter.Stats()
+	if len(stats.FailedRanges) != 2 {
+		t.Fatalf("FailedRanges = %+v, want the windows 4-7 and 8-11", stats.FailedRanges)
+	}
+	for i, want := range [][2]uint64{{4, 7}, {8, 11}} {
+		if got := stats.FailedRanges[i]; got.From != want[0] || got.To != want[1] || got.Err == nil {
+			t.Errorf("FailedRanges[%d] = %+v, want %d-%d with the node error", i, got, want[0], want[1])
+		}
+	}
+
+	// Рейтинг — ровно переводы уцелевшего окна 0-3.
+	want := countWindows(t, &windowChain{fixtureChain: newFixtureChain(logs, 0)}, 0, 1, 0, 3)
+	got := make(map[string]int)
+	for address, n := range counter.counts {
+		got[address.Hex()] = n
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("counts = %v, want the blocks 0-3 only: %v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// codeCache запоминает, есть ли у адреса код (контракт) или нет (EOA), — один eth_getCode на адрес.
+type codeCache struct {
+	client *ethclient.Client
+	stats  *Stats
+
+	mu        sync.Mutex
+	contracts map[common.Address]bool
+}
+
+func newCodeCache(client *ethclient.Client, stats *Stats) *codeCache {
+	return &codeCache{
+		client:    client,
+		stats:     stats,
+		contracts: make(map[common.Address]bool),
+	}
+}
+
+func (c *codeCache) IsContract(ctx context.Context, address common.Address) (bool, error) {
+	c.mu.Lock()
+	contract, ok := c.contracts[address]
+	c.mu.Unlock()
+	if ok {
+		c.stats.IncCacheHit()
+		return contract, nil
+	}
+
+	code, err := c.client.CodeAt(ctx, address, nil)
+	if err != nil {
+		c.stats.IncRPCError()
+		return false, fmt.Errorf("failed to fetch code of %s: %w", address.Hex(), err)
+	}
+	contract = len(code) > 0
+
+	c.mu.Lock()
+	c.contracts[address] = contract
+	c.mu.Unlock()
+	return contract, nil
+}
+
+// filterByCode оставляет только контракты (wantContracts) или только EOA, сохраняя порядок рейтинга.
+// Нулевой адрес (-count-zero) кода не имеет и считается EOA.
+func filterByCode(ctx context.Context, pool *enrichPool, codes *codeCache, metrics []Metric, wantContracts bool) ([]Metric, error) {
+	contract := make([]bool, len(metrics))
+	err := pool.Run(ctx, len(metrics), func(ctx context.Context, i int) error {
+		var err error
+		contract[i], err = codes.IsContract(ctx, metrics[i].Address)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]Metric, 0, len(metrics))
+	for i, m := range metrics {
+		if contract[i] == wantContracts {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered, nil
+}
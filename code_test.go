@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestFilterByCode(t *testing.T) {
+	contracts := map[common.Address]bool{testAddress(2): true, testAddress(4): true}
+	var requests atomic.Int64
+	client := dialTestRPC(t, func(method string, params []json.RawMessage) (any, error) {
+		if method != "eth_getCode" {
+			return nil, errors.New("unexpected method " + method)
+		}
+		requests.Add(1)
+		var address common.Address
+		json.Unmarshal(params[0], &address)
+		if contracts[address] {
+			return "0x6080604052", nil
+		}
+		return "0x", nil
+	})
+	stats := new(Stats)
+	codes := newCodeCache(client, stats)
+	pool := newEnrichPool(4, 0)
+	metrics := []Metric{{Address: testAddress(1)}, {Address: testAddress(2)}, {Address: testAddress(3)}, {Address: testAddress(4)}}
+
+	tests := []struct {
+		name          string
+		wantContracts bool
+		want          []common.Address
+	}{
+		{"-contracts-only", true, []common.Address{testAddress(2), testAddress(4)}},
+		{"-eoa-only", false, []common.Address{testAddress(1), testAddress(3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := filterByCode(context.Background(), pool, codes, metrics, tt.wantContracts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(filtered) != len(tt.want) {
+				t.Fatalf("kept %d addresses, want %d", len(filtered), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if filtered[i].Address != want {
+					t.Errorf("row %d is %s, want %s", i+1, filtered[i].Address.Hex(), want.Hex())
+				}
+			}
+		})
+	}
+	// Второй фильтр берёт код из того же кэша.
+	if requests.Load() != int64(len(metrics)) {
+		t.Errorf("%d eth_getCode requests for %d addresses", requests.Load(), len(metrics))
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command — подкоманда CLI. Флаги по-прежнему объявляются в main на flag.CommandLine, а набор
+// подкоманды получает их копии с общими значениями: без подкоманды работает прежний плоский набор.
+type command struct {
+	name    string
+	summary string
+	// finish проставляет флаги, которые подразумевает подкоманда, и проверяет обязательные;
+	// вызывается после окружения и конфига, чтобы видеть их значения.
+	finish func(fs *flag.FlagSet) error
+}
+
+var commands = []command{
+	{
+		name:    "scan",
+		summary: "rank the addresses of one block range and exit (the default without a command)",
+		finish:  func(*flag.FlagSet) error { return nil },
+	},
+	{
+		name:    "follow",
+		summary: "scan, then keep the ranking up to date from new blocks: a log subscription (-ws-url), or scheduled scans with -interval and -store",
+		finish: func(fs *flag.FlagSet) error {
+			if flagValue(fs, "interval") != "0s" {
+				return nil
+			}
+			return fs.Set("watch", "true")
+		},
+	},
+	{
+		name:    "serve",
+		summary: "serve the ranking over HTTP: the REST API (-serve-api, optionally with -grpc) or Prometheus metrics (-serve-metrics)",
+		finish: func(fs *flag.FlagSet) error {
+			if flagValue(fs, "serve-api") == "" && flagValue(fs, "serve-metrics") == "" {
+				return errors.New("serve needs -serve-api or -serve-metrics")
+			}
+			return nil
+		},
+	},
+	{
+		name:    "export",
+		summary: "scan and write every counted transfer to -parquet-dir, -follow-logs, -nats-url or -kafka-brokers besides the ranking",
+		finish: func(fs *flag.FlagSet) error {
+			if flagValue(fs, "parquet-dir") == "" && flagValue(fs, "follow-logs") == "" && flagValue(fs, "nats-url") == "" && flagValue(fs, "kafka-brokers") == "" {
+				return errors.New("export needs -parquet-dir, -follow-logs, -nats-url or -kafka-brokers")
+			}
+			return nil
+		},
+	},
+	{
+		name:    "snapshot",
+		summary: "scan, then rank the holders of each token by balanceOf at the end block through Multicall3",
+		finish:  func(fs *flag.FlagSet) error { return fs.Set("snapshot", "true") },
+	},
+	{
+		name:    "compare",
+		summary: "scan two block ranges and compare them: the range right before the scanned one, or -compare-from/-compare-to",
+		finish:  func(fs *flag.FlagSet) error { return fs.Set("compare", "true") },
+	},
+}
+
+// commandOnlyFlags — флаги, которые есть только у перечисленных подкоманд; остальные флаги
+// (диапазон, RPC, фильтры, вывод) общие для всех.
+var commandOnlyFlags = map[string][]string{
+	"watch":           {"follow"},
+	"follow":          {"follow"},
+	"ws-url":          {"follow"},
+	"watch-every":     {"follow"},
+	"tui":             {"follow"},
+	"watchlist-file":  {"follow"},
+	"notify-webhook":  {"follow"},
+	"telegram-chat":   {"follow"},
+	"alert-exit":      {"follow"},
+	"interval":        {"follow", "serve"},
+	"serve-metrics":   {"follow", "serve"},
+	"serve-api":       {"serve"},
+	"grpc":            {"serve"},
+	"grpc-cert":       {"serve"},
+	"grpc-key":        {"serve"},
+	"follow-logs":     {"export", "follow"},
+	"log-rotate-size": {"export", "follow"},
+	"nats-url":        {"export", "follow"},
+	"nats-subject":    {"export", "follow"},
+	"kafka-brokers":   {"export", "follow"},
+	"kafka-topic":     {"export", "follow"},
+	"parquet-dir":     {"export"},
+	"parquet-rows":    {"export"},
+	"snapshot":        {"snapshot"},
+	"multicall":       {"snapshot"},
+	"snapshot-batch":  {"snapshot"},
+	"compare":         {"compare"},
+	"compare-from":    {"compare"},
+	"compare-to":      {"compare"},
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func commandNames() string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// commandFlagSet собирает набор флагов подкоманды из flag.CommandLine: flag.Value общие, поэтому
+// разбор набора заполняет те же переменные, что объявил main.
+func commandFlagSet(c command) *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	flag.VisitAll(func(f *flag.Flag) {
+		if only, ok := commandOnlyFlags[f.Name]; ok && !containsString(only, c.name) {
+			return
+		}
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "usage: %s %s [flags]\n\n%s\n\nflags:\n", programName(), c.name, c.summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func flagValue(fs *flag.FlagSet, name string) string {
+	if f := fs.Lookup(name); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+func programName() string {
+	name := os.Args[0]
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// parseCommandLine разбирает аргументы: первым может идти подкоманда (или help [подкоманда]),
+// без неё флаги разбираются плоским flag.CommandLine, как раньше. Возвращает разобранный набор
+// и подкоманду; у плоского набора она пустая. После вывода справки возвращает flag.ErrHelp.
+func parseCommandLine(args []string) (*flag.FlagSet, command, error) {
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "usage: %s [command] [flags]\n\ncommands:\n", programName())
+		for _, c := range commands {
+			fmt.Fprintf(out, "  %-8s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(out, "\nRun %s help COMMAND for the flags of a command. Without a command every flag is accepted:\n", programName())
+		flag.PrintDefaults()
+	}
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		flag.CommandLine.Parse(args)
+		if flag.NArg() > 0 {
+			return nil, command{}, fmt.Errorf("unexpected argument %q: a command goes before the flags", flag.Arg(0))
+		}
+		return flag.CommandLine, command{}, nil
+	}
+
+	name := args[0]
+	if name == "help" {
+		if len(args) < 2 {
+			flag.CommandLine.SetOutput(os.Stdout)
+			flag.Usage()
+			return nil, command{}, flag.ErrHelp
+		}
+		c, ok := lookupCommand(args[1])
+		if !ok {
+			return nil, command{}, fmt.Errorf("unknown command %q: use %s", args[1], commandNames())
+		}
+		fs := commandFlagSet(c)
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
+		return nil, command{}, flag.ErrHelp
+	}
+	c, ok := lookupCommand(name)
+	if !ok {
+		return nil, command{}, fmt.Errorf("unknown command %q: use %s", name, commandNames())
+	}
+	fs := commandFlagSet(c)
+	fs.Parse(args[1:])
+	if fs.NArg() > 0 {
+		return nil, command{}, fmt.Errorf("unexpected argument %q after %s flags", fs.Arg(0), c.name)
+	}
+	return fs, c, nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"getBlock/metric"
+)
+
+// rangeDelta — изменение числа переводов адреса (или токена) между диапазоном -compare и основным.
+type rangeDelta struct {
+	Label         string
+	Before, After int
+}
+
+func (d rangeDelta) Delta() int {
+	return d.After - d.Before
+}
+
+// Percent — изменение в процентах; у строки, которой не было в диапазоне -compare, его нет.
+func (d rangeDelta) Percent() string {
+	if d.Before == 0 {
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", float64(d.Delta())*100/float64(d.Before))
+}
+
+// rangeComparison — основной диапазон против диапазона -compare.
+type rangeComparison struct {
+	From, To                 uint64
+	Before, After            int
+	Grown, Dropped, ByTokens []rangeDelta
+}
+
+// compareRange сканирует диапазон [from, to] с теми же опциями, что основной скан, но без
+// побочных выгрузок (-store, -follow-logs, -parquet-dir, -nats-url, -kafka-brokers), и сравнивает его с main.
+func compareRange(ctx context.Context, client *ethclient.Client, opts scanOptions, main *transferCounter, from, to *big.Int) (rangeComparison, error) {
+	opts.FromBlock, opts.ToBlock, opts.LookbackDuration = from, to, 0
+	opts.Store, opts.Feed, opts.Export, opts.Publish, opts.Kafka = nil, nil, nil, nil, nil
+	other := newTransferCounter(client, opts)
+	before, err := currentBlock(ctx, client, other)
+	if err != nil && !errors.Is(err, metric.ErrNoLogs) {
+		return rangeComparison{}, err
+	}
+	after, err := main.Metrics()
+	if err != nil && !errors.Is(err, metric.ErrNoLogs) {
+		return rangeComparison{}, err
+	}
+
+	comparison := rangeComparison{From: from.Uint64(), To: to.Uint64(), Before: other.Stats().Transfers, After: main.Stats().Transfers}
+	for _, d := range diffRanges(labelCounts(before), labelCounts(after)) {
+		switch {
+		case d.Delta() > 0:
+			comparison.Grown = append(comparison.Grown, d)
+		case d.Delta() < 0:
+			comparison.Dropped = append(comparison.Dropped, d)
+		}
+	}
+	// Выпавшие отсортированы по убыванию модуля, то есть самые сильные падения — первыми.
+	sort.SliceStable(comparison.Dropped, func(i, j int) bool {
+		return comparison.Dropped[i].Delta() < comparison.Dropped[j].Delta()
+	})
+	comparison.ByTokens = diffRanges(tokenCounts(other.TokenMetrics()), tokenCounts(main.TokenMetrics()))
+	return comparison, nil
+}
+
+// previousRange — диапазон той же длины, что [from, to], сразу перед ним; ok == false, если перед from нет блоков.
+func previousRange(from, to uint64) (prevFrom, prevTo uint64, ok bool) {
+	if from == 0 {
+		return 0, 0, false
+	}
+	length := to - from + 1
+	prevTo = from - 1
+	if length > from {
+		return 0, prevTo, true
+	}
+	return from - length, prevTo, true
+}
+
+func labelCounts(metrics []Metric) map[string]int {
+	counts := make(map[string]int, len(metrics))
+	for _, m := range metrics {
+		counts[m.Label()] = m.Count
+	}
+	return counts
+}
+
+func tokenCounts(tokens []TokenMetric) map[string]int {
+	counts := make(map[string]int, len(tokens))
+	for _, t := range tokens {
+		counts[t.Token.Hex()] = t.Transfers
+	}
+	return counts
+}
+
+// diffRanges объединяет строки обоих диапазонов и сортирует их по модулю изменения.
+func diffRanges(before, after map[string]int) []rangeDelta {
+	var diffs []rangeDelta
+	for label, count := range after {
+		diffs = append(diffs, rangeDelta{Label: label, Before: before[label], After: count})
+	}
+	for label, count := range before {
+		if _, ok := after[label]; !ok {
+			diffs = append(diffs, rangeDelta{Label: label, Before: count})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if abs(diffs[i].Delta()) != abs(diffs[j].Delta()) {
+			return abs(diffs[i].Delta()) > abs(diffs[j].Delta())
+		}
+		return diffs[i].Label < diffs[j].Label
+	})
+	return diffs
+}
+
+// writeComparison печатает сравнение после рейтинга: общий итог, topN адресов с наибольшим
+// ростом и падением и изменения по токенам.
+func writeComparison(w io.Writer, c rangeComparison, stats ScanStats, opts outputOptions) error {
+	total := rangeDelta{Before: c.Before, After: c.After}
+	sections := []struct {
+		title string
+		rows  []rangeDelta
+		token bool
+	}{
+		{"biggest growth", c.Grown, false},
+		{"biggest drop", c.Dropped, false},
+		{"tokens", c.ByTokens, true},
+	}
+
+	if opts.Format == formatMarkdown {
+		if _, err := fmt.Fprintf(w, "Blocks %d-%d compared with %d-%d: %d -> %d transfers (%+d, %s)\n\n",
+			stats.FromBlock, stats.ToBlock, c.From, c.To, c.Before, c.After, total.Delta(), total.Percent()); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"Change", "Address", "Before", "After", "Delta", "Delta %"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, section := range sections {
+			for _, d := range topDeltas(section.rows) {
+				label := d.Label
+				if section.token {
+					label = opts.Tokens.Label(common.HexToAddress(label))
+				}
+				if err := writeMarkdownRow(w, []string{section.title, label, fmt.Sprint(d.Before), fmt.Sprint(d.After), fmt.Sprintf("%+d", d.Delta()), d.Percent()}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "compared with blocks %d-%d: %d -> %d transfers (%+d, %s)\n", c.From, c.To, c.Before, c.After, total.Delta(), total.Percent()); err != nil {
+		return err
+	}
+	for _, section := range sections {
+		rows := topDeltas(section.rows)
+		if len(rows) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s:\n", section.title); err != nil {
+			return err
+		}
+		for _, d := range rows {
+			label := d.Label
+			if section.token {
+				label = "token " + opts.Tokens.Label(common.HexToAddress(label))
+			}
+			if _, err := fmt.Fprintf(w, "  %v: %d -> %d (%+d, %s)\n", label, d.Before, d.After, d.Delta(), d.Percent()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func topDeltas(rows []rangeDelta) []rangeDelta {
+	if len(rows) > topN {
+		return rows[:topN]
+	}
+	return rows
+}
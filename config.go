@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config описывает значения по умолчанию для флагов; ключи совпадают с именами флагов.
+type Config struct {
+	// Chains — RPC URL и chain ID сетей для -chain; не флаг, а отдельная секция.
+	Chains map[string]chainConfig `yaml:"chains"`
+
+	RPCURL            *string  `yaml:"rpc-url"`
+	WSURL             *string  `yaml:"ws-url"`
+	RPCHeaders        []string `yaml:"rpc-header"`
+	Format            *string  `yaml:"format"`
+	AddressesOnly     *bool    `yaml:"addresses-only"`
+	Direction         *string  `yaml:"direction"`
+	Decimals          *bool    `yaml:"decimals"`
+	Watch             *bool    `yaml:"watch"`
+	MaxLogs           *int     `yaml:"max-logs"`
+	MaxTotalLogs      *int     `yaml:"max-total-logs"`
+	ByTxSender        *bool    `yaml:"by-tx-sender"`
+	GroupPrefix       *int     `yaml:"group-prefix"`
+	TokenRegistry     *string  `yaml:"token-registry"`
+	ByToken           *bool    `yaml:"by-token"`
+	ResolveProxy      *bool    `yaml:"resolve-proxy"`
+	ENS               *bool    `yaml:"ens"`
+	Fields            *string  `yaml:"fields"`
+	FromAny           *string  `yaml:"from-any"`
+	ToAny             *string  `yaml:"to-any"`
+	CountZero         *bool    `yaml:"count-zero"`
+	TopTokens         *bool    `yaml:"top-tokens"`
+	BreakerThreshold  *int     `yaml:"breaker-threshold"`
+	BreakerCooldown   *string  `yaml:"breaker-cooldown"`
+	LogsFile          *string  `yaml:"logs-file"`
+	Decay             *string  `yaml:"decay"`
+	DecayHalfLife     *float64 `yaml:"decay-half-life"`
+	SuccessfulOnly    *bool    `yaml:"successful-only"`
+	RankOf            *string  `yaml:"rank-of"`
+	Out               *string  `yaml:"out"`
+	Gzip              *bool    `yaml:"gzip"`
+	ApprovalsTo       *string  `yaml:"approvals-to"`
+	EnrichConcurrency *int     `yaml:"enrich-concurrency"`
+	EnrichRPS         *float64 `yaml:"enrich-rps"`
+	Histogram         *bool    `yaml:"histogram"`
+	HistogramBins     *string  `yaml:"histogram-bins"`
+	AtHash            *string  `yaml:"at-hash"`
+	GrafanaWindow     *int     `yaml:"grafana-window"`
+	MinCounterparties *int     `yaml:"min-counterparties"`
+	Sort              *string  `yaml:"sort"`
+	Order             *string  `yaml:"order"`
+	ABIDir            *string  `yaml:"abi-dir"`
+	Supply            *bool    `yaml:"supply"`
+	TopShare          *float64 `yaml:"top-share"`
+	BarWidth          *int     `yaml:"bar-width"`
+	ShortAddr         *bool    `yaml:"short-addr"`
+	Lookback          *int     `yaml:"lookback"`
+	CountMode         *string  `yaml:"count-mode"`
+	FollowLogs        *string  `yaml:"follow-logs"`
+	LogRotateSize     *int     `yaml:"log-rotate-size"`
+	IncludePending    *bool    `yaml:"include-pending"`
+	ValueSample       *float64 `yaml:"value-sample"`
+	Timeout           *string  `yaml:"timeout"`
+	RequestTimeout    *string  `yaml:"request-timeout"`
+	Baseline          *string  `yaml:"baseline"`
+	Labels            *string  `yaml:"labels"`
+	GroupByCategory   *bool    `yaml:"group-by-category"`
+	DedupeLogs        *bool    `yaml:"dedupe-logs"`
+	EOAOnly           *bool    `yaml:"eoa-only"`
+	ContractsOnly     *bool    `yaml:"contracts-only"`
+	SortSecondary     *string  `yaml:"sort-secondary"`
+	Audit             *bool    `yaml:"audit"`
+	LookbackDuration  *string  `yaml:"lookback-duration"`
+	BlockTime         *string  `yaml:"block-time"`
+	Proxy             *string  `yaml:"proxy"`
+	Detail            *string  `yaml:"detail"`
+	FromBlock         *string  `yaml:"from-block"`
+	ToBlock           *string  `yaml:"to-block"`
+	Contracts         *string  `yaml:"contracts"`
+	WatchEvery        *int     `yaml:"watch-every"`
+	ChunkSize         *int     `yaml:"chunk-size"`
+	Workers           *int     `yaml:"workers"`
+	FetchRPS          *float64 `yaml:"fetch-rps"`
+	ServeMetrics      *string  `yaml:"serve-metrics"`
+	ServeAPI          *string  `yaml:"serve-api"`
+	Store             *string  `yaml:"store"`
+	Chain             *string  `yaml:"chain"`
+	HealthInterval    *string  `yaml:"health-interval"`
+	Standard          *string  `yaml:"standard"`
+	TokenMetadata     *bool    `yaml:"token-metadata"`
+	TokenCache        *string  `yaml:"token-cache"`
+	ENSCache          *string  `yaml:"ens-cache"`
+	ENSCacheTTL       *string  `yaml:"ens-cache-ttl"`
+	ExcludeLabels     *string  `yaml:"exclude-labels"`
+	TopTokensBy       *string  `yaml:"top-tokens-by"`
+	TopSpenders       *bool    `yaml:"top-spenders"`
+	ABIFiles          *string  `yaml:"abi"`
+	EventNames        *string  `yaml:"events"`
+	EventSums         *string  `yaml:"event-sum"`
+	TopEvents         *bool    `yaml:"top-events"`
+	Bucket            *string  `yaml:"bucket"`
+	MinValue          *string  `yaml:"min-value"`
+	PriceSource       *string  `yaml:"price-source"`
+	PriceFeeds        *string  `yaml:"price-feeds"`
+	PriceInterval     *int     `yaml:"price-interval"`
+	CoinGeckoURL      *string  `yaml:"coingecko-url"`
+	CoinGeckoPlatform *string  `yaml:"coingecko-platform"`
+	WatchlistFile     *string  `yaml:"watchlist-file"`
+	NotifyWebhook     *string  `yaml:"notify-webhook"`
+	TelegramChat      *string  `yaml:"telegram-chat"`
+	RPCRetries        *int     `yaml:"rpc-retries"`
+	RPCBackoff        *string  `yaml:"rpc-backoff"`
+	RPCBackoffMax     *string  `yaml:"rpc-backoff-max"`
+	RPCRPS            *float64 `yaml:"rpc-rps"`
+	Interval          *string  `yaml:"interval"`
+	Growth            *bool    `yaml:"growth"`
+	TxCounts          *bool    `yaml:"tx-counts"`
+	Gas               *bool    `yaml:"gas"`
+	TUI               *bool    `yaml:"tui"`
+	ParquetDir        *string  `yaml:"parquet-dir"`
+	ParquetRows       *int     `yaml:"parquet-rows"`
+	NATSURL           *string  `yaml:"nats-url"`
+	NATSSubject       *string  `yaml:"nats-subject"`
+	KafkaBrokers      *string  `yaml:"kafka-brokers"`
+	KafkaTopic        *string  `yaml:"kafka-topic"`
+	GRPCAddr          *string  `yaml:"grpc"`
+	GRPCCert          *string  `yaml:"grpc-cert"`
+	GRPCKey           *string  `yaml:"grpc-key"`
+	Snapshot          *bool    `yaml:"snapshot"`
+	Multicall         *string  `yaml:"multicall"`
+	SnapshotBatch     *int     `yaml:"snapshot-batch"`
+	Wash              *bool    `yaml:"wash"`
+	WashThreshold     *float64 `yaml:"wash-threshold"`
+	ValueStats        *bool    `yaml:"value-stats"`
+	LogLevel          *string  `yaml:"log-level"`
+	LogFormat         *string  `yaml:"log-format"`
+	Graph             *string  `yaml:"graph"`
+	GraphFormat       *string  `yaml:"graph-format"`
+	GraphTop          *int     `yaml:"graph-top"`
+	PartialOK         *bool    `yaml:"partial-ok"`
+	Watchlist         *string  `yaml:"watchlist"`
+	AlertThreshold    *int     `yaml:"alert-threshold"`
+	AlertExit         *bool    `yaml:"alert-exit"`
+}
+
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		// TOML приводится к тому же дереву, что и YAML, чтобы проверка ключей и типов была общей.
+		tree, err := parseTOML(data)
+		if err != nil {
+			return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(tree); err != nil {
+			return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	cfg.expandEnv()
+	return cfg, nil
+}
+
+// expandEnv подставляет ${VAR} и $VAR из окружения во все строковые значения конфига,
+// включая URL секции chains (числовые и логические поля не трогает), чтобы секреты не хранились в файле.
+// Незаданная переменная заменяется пустой строкой с предупреждением.
+func (cfg *Config) expandEnv() {
+	expand := func(value string) string {
+		return os.Expand(value, func(name string) string {
+			env, ok := os.LookupEnv(name)
+			if !ok {
+				logger.Warn("config references an unset environment variable, using an empty value", "variable", name)
+			}
+			return env
+		})
+	}
+
+	fields := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		switch value := field.Interface().(type) {
+		case *string:
+			if value != nil {
+				expanded := expand(*value)
+				field.Set(reflect.ValueOf(&expanded))
+			}
+		case []string:
+			for j := range value {
+				value[j] = expand(value[j])
+			}
+		}
+	}
+
+	for name, chain := range cfg.Chains {
+		chain.RPCURL, chain.WSURL = expand(chain.RPCURL), expand(chain.WSURL)
+		cfg.Chains[name] = chain
+	}
+}
+
+func (cfg Config) flagValues() map[string][]string {
+	values := make(map[string][]string)
+
+	setString := func(name string, value *string) {
+		if value != nil {
+			values[name] = []string{*value}
+		}
+	}
+	setBool := func(name string, value *bool) {
+		if value != nil {
+			values[name] = []string{strconv.FormatBool(*value)}
+		}
+	}
+	setFloat := func(name string, value *float64) {
+		if value != nil {
+			values[name] = []string{strconv.FormatFloat(*value, 'g', -1, 64)}
+		}
+	}
+	setInt := func(name string, value *int) {
+		if value != nil {
+			values[name] = []string{strconv.Itoa(*value)}
+		}
+	}
+
+	setString("rpc-url", cfg.RPCURL)
+	setString("ws-url", cfg.WSURL)
+	if len(cfg.RPCHeaders) > 0 {
+		values["rpc-header"] = cfg.RPCHeaders
+	}
+	setString("format", cfg.Format)
+	setBool("addresses-only", cfg.AddressesOnly)
+	setString("direction", cfg.Direction)
+	setBool("decimals", cfg.Decimals)
+	setBool("watch", cfg.Watch)
+	setInt("max-logs", cfg.MaxLogs)
+	setInt("max-total-logs", cfg.MaxTotalLogs)
+	setBool("by-tx-sender", cfg.ByTxSender)
+	setInt("group-prefix", cfg.GroupPrefix)
+	setString("token-registry", cfg.TokenRegistry)
+	setBool("by-token", cfg.ByToken)
+	setBool("resolve-proxy", cfg.ResolveProxy)
+	setBool("ens", cfg.ENS)
+	setString("fields", cfg.Fields)
+	setString("from-any", cfg.FromAny)
+	setString("to-any", cfg.ToAny)
+	setBool("count-zero", cfg.CountZero)
+	setBool("top-tokens", cfg.TopTokens)
+	setInt("breaker-threshold", cfg.BreakerThreshold)
+	setString("breaker-cooldown", cfg.BreakerCooldown)
+	setString("logs-file", cfg.LogsFile)
+	setString("decay", cfg.Decay)
+	setFloat("decay-half-life", cfg.DecayHalfLife)
+	setBool("successful-only", cfg.SuccessfulOnly)
+	setString("rank-of", cfg.RankOf)
+	setString("out", cfg.Out)
+	setBool("gzip", cfg.Gzip)
+	setString("approvals-to", cfg.ApprovalsTo)
+	setInt("enrich-concurrency", cfg.EnrichConcurrency)
+	setFloat("enrich-rps", cfg.EnrichRPS)
+	setBool("histogram", cfg.Histogram)
+	setString("histogram-bins", cfg.HistogramBins)
+	setString("at-hash", cfg.AtHash)
+	setInt("grafana-window", cfg.GrafanaWindow)
+	setInt("min-counterparties", cfg.MinCounterparties)
+	setString("sort", cfg.Sort)
+	setString("order", cfg.Order)
+	setString("abi-dir", cfg.ABIDir)
+	setBool("supply", cfg.Supply)
+	setFloat("top-share", cfg.TopShare)
+	setInt("bar-width", cfg.BarWidth)
+	setBool("short-addr", cfg.ShortAddr)
+	setInt("lookback", cfg.Lookback)
+	setString("count-mode", cfg.CountMode)
+	setString("follow-logs", cfg.FollowLogs)
+	setInt("log-rotate-size", cfg.LogRotateSize)
+	setBool("include-pending", cfg.IncludePending)
+	setFloat("value-sample", cfg.ValueSample)
+	setString("timeout", cfg.Timeout)
+	setString("request-timeout", cfg.RequestTimeout)
+	setString("baseline", cfg.Baseline)
+	setString("labels", cfg.Labels)
+	setBool("group-by-category", cfg.GroupByCategory)
+	setBool("dedupe-logs", cfg.DedupeLogs)
+	setBool("eoa-only", cfg.EOAOnly)
+	setBool("contracts-only", cfg.ContractsOnly)
+	setString("sort-secondary", cfg.SortSecondary)
+	setBool("audit", cfg.Audit)
+	setString("lookback-duration", cfg.LookbackDuration)
+	setString("block-time", cfg.BlockTime)
+	setString("proxy", cfg.Proxy)
+	setString("detail", cfg.Detail)
+	setString("from-block", cfg.FromBlock)
+	setString("to-block", cfg.ToBlock)
+	setString("contracts", cfg.Contracts)
+	setInt("watch-every", cfg.WatchEvery)
+	setInt("chunk-size", cfg.ChunkSize)
+	setInt("workers", cfg.Workers)
+	setFloat("fetch-rps", cfg.FetchRPS)
+	setString("serve-metrics", cfg.ServeMetrics)
+	setString("serve-api", cfg.ServeAPI)
+	setString("store", cfg.Store)
+	setString("chain", cfg.Chain)
+	setString("health-interval", cfg.HealthInterval)
+	setString("standard", cfg.Standard)
+	setBool("token-metadata", cfg.TokenMetadata)
+	setString("token-cache", cfg.TokenCache)
+	setString("ens-cache", cfg.ENSCache)
+	setString("ens-cache-ttl", cfg.ENSCacheTTL)
+	setString("exclude-labels", cfg.ExcludeLabels)
+	setString("top-tokens-by", cfg.TopTokensBy)
+	setBool("top-spenders", cfg.TopSpenders)
+	setString("abi", cfg.ABIFiles)
+	setString("events", cfg.EventNames)
+	setString("event-sum", cfg.EventSums)
+	setBool("top-events", cfg.TopEvents)
+	setString("bucket", cfg.Bucket)
+	setString("min-value", cfg.MinValue)
+	setString("price-source", cfg.PriceSource)
+	setString("price-feeds", cfg.PriceFeeds)
+	setInt("price-interval", cfg.PriceInterval)
+	setString("coingecko-url", cfg.CoinGeckoURL)
+	setString("coingecko-platform", cfg.CoinGeckoPlatform)
+	setString("watchlist-file", cfg.WatchlistFile)
+	setString("notify-webhook", cfg.NotifyWebhook)
+	setString("telegram-chat", cfg.TelegramChat)
+	setInt("rpc-retries", cfg.RPCRetries)
+	setString("rpc-backoff", cfg.RPCBackoff)
+	setString("rpc-backoff-max", cfg.RPCBackoffMax)
+	setFloat("rpc-rps", cfg.RPCRPS)
+	setString("interval", cfg.Interval)
+	setBool("growth", cfg.Growth)
+	setBool("tx-counts", cfg.TxCounts)
+	setBool("gas", cfg.Gas)
+	setBool("tui", cfg.TUI)
+	setString("parquet-dir", cfg.ParquetDir)
+	setInt("parquet-rows", cfg.ParquetRows)
+	setString("nats-url", cfg.NATSURL)
+	setString("nats-subject", cfg.NATSSubject)
+	setString("kafka-brokers", cfg.KafkaBrokers)
+	setString("kafka-topic", cfg.KafkaTopic)
+	setString("grpc", cfg.GRPCAddr)
+	setString("grpc-cert", cfg.GRPCCert)
+	setString("grpc-key", cfg.GRPCKey)
+	setBool("snapshot", cfg.Snapshot)
+	setString("multicall", cfg.Multicall)
+	setInt("snapshot-batch", cfg.SnapshotBatch)
+	setBool("wash", cfg.Wash)
+	setFloat("wash-threshold", cfg.WashThreshold)
+	setBool("value-stats", cfg.ValueStats)
+	setString("log-level", cfg.LogLevel)
+	setString("log-format", cfg.LogFormat)
+	setString("graph", cfg.Graph)
+	setString("graph-format", cfg.GraphFormat)
+	setInt("graph-top", cfg.GraphTop)
+	setBool("partial-ok", cfg.PartialOK)
+	setString("watchlist", cfg.Watchlist)
+	setInt("alert-threshold", cfg.AlertThreshold)
+	setBool("alert-exit", cfg.AlertExit)
+
+	return values
+}
+
+// envPrefix — префикс переменных окружения, переопределяющих флаги: -rpc-url задаётся METRIC_RPC_URL.
+const envPrefix = "METRIC_"
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// explicitFlags — флаги, заданные в командной строке, вместе с их синонимами: -sort-by, -last-n
+// и -follow делят flag.Value с -sort, -lookback и -watch, и заданный синоним защищает основной флаг.
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	values := make(map[flag.Value]bool)
+	fs.Visit(func(f *flag.Flag) {
+		values[f.Value] = true
+	})
+	explicit := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		if values[f.Value] {
+			explicit[f.Name] = true
+		}
+	})
+	return explicit
+}
+
+// applyEnv проставляет значения из METRIC_* флагам, не заданным в командной строке. Вызывается
+// до applyConfig, поэтому окружение важнее файла: флаг > окружение > конфиг > значение по умолчанию.
+func applyEnv(fs *flag.FlagSet) error {
+	explicit := explicitFlags(fs)
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || explicit[f.Name] || err != nil {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid %s: %w", envName(f.Name), setErr)
+		}
+	})
+	return err
+}
+
+// applyConfig проставляет значения из файла только тем флагам, которые не заданы в командной строке.
+func applyConfig(fs *flag.FlagSet, cfg Config) error {
+	explicit := explicitFlags(fs)
+
+	for name, values := range cfg.flagValues() {
+		// Один конфиг годится для всех подкоманд: флаги, которых у подкоманды нет, пропускаются.
+		if explicit[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		for _, value := range values {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid config value for %s: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
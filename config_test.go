@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigExpandsEnv(t *testing.T) {
+	t.Setenv("METRIC_TEST_KEY", "s3cret")
+	os.Unsetenv("METRIC_TEST_UNSET")
+
+	path := filepath.Join(t.TempDir(), "metric.yaml")
+	config := `rpc-url: https://go.getblock.io/${METRIC_TEST_KEY}
+ws-url: wss://node.example/$METRIC_TEST_UNSET
+rpc-header:
+  - "Authorization: Bearer ${METRIC_TEST_KEY}"
+decimals: true
+chains:
+  optimism:
+    rpc-url: https://go.getblock.io/${METRIC_TEST_KEY}/op
+    chain-id: 10
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	defer setLogOutput(setLogOutput(&logs))
+
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"set variable", *cfg.RPCURL, "https://go.getblock.io/s3cret"},
+		{"unset variable", *cfg.WSURL, "wss://node.example/"},
+		{"list value", cfg.RPCHeaders[0], "Authorization: Bearer s3cret"},
+		{"chains section", cfg.Chains["optimism"].RPCURL, "https://go.getblock.io/s3cret/op"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if cfg.Decimals == nil || !*cfg.Decimals {
+		t.Error("a boolean value was lost")
+	}
+
+	warnings := strings.Count(logs.String(), "warning: config references an unset environment variable, using an empty value variable=METRIC_TEST_UNSET")
+	if warnings != 1 {
+		t.Errorf("got %d warnings about the unset variable:\n%s", warnings, logs.String())
+	}
+	if strings.Contains(logs.String(), "METRIC_TEST_KEY") {
+		t.Errorf("a set variable was reported:\n%s", logs.String())
+	}
+}
+
+// Синоним, заданный в командной строке, не перекрывается ни окружением, ни конфигом основного флага.
+func TestExplicitAliasWins(t *testing.T) {
+	newFlags := func(args ...string) (*flag.FlagSet, *string, *uint64) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		sortBy := fs.String("sort", sortCount, "")
+		fs.StringVar(sortBy, "sort-by", sortCount, "")
+		lookback := fs.Uint64("lookback", defaultLookback, "")
+		fs.Uint64Var(lookback, "last-n", defaultLookback, "")
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return fs, sortBy, lookback
+	}
+	address, lookback := sortAddress, 7
+	cfg := Config{Sort: &address, Lookback: &lookback}
+
+	fs, sortBy, last := newFlags("-sort-by", "value", "-last-n", "3")
+	if err := applyConfig(fs, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if *sortBy != sortValue || *last != 3 {
+		t.Errorf("config: -sort %s -lookback %d, want value and 3 from the command line", *sortBy, *last)
+	}
+
+	t.Setenv("METRIC_SORT", sortAddress)
+	t.Setenv("METRIC_LOOKBACK", "7")
+	fs, sortBy, last = newFlags("-sort-by", "value", "-last-n", "3")
+	if err := applyEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *sortBy != sortValue || *last != 3 {
+		t.Errorf("environment: -sort %s -lookback %d, want value and 3 from the command line", *sortBy, *last)
+	}
+}
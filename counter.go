@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"getBlock/metric"
+)
+
+// logKey однозначно определяет лог: провайдеры иногда возвращают один и тот же лог дважды.
+type logKey struct {
+	TxHash common.Hash
+	Index  uint
+}
+
+// ErrTooManyLogs возвращается, когда превышен общий лимит -max-total-logs.
+var ErrTooManyLogs = errors.New("total logs limit exceeded")
+
+// transferCounter накапливает статистику по адресам из Transfer логов.
+type transferCounter struct {
+	opts scanOptions
+	// failed — окна, пропущенные -partial-ok; пишутся воркерами загрузки.
+	failedMu  sync.Mutex
+	failed    []FailedRange
+	counts    map[common.Address]int
+	scores    map[common.Address]float64
+	rawValues map[common.Address]*big.Int
+	values    map[common.Address]*big.Rat
+	// unscaled — адреса с переводами токенов без известных decimals: суммы в токенах у них нет.
+	unscaled  map[common.Address]bool
+	decimals  *decimalsCache
+	senders   *senderCache
+	gas       *gasCache
+	receipts  *receiptCache
+	headers   *headerCache
+	codes     *codeCache
+	pool      *enrichPool
+	fetchPool *enrichPool
+	stats     *Stats
+	audit     *auditLog
+	// progress — ход текущего CountRange для строк "scan progress"; nil вне скана.
+	progress *scanProgress
+
+	pairCounts    map[pairKey]int
+	pairRawValues map[pairKey]*big.Int
+	pairValues    map[pairKey]*big.Rat
+
+	tokenParticipants map[common.Address]map[common.Address]struct{}
+	tokenEvents       map[common.Address]int
+
+	spenders map[common.Address]*spenderStats
+
+	// eventStats — сводка -top-events по сигнатуре события.
+	eventStats map[string]*eventStats
+
+	windowCounts map[uint64]map[common.Address]int
+
+	// blockTransfers и blockActive — переводы и активные адреса по блокам для -bucket.
+	blockTransfers map[uint64]int
+	blockActive    map[uint64]map[common.Address]struct{}
+
+	// activeAddresses и knownAddresses — адреса диапазона и прошлых прогонов -store для -growth.
+	activeAddresses map[common.Address]struct{}
+	knownAddresses  map[common.Address]struct{}
+
+	counterparties map[common.Address]map[common.Address]struct{}
+	// flows — переводы по направлениям для -wash и -graph.
+	flows map[flowKey]int
+	// transferValues — суммы переводов по токенам для -value-stats.
+	transferValues map[common.Address][]*big.Int
+
+	sent, received map[common.Address]int
+	inflow         map[common.Address]*big.Int
+
+	spans map[common.Address]blockSpan
+	// txs — различные транзакции адреса для -tx-counts и -gas.
+	txs map[common.Address]map[common.Hash]struct{}
+
+	sentVolume, receivedVolume map[common.Address]*big.Rat
+
+	// notifier — уведомления -watchlist-file; задаётся только на время -watch.
+	notifier *transferNotifier
+
+	// prices — цены -price-source; nil, если USD не считается.
+	prices    *priceCache
+	usdValues map[common.Address]*big.Rat
+
+	details []detailTransfer
+	whales  []whaleTransfer
+
+	blockCounts map[uint64]map[common.Address]*storedCount
+	// blockHashes — хеши блоков с переводами для проверки реорганизации в -store.
+	blockHashes map[uint64]common.Hash
+
+	seenLogs map[logKey]struct{}
+	// counted — учтённые переводы последних reorgDepth блоков (с -watch) и их блоки: Remove
+	// откатывает только их.
+	counted         map[logKey]uint64
+	countedPrunedAt uint64
+
+	seenTokens map[common.Address]struct{}
+
+	sampled      int
+	sampledRaw   *big.Int
+	sampledValue *big.Rat
+
+	mints, burns             int
+	supplies                 map[common.Address]*TokenSupply
+	minted, burned           *big.Int
+	mintedValue, burnedValue *big.Rat
+
+	fromBlock, toBlock uint64
+}
+
+// SetRange задаёт просканированный диапазон блоков: для -decay и ScanStats.
+func (c *transferCounter) SetRange(from, to uint64) {
+	c.fromBlock, c.toBlock = from, to
+}
+
+func newTransferCounter(client *ethclient.Client, opts scanOptions) *transferCounter {
+	stats := new(Stats)
+	c := &transferCounter{
+		opts:      opts,
+		counts:    make(map[common.Address]int),
+		scores:    make(map[common.Address]float64),
+		rawValues: make(map[common.Address]*big.Int),
+		values:    make(map[common.Address]*big.Rat),
+		unscaled:  make(map[common.Address]bool),
+		decimals:  newDecimalsCache(client, opts.TokenRegistry, stats),
+		senders:   newSenderCache(client, stats),
+		gas:       newGasCache(client, stats),
+		receipts:  newReceiptCache(client, stats),
+		headers:   newHeaderCache(client, stats, opts.RequestTimeout),
+		codes:     newCodeCache(client, stats),
+		pool:      newEnrichPool(opts.EnrichConcurrency, opts.EnrichRPS),
+		fetchPool: newEnrichPool(opts.Workers, opts.FetchRPS),
+		stats:     stats,
+		audit:     new(auditLog),
+
+		pairCounts:    make(map[pairKey]int),
+		pairRawValues: make(map[pairKey]*big.Int),
+		pairValues:    make(map[pairKey]*big.Rat),
+
+		tokenParticipants: make(map[common.Address]map[common.Address]struct{}),
+		tokenEvents:       make(map[common.Address]int),
+
+		spenders: make(map[common.Address]*spenderStats),
+
+		eventStats: make(map[string]*eventStats),
+
+		windowCounts: make(map[uint64]map[common.Address]int),
+
+		blockTransfers: make(map[uint64]int),
+		blockActive:    make(map[uint64]map[common.Address]struct{}),
+
+		activeAddresses: make(map[common.Address]struct{}),
+
+		counterparties: make(map[common.Address]map[common.Address]struct{}),
+		flows:          make(map[flowKey]int),
+
+		transferValues: make(map[common.Address][]*big.Int),
+
+		sent:     make(map[common.Address]int),
+		received: make(map[common.Address]int),
+		inflow:   make(map[common.Address]*big.Int),
+
+		spans: make(map[common.Address]blockSpan),
+		txs:   make(map[common.Address]map[common.Hash]struct{}),
+
+		blockCounts: make(map[uint64]map[common.Address]*storedCount),
+		blockHashes: make(map[uint64]common.Hash),
+
+		sentVolume:     make(map[common.Address]*big.Rat),
+		receivedVolume: make(map[common.Address]*big.Rat),
+		usdValues:      make(map[common.Address]*big.Rat),
+
+		seenLogs: make(map[logKey]struct{}),
+		counted:  make(map[logKey]uint64),
+
+		seenTokens: make(map[common.Address]struct{}),
+
+		sampledRaw:   new(big.Int),
+		sampledValue: new(big.Rat),
+
+		supplies:    make(map[common.Address]*TokenSupply),
+		minted:      new(big.Int),
+		burned:      new(big.Int),
+		mintedValue: new(big.Rat),
+		burnedValue: new(big.Rat),
+	}
+	if opts.Prices != nil {
+		c.prices = newPriceCache(opts.Prices, opts.PriceInterval, c.headers)
+	}
+	return c
+}
+
+func (c *transferCounter) Add(ctx context.Context, vLog types.Log) error {
+	if seen := c.stats.IncLogs(); c.opts.MaxTotalLogs > 0 && seen > int64(c.opts.MaxTotalLogs) {
+		return fmt.Errorf("%w: processed more than %d logs", ErrTooManyLogs, c.opts.MaxTotalLogs)
+	}
+
+	if c.opts.DedupeLogs {
+		key := logKey{TxHash: vLog.TxHash, Index: vLog.Index}
+		if _, dup := c.seenLogs[key]; dup {
+			c.stats.IncDuplicate()
+			return nil
+		}
+		c.seenLogs[key] = struct{}{}
+	}
+	if c.opts.Store != nil {
+		c.blockHashes[vLog.BlockNumber] = vLog.BlockHash
+	}
+
+	if len(c.opts.ApprovalSpenders) > 0 {
+		c.addApproval(vLog)
+		return nil
+	}
+	if c.opts.Events != nil {
+		c.addEvent(vLog)
+		return nil
+	}
+	if c.opts.TopSpenders && len(vLog.Topics) > 0 && vLog.Topics[0] == approvalEventHash {
+		c.countSpender(vLog)
+		return nil
+	}
+	if c.opts.Standard == standardERC721 || c.opts.Standard == standardERC1155 {
+		c.addNFT(vLog)
+		return nil
+	}
+
+	transferEvent, err := metric.DecodeTransfer(vLog)
+	if err != nil && len(vLog.Topics) > 0 && vLog.Topics[0] == metric.TransferEventHash {
+		// Лог с сигнатурой Transfer, который не разобрался как ERC20 (ERC721, битые data).
+		c.stats.IncUnpackFailure()
+	}
+	if errors.Is(err, metric.ErrNotTransfer) {
+		return nil
+	}
+	if err != nil {
+		logger.Debug("failed to unpack log from ABI", "tx", vLog.TxHash.Hex(), "index", vLog.Index, "err", err)
+		return nil
+	}
+
+	if c.opts.SuccessfulOnly {
+		successful, err := c.receipts.Successful(ctx, vLog.TxHash)
+		if err != nil {
+			return err
+		}
+		if !successful {
+			return nil
+		}
+	}
+
+	// Вне выборки -value-sample значение не суммируется: raw и scaled остаются nil.
+	raw := transferEvent.Value
+	if c.opts.ValueSample > 0 && !sampledTx(vLog.TxHash, c.opts.ValueSample) {
+		raw = nil
+	}
+
+	var scaled *big.Rat
+	if c.opts.Decimals && raw != nil {
+		tokenDecimals, err := c.decimals.Decimals(ctx, vLog.Address)
+		if err == nil {
+			scaled = scaleValue(transferEvent.Value, tokenDecimals)
+		}
+	}
+
+	if c.opts.Watch {
+		c.trackCounted(vLog)
+	}
+	c.countTransfer(vLog.BlockNumber)
+	c.countTokenEvent(vLog.Address)
+	c.countSupply(vLog.Address, transferEvent, scaled)
+	if c.opts.ValueSample > 0 && raw != nil {
+		c.countSample(raw, scaled)
+	}
+	if c.opts.ValueStats && raw != nil {
+		c.recordValue(vLog.Address, raw)
+	}
+	if c.opts.Feed != nil {
+		if err := c.opts.Feed.Write(vLog, transferEvent); err != nil {
+			return err
+		}
+	}
+	if c.opts.Publish != nil {
+		if err := c.opts.Publish.Write(vLog, transferEvent); err != nil {
+			return err
+		}
+	}
+	if c.opts.Kafka != nil {
+		if err := c.opts.Kafka.Write(vLog, transferEvent); err != nil {
+			return err
+		}
+	}
+	if c.opts.Export != nil {
+		header, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(vLog.BlockNumber))
+		if err != nil {
+			return fmt.Errorf("failed to retrieve header of block %d: %w", vLog.BlockNumber, err)
+		}
+		if err := c.opts.Export.Write(vLog, transferEvent, time.Unix(int64(header.Time), 0).UTC()); err != nil {
+			return err
+		}
+	}
+	if c.notifier != nil {
+		c.notifier.Notify(vLog, transferEvent, scaled)
+	}
+
+	weight := 1.0
+	if c.opts.Decay != "" {
+		weight = decayWeight(c.opts.Decay, c.opts.DecayHalfLife, c.fromBlock, c.toBlock, vLog.BlockNumber)
+	}
+
+	if c.opts.ByTxSender {
+		sender, err := c.senders.Sender(ctx, vLog)
+		if err != nil {
+			return err
+		}
+		c.count(sender, vLog, raw, scaled, weight)
+		if c.opts.Detail != nil {
+			c.recordDetail(vLog, transferEvent, scaled, &sender)
+		}
+		return nil
+	}
+
+	if c.opts.Detail != nil {
+		c.recordDetail(vLog, transferEvent, scaled, nil)
+	}
+	if c.opts.MinValue != nil {
+		c.recordWhale(vLog, transferEvent, scaled)
+	}
+
+	if c.opts.MinCounterparties > 0 || c.opts.Graph {
+		c.trackCounterparty(transferEvent.From, transferEvent.To)
+		c.trackCounterparty(transferEvent.To, transferEvent.From)
+	}
+	if c.opts.Wash || c.opts.Graph {
+		c.trackFlow(vLog.Address, transferEvent)
+	}
+
+	if c.opts.CountMode == countModeMax || c.opts.Sides {
+		c.sent[transferEvent.From]++
+		c.received[transferEvent.To]++
+	}
+
+	if c.opts.Inflow {
+		c.countInflow(transferEvent.To, transferEvent.Value)
+	}
+	if c.opts.Volume && scaled != nil {
+		c.countVolume(transferEvent, scaled)
+	}
+	if c.prices != nil && scaled != nil {
+		c.countUSD(ctx, vLog.Address, transferEvent.From, transferEvent.To, vLog.BlockNumber, scaled)
+	}
+
+	if c.opts.Direction != directionIn {
+		c.count(transferEvent.From, vLog, raw, scaled, weight)
+	}
+	if c.opts.Direction != directionOut {
+		c.count(transferEvent.To, vLog, raw, scaled, weight)
+	}
+	return nil
+}
+
+func (c *transferCounter) count(address common.Address, vLog types.Log, raw *big.Int, scaled *big.Rat, weight float64) {
+	token, block := vLog.Address, vLog.BlockNumber
+	if c.opts.Window > 0 {
+		c.countWindow(address, block)
+	}
+	if c.opts.Bucket != "" {
+		c.trackActive(address, block)
+	}
+	if c.opts.Growth {
+		c.trackGrowth(address)
+	}
+	if c.opts.ByToken {
+		c.countPair(address, token, raw, scaled)
+	}
+	if c.opts.TopTokens || c.opts.Snapshot {
+		c.trackParticipant(token, address)
+	}
+	if c.opts.TokenMetadata {
+		c.seenTokens[token] = struct{}{}
+	}
+	if c.opts.Rate {
+		c.trackSpan(address, block)
+	}
+	if c.opts.TxCounts || c.opts.Gas {
+		c.trackTx(address, vLog.TxHash)
+	}
+	if c.opts.Store != nil {
+		c.trackBlockCount(address, block, raw)
+	}
+
+	c.counts[address]++
+	if c.opts.Decay != "" {
+		c.scores[address] += weight
+	}
+	if !c.opts.Decimals || raw == nil {
+		return
+	}
+
+	if c.rawValues[address] == nil {
+		c.rawValues[address] = new(big.Int)
+		c.values[address] = new(big.Rat)
+	}
+	c.rawValues[address].Add(c.rawValues[address], raw)
+	if scaled != nil {
+		c.values[address].Add(c.values[address], scaled)
+	} else {
+		c.unscaled[address] = true
+	}
+}
+
+// valueOf — сумма адреса в токенах; пустая, если у части его токенов decimals неизвестны
+// и сложить их нельзя.
+func (c *transferCounter) valueOf(address common.Address, value *big.Rat) string {
+	if c.unscaled[address] {
+		return ""
+	}
+	return formatDecimal(value)
+}
+
+func (c *transferCounter) Metrics() ([]Metric, error) {
+	if c.opts.GroupPrefix > 0 || c.opts.GroupByCategory {
+		return c.groupedMetrics()
+	}
+
+	counts := c.rankCounts()
+	metrics, err := metric.SortAddressesByCount(counts)
+	if err != nil {
+		return nil, err
+	}
+
+	// SortAddressesByCount всегда отбрасывает нулевой адрес (минты и сжигания).
+	if zeroCount := counts[common.Address{}]; c.opts.CountZero && zeroCount > 0 {
+		metrics = append(metrics, Metric{Count: zeroCount})
+		metric.SortByCount(metrics)
+	}
+
+	if c.opts.MinCounterparties > 0 {
+		metrics = c.filterCounterparties(metrics)
+	}
+	if len(c.opts.ExcludeLabels) > 0 {
+		metrics = c.filterExcluded(metrics)
+	}
+
+	if c.opts.Decimals && c.opts.ValueSample > 0 {
+		factor := c.sampleFactor()
+		for i := range metrics {
+			metrics[i].RawValue = extrapolateRaw(c.rawValues[metrics[i].Address], factor)
+			metrics[i].Value = c.valueOf(metrics[i].Address, extrapolateValue(c.values[metrics[i].Address], factor))
+		}
+	} else if c.opts.Decimals {
+		for i := range metrics {
+			metrics[i].RawValue = c.rawValues[metrics[i].Address]
+			metrics[i].Value = c.valueOf(metrics[i].Address, c.values[metrics[i].Address])
+		}
+	}
+
+	if c.opts.Inflow {
+		c.sortByInflow(metrics)
+	}
+	if c.opts.Rate {
+		c.fillRates(metrics)
+	}
+	if c.opts.TxCounts {
+		c.fillTxCounts(metrics)
+	}
+	if c.opts.Volume {
+		c.fillVolumes(metrics)
+	}
+	if c.prices != nil {
+		c.fillUSD(metrics)
+	}
+	if c.opts.Sides {
+		c.fillSides(metrics)
+	}
+
+	if c.opts.Decay != "" {
+		for i := range metrics {
+			metrics[i].Score = c.scores[metrics[i].Address]
+		}
+		sort.SliceStable(metrics, func(i, j int) bool {
+			return metrics[i].Score > metrics[j].Score
+		})
+	}
+
+	return metrics, nil
+}
+
+func (c *transferCounter) warnFailedDecimals() {
+	for token, err := range c.decimals.failed {
+		logger.Warn("value of token excluded from decimal sums", "token", token.Hex(), "err", err)
+	}
+}
+
+// unpackWarnRate — доля неразобранных Transfer логов, после которой результат считается подозрительным.
+const unpackWarnRate = 0.1
+
+func (c *transferCounter) warnUnpackFailures() {
+	stats := c.stats.Snapshot()
+	if stats.Logs == 0 || stats.UnpackFailures == 0 {
+		return
+	}
+
+	rate := float64(stats.UnpackFailures) / float64(stats.Logs)
+	if rate <= unpackWarnRate {
+		return
+	}
+	logger.Warn("scanned logs carry the Transfer topic but could not be decoded as ERC20 Transfer; "+
+		"the ranking covers only the rest. Check that the event ABI and topic match the contracts scanned "+
+		"(ERC721 Transfer has 4 topics, rank it with -standard erc721) and that the provider returns complete log data",
+		"failed", stats.UnpackFailures, "logs", stats.Logs, "share", fmt.Sprintf("%.0f%%", 100*rate))
+}
+
+func (c *transferCounter) Stats() ScanStats {
+	stats := c.stats.Snapshot()
+	stats.FromBlock, stats.ToBlock = c.fromBlock, c.toBlock
+	stats.Mints, stats.Burns = c.mints, c.burns
+	stats.FailedRanges = c.failedRanges()
+	stats.Minted = new(big.Int).Set(c.minted)
+	stats.Burned = new(big.Int).Set(c.burned)
+	if c.opts.Decimals {
+		stats.MintedValue = formatDecimal(c.mintedValue)
+		stats.BurnedValue = formatDecimal(c.burnedValue)
+		stats.NetValue = formatDecimal(new(big.Rat).Sub(c.mintedValue, c.burnedValue))
+	}
+	return stats
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"getBlock/metric"
+)
+
+var testToken = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+// testAddress — адрес для тестов, чей последний байт равен n.
+func testAddress(n byte) common.Address {
+	return common.BytesToAddress([]byte{n})
+}
+
+// transferLog собирает лог ERC-20 Transfer в блоке block с индексом index.
+func transferLog(from, to common.Address, value int64, block uint64, index uint) types.Log {
+	return types.Log{
+		Address: testToken,
+		Topics: []common.Hash{
+			metric.TransferEventHash,
+			common.BytesToHash(from.Bytes()),
+			common.BytesToHash(to.Bytes()),
+		},
+		Data:        common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+		BlockNumber: block,
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(block<<16 | uint64(index))),
+		Index:       index,
+	}
+}
+
+func testScanOptions() scanOptions {
+	return scanOptions{
+		Direction:   directionBoth,
+		Standard:    standardERC20,
+		CountMode:   countModeSum,
+		TopTokensBy: tokensByAddresses,
+	}
+}
+
+func countTestLogs(t *testing.T, opts scanOptions, logs []types.Log) []Metric {
+	t.Helper()
+	metrics, err := countLogs(context.Background(), logs, newTransferCounter(nil, opts))
+	if err != nil {
+		t.Fatalf("countLogs: %v", err)
+	}
+	return metrics
+}
+
+func renderTestMetrics(t *testing.T, metrics []Metric, opts outputOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, metrics, ScanStats{}, opts); err != nil {
+		t.Fatalf("writeMetrics: %v", err)
+	}
+	return buf.String()
+}
+
+// tiedLogs — по одному переводу от каждого из n адресов одному получателю: у всех отправителей
+// одинаковое число переводов.
+func tiedLogs(n int) []types.Log {
+	sink := testAddress(0xff)
+	logs := make([]types.Log, n)
+	for i := range logs {
+		logs[i] = transferLog(testAddress(byte(i+1)), sink, 1, 1, uint(i))
+	}
+	return logs
+}
+
+func TestMetricsDeterministicOnTies(t *testing.T) {
+	logs := tiedLogs(40)
+	opts := outputOptions{Format: formatText, Sort: sortCount}
+
+	want := renderTestMetrics(t, countTestLogs(t, testScanOptions(), logs), opts)
+	for run := 0; run < 20; run++ {
+		if got := renderTestMetrics(t, countTestLogs(t, testScanOptions(), logs), opts); got != want {
+			t.Fatalf("run %d differs:\n%s\nwant:\n%s", run, got, want)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden сравнивает got с testdata/name; с -update перезаписывает файл.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// fixtureLogs — переводы 12 блоков: минт, несколько активных адресов с разными суммами и
+// переводы с равным числом, чтобы рейтинг зависел от разрешения равенств.
+func fixtureLogs() []types.Log {
+	var logs []types.Log
+	add := func(from, to common.Address, value int64, block uint64) {
+		var index uint
+		for _, vLog := range logs {
+			if vLog.BlockNumber == block {
+				index++
+			}
+		}
+		logs = append(logs, transferLog(from, to, value, block, index))
+	}
+	add(common.Address{}, testAddress(1), 1000000, 1)
+	for block := uint64(2); block <= 12; block++ {
+		add(testAddress(1), testAddress(byte(block)), int64(block)*1500, block)
+		if block%2 == 0 {
+			add(testAddress(byte(block)), testAddress(2), 250, block)
+		}
+		if block%3 == 0 {
+			add(testAddress(3), testAddress(4), 99, block)
+		}
+	}
+	return logs
+}
+
+// scanFixture сканирует fixtureChain так же, как main сканирует узел: заголовок head, диапазон и CountRange.
+func scanFixture(t *testing.T, opts scanOptions, chain *fixtureChain) (*transferCounter, []Metric) {
+	t.Helper()
+	opts.FromBlock = big.NewInt(1)
+	counter := newTransferCounter(nil, opts)
+	counter.headers = newHeaderCache(chain, counter.stats, 0)
+	metrics, err := currentBlock(context.Background(), chain, counter)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	return counter, metrics
+}
+
+func TestDecodeFixtureGolden(t *testing.T) {
+	var out bytes.Buffer
+	for _, vLog := range newFixtureChain(fixtureLogs(), 0).logs {
+		transfer, err := metric.DecodeTransfer(vLog)
+		if err != nil {
+			t.Fatalf("block %d log %d: %v", vLog.BlockNumber, vLog.Index, err)
+		}
+		fmt.Fprintf(&out, "%d/%d %s -> %s %v\n", vLog.BlockNumber, vLog.Index, transfer.From.Hex(), transfer.To.Hex(), transfer.Value)
+	}
+	checkGolden(t, "decode.golden", out.String())
+}
+
+func TestScanFixtureGolden(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize uint64
+		workers   int
+	}{
+		{"single request", 0, 1},
+		{"chunks", 4, 1},
+		{"parallel chunks", 3, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testScanOptions()
+			opts.ChunkSize, opts.Workers = tt.chunkSize, tt.workers
+			opts.Decimals = true
+			opts.TokenRegistry = map[common.Address]tokenInfo{testToken: {Symbol: "TST", Decimals: 2}}
+			counter, metrics := scanFixture(t, opts, newFixtureChain(fixtureLogs(), 0))
+
+			var out bytes.Buffer
+			if err := writeMetrics(&out, metrics, counter.Stats(), outputOptions{Format: formatText, Sort: sortCount, Decimals: true}); err != nil {
+				t.Fatal(err)
+			}
+			rank, m, _ := metric.RankOf(metrics, testAddress(2))
+			fmt.Fprintf(&out, "rank of %s: #%d of %d with %d transfers\n", m.Address.Hex(), rank, len(metrics), m.Count)
+			checkGolden(t, "scan.golden", out.String())
+		})
+	}
+}
+
+func TestCountZero(t *testing.T) {
+	mint := transferLog(common.Address{}, testAddress(1), 100, 1, 0)
+	burn := transferLog(testAddress(1), common.Address{}, 40, 1, 1)
+
+	tests := []struct {
+		name      string
+		countZero bool
+		want      map[common.Address]int
+	}{
+		{"zero address dropped", false, map[common.Address]int{testAddress(1): 2}},
+		{"-count-zero", true, map[common.Address]int{testAddress(1): 2, {}: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testScanOptions()
+			opts.CountZero = tt.countZero
+			metrics := countTestLogs(t, opts, []types.Log{mint, burn})
+			if len(metrics) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(metrics), len(tt.want))
+			}
+			for _, m := range metrics {
+				if want, ok := tt.want[m.Address]; !ok || m.Count != want {
+					t.Errorf("%s counted %d times, want %d", m.Address.Hex(), m.Count, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCountZeroTieOrder(t *testing.T) {
+	// Строка нулевого адреса делит счёт с обычными и должна встать по тому же правилу, что и они.
+	logs := []types.Log{
+		transferLog(common.Address{}, testAddress(2), 100, 1, 0),
+		transferLog(testAddress(2), common.Address{}, 40, 1, 1),
+		transferLog(testAddress(1), testAddress(3), 10, 2, 0),
+		transferLog(testAddress(1), testAddress(3), 10, 2, 1),
+	}
+	opts := testScanOptions()
+	opts.CountZero = true
+	metrics := countTestLogs(t, opts, logs)
+
+	want := []common.Address{{}, testAddress(1), testAddress(2), testAddress(3)}
+	if len(metrics) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(metrics), len(want))
+	}
+	for i, address := range want {
+		if metrics[i].Address != address {
+			t.Errorf("row %d is %s, want %s", i+1, metrics[i].Address.Hex(), address.Hex())
+		}
+	}
+}
+
+func TestDirection(t *testing.T) {
+	logs := []types.Log{
+		transferLog(testAddress(1), testAddress(2), 10, 1, 0),
+		transferLog(testAddress(1), testAddress(3), 10, 1, 1),
+		transferLog(testAddress(2), testAddress(3), 10, 2, 0),
+	}
+	tests := []struct {
+		direction string
+		want      map[common.Address]int
+	}{
+		{directionBoth, map[common.Address]int{testAddress(1): 2, testAddress(2): 2, testAddress(3): 2}},
+		{directionOut, map[common.Address]int{testAddress(1): 2, testAddress(2): 1}},
+		{directionIn, map[common.Address]int{testAddress(2): 1, testAddress(3): 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.direction, func(t *testing.T) {
+			opts := testScanOptions()
+			opts.Direction = tt.direction
+			metrics := countTestLogs(t, opts, logs)
+			if len(metrics) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(metrics), len(tt.want))
+			}
+			for _, m := range metrics {
+				if m.Count != tt.want[m.Address] {
+					t.Errorf("%s counted %d times, want %d", m.Address.Hex(), m.Count, tt.want[m.Address])
+				}
+			}
+		})
+	}
+}
+
+// rpcHandler отвечает на один JSON-RPC вызов тестового узла.
+type rpcHandler func(method string, params []json.RawMessage) (any, error)
+
+// newTestRPC поднимает HTTP JSON-RPC узел с ответами handler (включая батчи) и возвращает его URL.
+func newTestRPC(t *testing.T, handler rpcHandler) string {
+	t.Helper()
+	type request struct {
+		ID     json.RawMessage   `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	type rpcError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	type response struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result,omitempty"`
+		Error   *rpcError       `json:"error,omitempty"`
+	}
+	answer := func(req request) response {
+		result, err := handler(req.Method, req.Params)
+		if err != nil {
+			return response{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32000, Message: err.Error()}}
+		}
+		return response{JSONRPC: "2.0", ID: req.ID, Result: result}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+			var batch []request
+			if err := json.Unmarshal(body, &batch); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			out := make([]response, len(batch))
+			for i, req := range batch {
+				out[i] = answer(req)
+			}
+			json.NewEncoder(w).Encode(out)
+			return
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(answer(req))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func dialTestRPC(t *testing.T, handler rpcHandler) *ethclient.Client {
+	t.Helper()
+	client, err := ethclient.Dial(newTestRPC(t, handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestDedupeLogs(t *testing.T) {
+	first := transferLog(testAddress(1), testAddress(2), 5, 1, 0)
+	second := transferLog(testAddress(1), testAddress(3), 5, 1, 1)
+	logs := []types.Log{first, second, first, first}
+
+	tests := []struct {
+		name       string
+		dedupe     bool
+		count      int
+		duplicates int
+	}{
+		{"counted as returned", false, 4, 0},
+		{"-dedupe-logs", true, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testScanOptions()
+			opts.DedupeLogs = tt.dedupe
+			counter := newTransferCounter(nil, opts)
+			metrics, err := countLogs(context.Background(), logs, counter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metrics[0].Address != testAddress(1) || metrics[0].Count != tt.count {
+				t.Errorf("sender counted %d times, want %d", metrics[0].Count, tt.count)
+			}
+			if got := counter.Stats().Duplicates; got != tt.duplicates {
+				t.Errorf("stats report %d duplicates, want %d", got, tt.duplicates)
+			}
+		})
+	}
+}
+
+// Без decimals токена сумма в токенах неизвестна: текст не должен печатать её как (0).
+func TestUnknownDecimalsValue(t *testing.T) {
+	other := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	logs := []types.Log{
+		transferLog(testAddress(1), testAddress(2), 150, 1, 0),
+		transferLog(testAddress(3), testAddress(2), 7, 1, 1),
+	}
+	logs[1].Address = other
+	client := dialTestRPC(t, func(method string, _ []json.RawMessage) (any, error) {
+		return nil, errors.New("execution reverted")
+	})
+	opts := testScanOptions()
+	opts.Decimals = true
+	opts.TokenRegistry = map[common.Address]tokenInfo{testToken: {Decimals: 2}}
+	metrics, err := countLogs(context.Background(), logs, newTransferCounter(client, opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := renderTestMetrics(t, metrics, outputOptions{Format: formatText, Sort: sortCount, Decimals: true})
+	want := `address 0x0000000000000000000000000000000000000002 used ERC20 2 times, value 157 raw
+address 0x0000000000000000000000000000000000000001 used ERC20 1 times, value 150 raw (1.5)
+address 0x0000000000000000000000000000000000000003 used ERC20 1 times, value 7 raw
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import "github.com/ethereum/go-ethereum/common"
+
+// trackCounterparty запоминает, с кем адрес обменивался переводами (в обе стороны).
+func (c *transferCounter) trackCounterparty(address, counterparty common.Address) {
+	parties, ok := c.counterparties[address]
+	if !ok {
+		parties = make(map[common.Address]struct{})
+		c.counterparties[address] = parties
+	}
+	parties[counterparty] = struct{}{}
+}
+
+// filterCounterparties отбрасывает адреса, у которых меньше opts.MinCounterparties
+// различных контрагентов: так остаются «хабы», а не пары, гонявшие токены друг другу.
+func (c *transferCounter) filterCounterparties(metrics []Metric) []Metric {
+	filtered := metrics[:0]
+	for _, m := range metrics {
+		if len(c.counterparties[m.Address]) >= c.opts.MinCounterparties {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
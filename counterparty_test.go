@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestMinCounterparties(t *testing.T) {
+	// 0x01 и 0x02 перебрасываются переводами друг с другом, хаб 0x10 платит трём адресам.
+	var logs []types.Log
+	for i := uint(0); i < 10; i++ {
+		logs = append(logs, transferLog(testAddress(1), testAddress(2), 1, 1, i))
+	}
+	for i := byte(0); i < 3; i++ {
+		logs = append(logs, transferLog(testAddress(0x10), testAddress(0x20+i), 1, 2, uint(i)))
+	}
+
+	tests := []struct {
+		min  int
+		want []common.Address
+	}{
+		{0, []common.Address{testAddress(1), testAddress(2), testAddress(0x10), testAddress(0x20), testAddress(0x21), testAddress(0x22)}},
+		{1, []common.Address{testAddress(1), testAddress(2), testAddress(0x10), testAddress(0x20), testAddress(0x21), testAddress(0x22)}},
+		{2, []common.Address{testAddress(0x10)}},
+		{4, nil},
+	}
+	for _, tt := range tests {
+		opts := testScanOptions()
+		opts.MinCounterparties = tt.min
+		metrics := countTestLogs(t, opts, logs)
+		if len(metrics) != len(tt.want) {
+			t.Fatalf("-min-counterparties %d: got %d rows, want %d", tt.min, len(metrics), len(tt.want))
+		}
+		for i, want := range tt.want {
+			if metrics[i].Address != want {
+				t.Errorf("-min-counterparties %d: row %d is %s, want %s", tt.min, i+1, metrics[i].Address.Hex(), want.Hex())
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "github.com/ethereum/go-ethereum/common"
+
+const (
+	countModeSum = "sum"
+	countModeMax = "max"
+)
+
+// rankCounts — значения, по которым строится рейтинг. В режиме -count-mode max адрес
+// получает max(отправлено, получено) вместо суммы, поэтому выше оказываются адреса,
+// работающие преимущественно в одну сторону.
+func (c *transferCounter) rankCounts() map[common.Address]int {
+	if c.opts.CountMode != countModeMax {
+		return c.counts
+	}
+
+	counts := make(map[common.Address]int, len(c.counts))
+	for address := range c.counts {
+		counts[address] = c.sent[address]
+		if received := c.received[address]; received > counts[address] {
+			counts[address] = received
+		}
+	}
+	return counts
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestCountModeMax(t *testing.T) {
+	// 0x01 отправляет и получает по 3 перевода, 0x02 только получает 4.
+	var logs []types.Log
+	var index uint
+	add := func(from, to common.Address) {
+		logs = append(logs, transferLog(from, to, 1, 1, index))
+		index++
+	}
+	for i := 0; i < 3; i++ {
+		add(testAddress(1), testAddress(0x10))
+		add(testAddress(0x11), testAddress(1))
+	}
+	for i := 0; i < 4; i++ {
+		add(testAddress(0x12), testAddress(2))
+	}
+
+	tests := []struct {
+		mode  string
+		top   common.Address
+		count map[common.Address]int
+	}{
+		{countModeSum, testAddress(1), map[common.Address]int{testAddress(1): 6, testAddress(2): 4}},
+		{countModeMax, testAddress(2), map[common.Address]int{testAddress(1): 3, testAddress(2): 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			opts := testScanOptions()
+			opts.CountMode = tt.mode
+			metrics := countTestLogs(t, opts, logs)
+			if metrics[0].Address != tt.top {
+				t.Errorf("top address is %s, want %s", metrics[0].Address.Hex(), tt.top.Hex())
+			}
+			for _, m := range metrics {
+				if want, ok := tt.count[m.Address]; ok && m.Count != want {
+					t.Errorf("%s has rank value %d, want %d", m.Address.Hex(), m.Count, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+const formatCSV = "csv"
+
+// csvFormatter пишет строку заголовка с именами полей -fields и по строке на адрес.
+type csvFormatter struct {
+	opts outputOptions
+}
+
+func (f csvFormatter) Write(w io.Writer, metrics []Metric, _ ScanStats) error {
+	fields := f.opts.fields()
+	writer := csv.NewWriter(w)
+
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			row[i] = field.Value(m)
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
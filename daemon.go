@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runDaemon каждые interval сканирует блоки, вышедшие после последнего сохранённого в -store,
+// дописывает их в -store и печатает обновлённый рейтинг и сводку прогона. Сбой одного прогона не останавливает
+// демон: следующий продолжит с того же места. Возвращается при отмене ctx или ошибке вывода рейтинга.
+func runDaemon(ctx context.Context, client ChainReader, counter *transferCounter, interval time.Duration, report func([]Metric) error, endpoints []string) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	logger.Info("daemon mode: scanning new blocks", "every", interval.String())
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		started := time.Now()
+		before := counter.Stats().Transfers
+		if err := counter.recheckStore(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn("scheduled scan failed", "err", redactErr(err, endpoints...))
+			continue
+		}
+		from, _ := counter.resumeFrom()
+		if counter.opts.Growth {
+			counter.rollGrowth()
+		}
+
+		metrics, err := currentBlock(ctx, client, counter)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Warn("scheduled scan failed", "err", redactErr(err, endpoints...))
+			continue
+		}
+		// Без новых блоков рейтинг не изменился и повторно не печатается.
+		if to := counter.opts.Store.LastBlock; to != nil && from != nil && from.Uint64() <= *to {
+			logger.Info("scheduled scan", "from", from, "to", *to, "new_transfers", counter.Stats().Transfers-before,
+				"addresses", len(metrics), "took", time.Since(started).Round(time.Millisecond).String())
+			if err := report(metrics); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// recheckStore повторяет проверку реорганизации перед очередным прогоном демона. Если -store
+// откатился, из рейтинга вычитаются счётчики удалённых блоков, и следующий скан посчитает их заново.
+func (c *transferCounter) recheckStore(ctx context.Context) error {
+	before, err := c.opts.Store.Totals()
+	if err != nil {
+		return err
+	}
+	if err := c.rewindStore(ctx); err != nil {
+		return err
+	}
+	after, err := c.opts.Store.Totals()
+	if err != nil {
+		return err
+	}
+	for address, count := range before {
+		if c.counts[address] -= count - after[address]; c.counts[address] <= 0 {
+			delete(c.counts, address)
+		}
+	}
+	return nil
+}
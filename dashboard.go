@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// dashboardRefresh — период перерисовки -tui.
+	dashboardRefresh = time.Second
+	// dashboardLogLines — сколько последних сообщений журнала видно под таблицами.
+	dashboardLogLines = 5
+
+	ansiClear      = "\x1b[H\x1b[2J"
+	ansiHideCursor = "\x1b[?25l"
+	ansiShowCursor = "\x1b[?25h"
+)
+
+// dashboard — экран -tui для -watch: рейтинг, высота, переводы в секунду, разбивка по токенам
+// и последние сообщения журнала. Перерисовывается из цикла watch, поэтому читает счётчик без гонок.
+type dashboard struct {
+	out     io.Writer
+	opts    outputOptions
+	started time.Time
+
+	lastDraw      time.Time
+	lastTransfers int
+
+	mu   sync.Mutex
+	logs []string
+}
+
+func newDashboard(opts outputOptions) *dashboard {
+	return &dashboard{out: os.Stdout, opts: opts, started: time.Now()}
+}
+
+// stdoutIsTerminal — в файл или пайп escape-последовательности -tui писать незачем.
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Write принимает вывод стандартного log, чтобы предупреждения не ломали экран.
+func (d *dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		d.logs = append(d.logs, line)
+	}
+	if len(d.logs) > dashboardLogLines {
+		d.logs = d.logs[len(d.logs)-dashboardLogLines:]
+	}
+	return len(p), nil
+}
+
+func (d *dashboard) Start() {
+	fmt.Fprint(d.out, ansiHideCursor)
+}
+
+// Stop возвращает курсор и очищает экран, чтобы итоговый рейтинг печатался как обычно.
+func (d *dashboard) Stop() {
+	fmt.Fprint(d.out, ansiClear+ansiShowCursor)
+}
+
+// Draw перерисовывает экран; высота — последний блок с переводами, который видел счётчик.
+func (d *dashboard) Draw(counter *transferCounter) {
+	now := time.Now()
+	stats := counter.Stats()
+	rate := 0.0
+	if !d.lastDraw.IsZero() {
+		rate = float64(stats.Transfers-d.lastTransfers) / now.Sub(d.lastDraw).Seconds()
+	}
+	d.lastDraw, d.lastTransfers = now, stats.Transfers
+
+	var screen bytes.Buffer
+	screen.WriteString(ansiClear)
+	title := "watching"
+	if d.opts.Chain.Name != "" {
+		title += " " + d.opts.Chain.Name
+	}
+	fmt.Fprintf(&screen, "%s  block %d  %.1f transfers/s  %d transfers since block %d  up %s\n\n",
+		title, stats.ToBlock, rate, stats.Transfers, stats.FromBlock, now.Sub(d.started).Round(time.Second))
+
+	table := tabwriter.NewWriter(&screen, 0, 0, 2, ' ', 0)
+	fields := d.opts.fields()
+	header := []string{"#"}
+	for _, field := range fields {
+		header = append(header, field.Header)
+	}
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+	if metrics, err := counter.Metrics(); err == nil {
+		for i, m := range d.opts.top(metrics) {
+			row := []string{fmt.Sprint(i + 1)}
+			for _, field := range fields {
+				row = append(row, field.Value(m))
+			}
+			fmt.Fprintln(table, strings.Join(row, "\t"))
+		}
+	}
+	table.Flush()
+
+	tokens := counter.TokenMetrics()
+	if len(tokens) > topN {
+		tokens = tokens[:topN]
+	}
+	if len(tokens) > 0 {
+		screen.WriteString("\n")
+		table = tabwriter.NewWriter(&screen, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "Token\tTransfers\tAddresses")
+		for _, t := range tokens {
+			fmt.Fprintf(table, "%s\t%d\t%d\n", d.opts.Tokens.Label(t.Token), t.Transfers, t.Addresses)
+		}
+		table.Flush()
+	}
+
+	d.mu.Lock()
+	if len(d.logs) > 0 {
+		screen.WriteString("\n" + strings.Join(d.logs, "\n") + "\n")
+	}
+	d.mu.Unlock()
+
+	d.out.Write(screen.Bytes())
+}
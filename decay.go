@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	decayLinear      = "linear"
+	decayExponential = "exp"
+)
+
+// decayWeight возвращает вес трансфера из блока block в диапазоне [from, to]:
+//   - linear: (block - from + 1) / (to - from + 1), от 1/(длина диапазона) в начале до 1 в последнем блоке;
+//   - exp: 0.5^((to - block) / halfLife), вес падает вдвое каждые halfLife блоков до конца диапазона.
+func decayWeight(mode string, halfLife float64, from, to, block uint64) float64 {
+	if block > to {
+		block = to
+	}
+	if block < from {
+		block = from
+	}
+
+	switch mode {
+	case decayLinear:
+		return float64(block-from+1) / float64(to-from+1)
+	case decayExponential:
+		return math.Pow(0.5, float64(to-block)/halfLife)
+	default:
+		return 1
+	}
+}
+
+func validateDecay(mode string, halfLife float64) error {
+	switch mode {
+	case "", decayLinear:
+		return nil
+	case decayExponential:
+		if halfLife <= 0 {
+			return fmt.Errorf("-decay-half-life must be positive, got %v", halfLife)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid -decay %q: expected linear or exp", mode)
+	}
+}
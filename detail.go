@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+// detailTransfer — один перевод, учтённый в count адреса -detail.
+type detailTransfer struct {
+	Block        uint64         `json:"block"`
+	TxHash       common.Hash    `json:"tx_hash"`
+	Index        uint           `json:"log_index"`
+	Token        common.Address `json:"token"`
+	Role         string         `json:"role"`
+	Counterparty common.Address `json:"counterparty"`
+	RawValue     *big.Int       `json:"raw_value"`
+	Value        string         `json:"value,omitempty"`
+}
+
+// recordDetail запоминает перевод, если он увеличил count адреса -detail. Хранятся только
+// переводы этого адреса, поэтому память растёт с его активностью, а не со всем диапазоном.
+func (c *transferCounter) recordDetail(vLog types.Log, transferEvent metric.TransferEvents, scaled *big.Rat, sender *common.Address) {
+	detail := *c.opts.Detail
+	add := func(role string, counterparty common.Address) {
+		transfer := detailTransfer{
+			Block:        vLog.BlockNumber,
+			TxHash:       vLog.TxHash,
+			Index:        vLog.Index,
+			Token:        vLog.Address,
+			Role:         role,
+			Counterparty: counterparty,
+			RawValue:     transferEvent.Value,
+		}
+		if scaled != nil {
+			transfer.Value = formatDecimal(scaled)
+		}
+		c.details = append(c.details, transfer)
+	}
+
+	if sender != nil {
+		if *sender == detail {
+			add("tx sender", transferEvent.To)
+		}
+		return
+	}
+	if c.opts.Direction != directionIn && transferEvent.From == detail {
+		add(detailRole("out", transferEvent.To), transferEvent.To)
+	}
+	if c.opts.Direction != directionOut && transferEvent.To == detail {
+		add(detailRole("in", transferEvent.From), transferEvent.From)
+	}
+}
+
+// detailRole помечает перевод с нулевым контрагентом как минт (получено от 0x0) или сжигание (отправлено в 0x0).
+func detailRole(direction string, counterparty common.Address) string {
+	if counterparty != (common.Address{}) {
+		return direction
+	}
+	if direction == "in" {
+		return "mint"
+	}
+	return "burn"
+}
+
+// Details возвращает переводы адреса -detail по возрастанию блока и индекса лога.
+func (c *transferCounter) Details() []detailTransfer {
+	details := append([]detailTransfer(nil), c.details...)
+	sort.SliceStable(details, func(i, j int) bool {
+		if details[i].Block != details[j].Block {
+			return details[i].Block < details[j].Block
+		}
+		return details[i].Index < details[j].Index
+	})
+	return details
+}
+
+func writeDetail(w io.Writer, address common.Address, details []detailTransfer, opts outputOptions) error {
+	switch opts.Format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if details == nil {
+			details = []detailTransfer{}
+		}
+		return encoder.Encode(details)
+	case formatMarkdown:
+		header := []string{"Block", "Tx", "Log", "Token", "Role", "Counterparty", "Raw value"}
+		if opts.Decimals {
+			header = append(header, "Value")
+		}
+		separator := make([]string, len(header))
+		for i := range separator {
+			separator[i] = "---"
+		}
+		if err := writeMarkdownRow(w, header); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, separator); err != nil {
+			return err
+		}
+		for _, d := range details {
+			row := []string{fmt.Sprint(d.Block), d.TxHash.Hex(), fmt.Sprint(d.Index), opts.Tokens.Label(d.Token), d.Role, d.Counterparty.Hex(), d.RawValue.String()}
+			if opts.Decimals {
+				row = append(row, d.Value)
+			}
+			if err := writeMarkdownRow(w, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "%d transfers counted for address %v:\n", len(details), address.Hex()); err != nil {
+		return err
+	}
+	for _, d := range details {
+		line := fmt.Sprintf("block %v tx %v log %v: %v, counterparty %v, token %v, value %v raw",
+			d.Block, d.TxHash.Hex(), d.Index, d.Role, d.Counterparty.Hex(), opts.Tokens.Label(d.Token), d.RawValue)
+		if d.Value != "" {
+			line += " (" + d.Value + ")"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
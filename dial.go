@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
+)
+
+type rpcHeader struct {
+	Name  string
+	Value string
+}
+
+// headerFlags собирает повторяющиеся значения -rpc-header "Name: Value".
+type headerFlags []rpcHeader
+
+func (h *headerFlags) String() string {
+	parts := make([]string, 0, len(*h))
+	for _, header := range *h {
+		parts = append(parts, header.Name+": "+header.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (h *headerFlags) Set(raw string) error {
+	header, err := parseHeader(raw)
+	if err != nil {
+		return err
+	}
+	*h = append(*h, header)
+	return nil
+}
+
+func parseHeader(raw string) (rpcHeader, error) {
+	name, value, ok := strings.Cut(raw, ":")
+	if !ok {
+		return rpcHeader{}, fmt.Errorf("malformed header %q: expected \"Name: Value\"", raw)
+	}
+
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return rpcHeader{}, fmt.Errorf("malformed header %q: invalid header name", raw)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return rpcHeader{}, fmt.Errorf("malformed header %q: value must not contain line breaks", raw)
+	}
+
+	return rpcHeader{Name: textproto.CanonicalMIMEHeaderKey(name), Value: value}, nil
+}
+
+type dialConfig struct {
+	Headers []rpcHeader
+	// Transport используется для HTTP(S) соединений; nil — транспорт по умолчанию.
+	Transport http.RoundTripper
+	// Proxy — прокси -proxy для WebSocket соединений; nil — без прокси.
+	Proxy *url.URL
+}
+
+// parseProxyURL проверяет -proxy: схема http или socks5 (обе поддерживаются и для HTTP,
+// и для WebSocket соединений) и непустой host:port.
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("malformed proxy URL: %w", err)
+	}
+	switch proxy.Scheme {
+	case "http", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q: expected http or socks5", proxy.Scheme)
+	}
+	if proxy.Hostname() == "" || proxy.Port() == "" {
+		return nil, fmt.Errorf("proxy URL %q must include host and port", redactURL(raw))
+	}
+	return proxy, nil
+}
+
+// proxyTransport — копия транспорта по умолчанию, которая ходит через прокси.
+func proxyTransport(proxy *url.URL) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	return transport
+}
+
+func dialClient(ctx context.Context, url string, cfg dialConfig) (*ethclient.Client, error) {
+	options := make([]rpc.ClientOption, 0, len(cfg.Headers)+2)
+	for _, header := range cfg.Headers {
+		options = append(options, rpc.WithHeader(header.Name, header.Value))
+	}
+	if cfg.Transport != nil {
+		options = append(options, rpc.WithHTTPClient(&http.Client{Transport: cfg.Transport}))
+	}
+	if cfg.Proxy != nil {
+		options = append(options, rpc.WithWebsocketDialer(websocket.Dialer{
+			Proxy:           http.ProxyURL(cfg.Proxy),
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		}))
+	}
+
+	rpcClient, err := rpc.DialOptions(ctx, url, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ethclient.NewClient(rpcClient), nil
+}
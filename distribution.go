@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// valueDistribution — распределение сумм переводов одного токена для -value-stats. Суммы — с учётом
+// decimals, если они известны и задан -decimals, иначе сырые; Scaled отличает одно от другого.
+type valueDistribution struct {
+	Token     common.Address `json:"token"`
+	Transfers int            `json:"transfers"`
+	Scaled    bool           `json:"scaled"`
+	Min       string         `json:"min"`
+	Max       string         `json:"max"`
+	Mean      string         `json:"mean"`
+	Median    string         `json:"median"`
+	P90       string         `json:"p90"`
+	P99       string         `json:"p99"`
+	Histogram []valueBin     `json:"histogram"`
+}
+
+// valueBin — переводы с суммой в [From, To): корзины по степеням десяти; у нулевых сумм From и To — "0".
+type valueBin struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Transfers int    `json:"transfers"`
+}
+
+// recordValue запоминает сумму перевода: медиана и перцентили требуют всех значений, поэтому
+// память растёт с числом переводов.
+func (c *transferCounter) recordValue(token common.Address, raw *big.Int) {
+	c.transferValues[token] = append(c.transferValues[token], raw)
+}
+
+// ValueDistributions считает распределение сумм каждого токена; токены с большим числом переводов — первыми.
+// Перцентили берутся по ближайшему рангу.
+func (c *transferCounter) ValueDistributions(ctx context.Context) []valueDistribution {
+	out := make([]valueDistribution, 0, len(c.transferValues))
+	for token, values := range c.transferValues {
+		sorted := append([]*big.Int(nil), values...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) < 0 })
+
+		var decimals uint8
+		scaled := false
+		if c.opts.Decimals {
+			if d, err := c.decimals.Decimals(ctx, token); err == nil {
+				decimals, scaled = d, true
+			}
+		}
+		format := func(raw *big.Rat) string {
+			if scaled {
+				return formatDecimal(new(big.Rat).Quo(raw, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))))
+			}
+			return formatDecimal(raw)
+		}
+		percentile := func(p int) string {
+			rank := (p*len(sorted) + 99) / 100
+			if rank < 1 {
+				rank = 1
+			}
+			return format(new(big.Rat).SetInt(sorted[rank-1]))
+		}
+
+		sum := new(big.Int)
+		for _, v := range sorted {
+			sum.Add(sum, v)
+		}
+		out = append(out, valueDistribution{
+			Token:     token,
+			Transfers: len(sorted),
+			Scaled:    scaled,
+			Min:       format(new(big.Rat).SetInt(sorted[0])),
+			Max:       format(new(big.Rat).SetInt(sorted[len(sorted)-1])),
+			Mean:      format(new(big.Rat).SetInt(meanRaw(sum, len(sorted)))),
+			Median:    percentile(50),
+			P90:       percentile(90),
+			P99:       percentile(99),
+			Histogram: valueHistogram(sorted, int(decimals)),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Transfers != out[j].Transfers {
+			return out[i].Transfers > out[j].Transfers
+		}
+		return out[i].Token.Hex() < out[j].Token.Hex()
+	})
+	return out
+}
+
+// meanRaw — среднее сумм, округлённое до наименьшей единицы токена (половина — вверх): так оно
+// точно в тех же знаках, что и остальные значения, а не обрезано FloatString на 18 знаках.
+func meanRaw(sum *big.Int, n int) *big.Int {
+	count := big.NewInt(int64(n))
+	mean := new(big.Int).Add(new(big.Int).Lsh(sum, 1), count)
+	return mean.Quo(mean, count.Lsh(count, 1))
+}
+
+// valueHistogram раскладывает отсортированные суммы по степеням десяти: порядок суммы с decimals
+// знаками после запятой — число цифр сырого значения минус decimals.
+func valueHistogram(sorted []*big.Int, decimals int) []valueBin {
+	var bins []valueBin
+	last := 0
+	for _, v := range sorted {
+		if v.Sign() == 0 {
+			if len(bins) == 0 {
+				bins = append(bins, valueBin{From: "0", To: "0"})
+			}
+			bins[0].Transfers++
+			continue
+		}
+		exponent := len(v.String()) - 1 - decimals
+		if len(bins) == 0 || bins[len(bins)-1].From == "0" || exponent != last {
+			bins = append(bins, valueBin{From: powerOfTen(exponent), To: powerOfTen(exponent + 1)})
+			last = exponent
+		}
+		bins[len(bins)-1].Transfers++
+	}
+	return bins
+}
+
+// powerOfTen — 10^exponent десятичной строкой, в том числе для отрицательных степеней.
+func powerOfTen(exponent int) string {
+	if exponent >= 0 {
+		return "1" + strings.Repeat("0", exponent)
+	}
+	return "0." + strings.Repeat("0", -exponent-1) + "1"
+}
+
+// writeValueStats печатает распределения после рейтинга; в -format report они входят в JSON.
+func writeValueStats(w io.Writer, distributions []valueDistribution, opts outputOptions) error {
+	if len(distributions) > topN {
+		distributions = distributions[:topN]
+	}
+
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Token", "Transfers", "Min", "Median", "Mean", "P90", "P99", "Max"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---", "---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, d := range distributions {
+			if err := writeMarkdownRow(w, []string{opts.Tokens.Label(d.Token), fmt.Sprint(d.Transfers), d.Min, d.Median, d.Mean, d.P90, d.P99, d.Max}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, d := range distributions {
+		unit := ""
+		if !d.Scaled {
+			unit = " raw"
+		}
+		if _, err := fmt.Fprintf(w, "token %v: %d transfers, min %s, median %s, mean %s, p90 %s, p99 %s, max %s%s\n",
+			opts.Tokens.Label(d.Token), d.Transfers, d.Min, d.Median, d.Mean, d.P90, d.P99, d.Max, unit); err != nil {
+			return err
+		}
+		for _, bin := range d.Histogram {
+			label := bin.From + "-" + bin.To
+			if bin.From == "0" {
+				label = "0"
+			}
+			if _, err := fmt.Fprintf(w, "  %s: %d\n", label, bin.Transfers); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestMeanRaw(t *testing.T) {
+	tests := []struct {
+		sum, n int64
+		want   int64
+	}{
+		{4, 3, 1},
+		{3, 2, 2},
+		{5, 3, 2},
+		{10, 4, 3},
+		{0, 5, 0},
+		{9, 3, 3},
+	}
+	for _, tt := range tests {
+		if got := meanRaw(big.NewInt(tt.sum), int(tt.n)); got.Int64() != tt.want {
+			t.Errorf("meanRaw(%d, %d) = %v, want %d", tt.sum, tt.n, got, tt.want)
+		}
+	}
+}
+
+// Среднее 100/3 с двумя decimals — 0.33, а не 0.333333333333333333.
+func TestValueDistributionMean(t *testing.T) {
+	logs := []types.Log{
+		transferLog(testAddress(1), testAddress(2), 10, 1, 0),
+		transferLog(testAddress(1), testAddress(2), 20, 1, 1),
+		transferLog(testAddress(1), testAddress(2), 70, 1, 2),
+	}
+	opts := testScanOptions()
+	opts.ValueStats = true
+	opts.Decimals = true
+	opts.TokenRegistry = map[common.Address]tokenInfo{testToken: {Decimals: 2}}
+	counter := newTransferCounter(nil, opts)
+	if _, err := countLogs(context.Background(), logs, counter); err != nil {
+		t.Fatal(err)
+	}
+
+	distributions := counter.ValueDistributions(context.Background())
+	if len(distributions) != 1 {
+		t.Fatalf("got %d distributions, want 1", len(distributions))
+	}
+	d := distributions[0]
+	if d.Mean != "0.33" || d.Min != "0.1" || d.Median != "0.2" || d.Max != "0.7" {
+		t.Errorf("distribution = %+v, want mean 0.33, min 0.1, median 0.2, max 0.7", d)
+	}
+}
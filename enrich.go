@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// enrichPool ограничивает число одновременных RPC-запросов обогащения
+// (decimals, квитанции, отправители, ENS) и, опционально, их частоту.
+type enrichPool struct {
+	concurrency int
+	interval    time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newEnrichPool(concurrency int, requestsPerSecond float64) *enrichPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	p := &enrichPool{concurrency: concurrency}
+	if requestsPerSecond > 0 {
+		p.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return p
+}
+
+// wait выдерживает общий для всех воркеров интервал между запросами.
+func (p *enrichPool) wait(ctx context.Context) error {
+	if p.interval == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	delay := p.next.Sub(now)
+	p.next = p.next.Add(p.interval)
+	p.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run вызывает fn для i в [0, n) не более чем в concurrency горутин и возвращает первую ошибку.
+// Если ctx отменён раньше, чем все i отданы в работу, возвращается ctx.Err(): иначе пропущенные
+// задачи выглядели бы выполненными.
+func (p *enrichPool) Run(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	workers := p.concurrency
+	if workers > n {
+		workers = n
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := p.wait(ctx)
+				if err == nil {
+					err = fn(ctx, i)
+				}
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr == nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// prefetchBatchSize — сколько заголовков или квитанций Prefetch запрашивает в одном JSON-RPC батче.
+const prefetchBatchSize = 100
+
+// Prefetch параллельно заполняет кэши decimals, квитанций и отправителей для пачки логов,
+// чтобы последующий Add обходился без RPC.
+func (c *transferCounter) Prefetch(ctx context.Context, logs []types.Log) error {
+	var tokens []common.Address
+	if c.opts.Decimals && len(c.opts.ApprovalSpenders) == 0 {
+		seen := make(map[common.Address]bool)
+		for _, vLog := range logs {
+			if !seen[vLog.Address] {
+				seen[vLog.Address] = true
+				tokens = append(tokens, vLog.Address)
+			}
+		}
+	}
+
+	// Для -parquet-dir каждому блоку нужен заголовок со временем.
+	var blocks []uint64
+	if c.opts.Export != nil {
+		seen := make(map[uint64]bool)
+		for _, vLog := range logs {
+			if !seen[vLog.BlockNumber] {
+				seen[vLog.BlockNumber] = true
+				blocks = append(blocks, vLog.BlockNumber)
+			}
+		}
+	}
+	if err := c.headers.Prefetch(ctx, c.pool, blocks); err != nil {
+		return err
+	}
+
+	var txLogs []types.Log
+	var hashes []common.Hash
+	if c.opts.SuccessfulOnly || c.opts.ByTxSender {
+		seen := make(map[common.Hash]bool)
+		for _, vLog := range logs {
+			if !seen[vLog.TxHash] {
+				seen[vLog.TxHash] = true
+				txLogs = append(txLogs, vLog)
+				hashes = append(hashes, vLog.TxHash)
+			}
+		}
+	}
+	if c.opts.SuccessfulOnly {
+		if err := c.receipts.Prefetch(ctx, c.pool, hashes); err != nil {
+			return err
+		}
+	}
+
+	err := c.pool.Run(ctx, len(tokens), func(ctx context.Context, i int) error {
+		// Ошибки decimals запоминаются в кэше и выводятся предупреждением.
+		_, _ = c.decimals.Decimals(ctx, tokens[i])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !c.opts.ByTxSender {
+		return nil
+	}
+	return c.pool.Run(ctx, len(txLogs), func(ctx context.Context, i int) error {
+		// Отправители неуспешных транзакций с -successful-only не нужны: статус уже в кэше.
+		if c.opts.SuccessfulOnly {
+			successful, err := c.receipts.Successful(ctx, txLogs[i].TxHash)
+			if err != nil || !successful {
+				return err
+			}
+		}
+		_, err := c.senders.Sender(ctx, txLogs[i])
+		return err
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestEnrichPoolRunsEveryJob(t *testing.T) {
+	pool := newEnrichPool(4, 0)
+	var calls atomic.Int64
+	seen := make([]atomic.Bool, 100)
+	err := pool.Run(context.Background(), len(seen), func(ctx context.Context, i int) error {
+		calls.Add(1)
+		seen[i].Store(true)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if calls.Load() != int64(len(seen)) {
+		t.Errorf("fn called %d times, want %d", calls.Load(), len(seen))
+	}
+	for i := range seen {
+		if !seen[i].Load() {
+			t.Errorf("job %d was not run", i)
+		}
+	}
+}
+
+func TestEnrichPoolFirstError(t *testing.T) {
+	pool := newEnrichPool(2, 0)
+	want := errors.New("node error")
+	err := pool.Run(context.Background(), 50, func(ctx context.Context, i int) error {
+		if i == 3 {
+			return want
+		}
+		return nil
+	})
+	if !errors.Is(err, want) {
+		t.Errorf("Run = %v, want %v", err, want)
+	}
+}
+
+// Отменённый контекст без ошибок fn не должен выглядеть успешным прогоном: часть задач не запускалась.
+func TestEnrichPoolCancelled(t *testing.T) {
+	pool := newEnrichPool(1, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls atomic.Int64
+	err := pool.Run(ctx, 100, func(ctx context.Context, i int) error {
+		if calls.Add(1) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v after %d of 100 jobs, want context.Canceled", err, calls.Load())
+	}
+}
+
+func BenchmarkEnrichPool(b *testing.B) {
+	for _, workers := range []int{1, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			pool := newEnrichPool(workers, 0)
+			var sum atomic.Int64
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := pool.Run(context.Background(), 1000, func(ctx context.Context, i int) error {
+					sum.Add(int64(i))
+					return nil
+				}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var ensRegistryAddress = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// ensBatchSize — сколько eth_call уходит в одном JSON-RPC батче; провайдеры ограничивают размер батча.
+const ensBatchSize = 100
+
+const ensABI = `[
+{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"resolver","outputs":[{"name":"","type":"address"}],"type":"function"},
+{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"name","outputs":[{"name":"","type":"string"}],"type":"function"},
+{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"addr","outputs":[{"name":"","type":"address"}],"type":"function"}
+]`
+
+var ensContractABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(ensABI))
+	if err != nil {
+		panic(fmt.Sprintf("failed in marshall ens abi: %v", err))
+	}
+	return parsed
+}()
+
+func namehash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		labelHash := crypto.Keccak256([]byte(labels[i]))
+		node = crypto.Keccak256Hash(node.Bytes(), labelHash)
+	}
+	return node
+}
+
+// defaultENSCachePath — файл кэша -ens-cache по умолчанию рядом с кэшем токенов.
+func defaultENSCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "getblock", "ens.json")
+}
+
+// ensEntry — результат обратного поиска; пустое имя — у адреса нет подтверждённой записи.
+type ensEntry struct {
+	Name      string    `json:"name,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// ensResolver делает обратный поиск ENS-имён батчами eth_call и кэширует результат,
+// включая отсутствие имени, в файле между запусками; записи старше ttl проверяются заново.
+type ensResolver struct {
+	client *ethclient.Client
+	path   string
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	names map[common.Address]ensEntry
+	dirty bool
+}
+
+func newENSResolver(client *ethclient.Client, path string, ttl time.Duration) (*ensResolver, error) {
+	r := &ensResolver{
+		client: client,
+		path:   path,
+		ttl:    ttl,
+		names:  make(map[common.Address]ensEntry),
+	}
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read ENS cache: %w", err)
+	default:
+		if err := json.Unmarshal(data, &r.names); err != nil {
+			return nil, fmt.Errorf("failed to parse ENS cache %s: %w", path, err)
+		}
+	}
+	return r, nil
+}
+
+// Names возвращает подтверждённые имена адресов: из кэша или одним проходом батчей для остальных.
+// Адреса, поиск которых упал на ошибке RPC, в результат и кэш не попадают.
+func (r *ensResolver) Names(ctx context.Context, addresses []common.Address) map[common.Address]string {
+	names := make(map[common.Address]string, len(addresses))
+	var missing []common.Address
+
+	r.mu.Lock()
+	for _, address := range addresses {
+		entry, ok := r.names[address]
+		if ok && (r.ttl <= 0 || time.Since(entry.CheckedAt) < r.ttl) {
+			names[address] = entry.Name
+			continue
+		}
+		missing = append(missing, address)
+	}
+	r.mu.Unlock()
+
+	if len(missing) == 0 {
+		return names
+	}
+
+	resolved, err := r.lookup(ctx, missing)
+	if err != nil {
+		logger.Warn("ENS lookup failed", "err", err)
+	}
+
+	now := time.Now().UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for address, name := range resolved {
+		names[address] = name
+		r.names[address] = ensEntry{Name: name, CheckedAt: now}
+		r.dirty = true
+	}
+	return names
+}
+
+// Save записывает кэш, если появились новые записи.
+func (r *ensResolver) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.path == "" || !r.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.names, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ENS cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create ENS cache directory: %w", err)
+	}
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to write ENS cache: %w", err)
+	}
+	r.dirty = false
+	return nil
+}
+
+// ensCall — один eth_call батча: метод ENS-контракта с аргументом node.
+type ensCall struct {
+	address  common.Address
+	contract common.Address
+	method   string
+	node     common.Hash
+	output   hexutil.Bytes
+	err      error
+}
+
+// lookup проходит цепочку обратного поиска для всех адресов сразу, по батчу на шаг:
+// resolver обратной записи, name, resolver найденного имени и его addr.
+// Обратная запись не подтверждена владельцем имени, пока прямое разрешение не вернёт тот же адрес.
+func (r *ensResolver) lookup(ctx context.Context, addresses []common.Address) (map[common.Address]string, error) {
+	names := make(map[common.Address]string, len(addresses))
+
+	reverse := make([]*ensCall, len(addresses))
+	for i, address := range addresses {
+		node := namehash(hex.EncodeToString(address.Bytes()) + ".addr.reverse")
+		reverse[i] = &ensCall{address: address, contract: ensRegistryAddress, method: "resolver", node: node}
+	}
+	if err := r.batch(ctx, reverse); err != nil {
+		return nil, err
+	}
+
+	var nameCalls []*ensCall
+	for _, call := range reverse {
+		if resolver, ok := r.result(call, names).(common.Address); ok {
+			nameCalls = append(nameCalls, &ensCall{address: call.address, contract: resolver, method: "name", node: call.node})
+		}
+	}
+	if err := r.batch(ctx, nameCalls); err != nil {
+		return nil, err
+	}
+
+	var forward []*ensCall
+	claimed := make(map[common.Address]string)
+	for _, call := range nameCalls {
+		if name, ok := r.result(call, names).(string); ok {
+			claimed[call.address] = name
+			forward = append(forward, &ensCall{address: call.address, contract: ensRegistryAddress, method: "resolver", node: namehash(name)})
+		}
+	}
+	if err := r.batch(ctx, forward); err != nil {
+		return nil, err
+	}
+
+	var addrCalls []*ensCall
+	for _, call := range forward {
+		if resolver, ok := r.result(call, names).(common.Address); ok {
+			addrCalls = append(addrCalls, &ensCall{address: call.address, contract: resolver, method: "addr", node: call.node})
+		}
+	}
+	if err := r.batch(ctx, addrCalls); err != nil {
+		return nil, err
+	}
+
+	for _, call := range addrCalls {
+		if resolved, ok := r.result(call, names).(common.Address); ok && resolved == call.address {
+			names[call.address] = claimed[call.address]
+		}
+	}
+	return names, nil
+}
+
+// result разбирает ответ шага. Пустой или нулевой результат завершает поиск адреса без имени
+// (names[address] = ""); при ошибке вызова адрес убирается из names, чтобы он проверился в следующий раз.
+func (r *ensResolver) result(call *ensCall, names map[common.Address]string) interface{} {
+	if call.err != nil {
+		logger.Warn("ENS lookup failed", "method", call.method, "address", call.address.Hex(), "err", call.err)
+		delete(names, call.address)
+		return nil
+	}
+
+	names[call.address] = ""
+	if len(call.output) == 0 {
+		return nil
+	}
+	values, err := ensContractABI.Unpack(call.method, call.output)
+	if err != nil || len(values) != 1 {
+		logger.Warn("failed to unpack ENS result", "method", call.method, "contract", call.contract.Hex(), "err", err)
+		return nil
+	}
+
+	switch value := values[0].(type) {
+	case common.Address:
+		if value == (common.Address{}) {
+			return nil
+		}
+		return value
+	case string:
+		if value == "" {
+			return nil
+		}
+		return value
+	}
+	return nil
+}
+
+// batch отправляет вызовы JSON-RPC батчами по ensBatchSize; ошибка отдельного вызова
+// сохраняется в call.err, ошибка всего батча возвращается.
+func (r *ensResolver) batch(ctx context.Context, calls []*ensCall) error {
+	for start := 0; start < len(calls); start += ensBatchSize {
+		end := start + ensBatchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		elems := make([]rpc.BatchElem, 0, end-start)
+		for _, call := range calls[start:end] {
+			input, err := ensContractABI.Pack(call.method, call.node)
+			if err != nil {
+				return fmt.Errorf("failed to pack ENS %s call: %w", call.method, err)
+			}
+			elems = append(elems, rpc.BatchElem{
+				Method: "eth_call",
+				Args:   []interface{}{map[string]interface{}{"to": call.contract, "data": hexutil.Bytes(input)}, "latest"},
+				Result: &call.output,
+			})
+		}
+
+		if err := r.client.Client().BatchCallContext(ctx, elems); err != nil {
+			return fmt.Errorf("failed to send ENS batch: %w", err)
+		}
+		for i, elem := range elems {
+			calls[start+i].err = elem.Error
+		}
+	}
+	return nil
+}
+
+func resolveNames(ctx context.Context, resolver *ensResolver, metrics []Metric) {
+	n := topN
+	if len(metrics) < n {
+		n = len(metrics)
+	}
+
+	var addresses []common.Address
+	for _, m := range metrics[:n] {
+		if m.Group == "" && m.Name == "" {
+			addresses = append(addresses, m.Address)
+		}
+	}
+
+	names := resolver.Names(ctx, addresses)
+	for i := range metrics[:n] {
+		if metrics[i].Group == "" && metrics[i].Name == "" {
+			metrics[i].Name = names[metrics[i].Address]
+		}
+	}
+	if err := resolver.Save(); err != nil {
+		logger.Warn(err.Error())
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// EventMetric — сводка по одному событию ABI: сколько логов пришло, от скольких контрактов,
+// сколько различных индексированных адресов в них участвовало и суммы параметров -event-sum.
+type EventMetric struct {
+	Event     string
+	Logs      int
+	Contracts int
+	Addresses int
+	Sums      []EventSum
+}
+
+type EventSum struct {
+	Param string
+	Total *big.Int
+}
+
+type eventStats struct {
+	logs      int
+	contracts map[common.Address]struct{}
+	addresses map[common.Address]struct{}
+	sums      map[string]*big.Int
+}
+
+// countEventStats обновляет сводку события для -top-events.
+func (c *transferCounter) countEventStats(event abi.Event, vLog types.Log, addresses []common.Address) {
+	stats, ok := c.eventStats[event.Sig]
+	if !ok {
+		stats = &eventStats{
+			contracts: make(map[common.Address]struct{}),
+			addresses: make(map[common.Address]struct{}),
+			sums:      make(map[string]*big.Int),
+		}
+		c.eventStats[event.Sig] = stats
+	}
+
+	stats.logs++
+	stats.contracts[vLog.Address] = struct{}{}
+	for _, address := range addresses {
+		if address != (common.Address{}) || c.opts.CountZero {
+			stats.addresses[address] = struct{}{}
+		}
+	}
+
+	params := c.opts.Events.sums[event.Name]
+	if len(params) == 0 {
+		return
+	}
+	values, err := eventIntegers(event, vLog)
+	if err != nil {
+		c.stats.IncUnpackFailure()
+		return
+	}
+	for _, param := range params {
+		total, ok := stats.sums[param]
+		if !ok {
+			total = new(big.Int)
+			stats.sums[param] = total
+		}
+		total.Add(total, values[param])
+	}
+}
+
+// eventIntegers достаёт целочисленные параметры события: индексированные из топиков, остальные из data.
+func eventIntegers(event abi.Event, vLog types.Log) (map[string]*big.Int, error) {
+	values := make(map[string]*big.Int)
+
+	topic := 1
+	for _, input := range event.Inputs {
+		if !input.Indexed {
+			continue
+		}
+		switch input.Type.T {
+		case abi.UintTy:
+			values[input.Name] = vLog.Topics[topic].Big()
+		case abi.IntTy:
+			values[input.Name] = math.S256(vLog.Topics[topic].Big())
+		}
+		topic++
+	}
+
+	unpacked := make(map[string]interface{})
+	if err := event.Inputs.NonIndexed().UnpackIntoMap(unpacked, vLog.Data); err != nil {
+		return nil, fmt.Errorf("unpack %s in tx %s: %w", event.Name, vLog.TxHash.Hex(), err)
+	}
+	for name, value := range unpacked {
+		switch v := value.(type) {
+		case *big.Int:
+			values[name] = v
+		default:
+			// uint8..uint64 и int8..int64 abi распаковывает в родные типы Go.
+			rv := reflect.ValueOf(value)
+			switch rv.Kind() {
+			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				values[name] = new(big.Int).SetUint64(rv.Uint())
+			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				values[name] = big.NewInt(rv.Int())
+			}
+		}
+	}
+	return values, nil
+}
+
+func (c *transferCounter) EventMetrics() []EventMetric {
+	events := make([]EventMetric, 0, len(c.eventStats))
+	for sig, stats := range c.eventStats {
+		m := EventMetric{Event: sig, Logs: stats.logs, Contracts: len(stats.contracts), Addresses: len(stats.addresses)}
+		name, _, _ := strings.Cut(sig, "(")
+		for _, param := range c.opts.Events.sums[name] {
+			total := stats.sums[param]
+			if total == nil {
+				total = new(big.Int)
+			}
+			m.Sums = append(m.Sums, EventSum{Param: param, Total: total})
+		}
+		events = append(events, m)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Logs != events[j].Logs {
+			return events[i].Logs > events[j].Logs
+		}
+		return events[i].Event < events[j].Event
+	})
+	return events
+}
+
+func (m EventMetric) sumsText() string {
+	parts := make([]string, len(m.Sums))
+	for i, sum := range m.Sums {
+		parts[i] = sum.Param + " " + sum.Total.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeEventMetrics печатает сводку по всем событиям после рейтинга адресов.
+func writeEventMetrics(w io.Writer, events []EventMetric, opts outputOptions) error {
+	if opts.AddressesOnly {
+		return nil
+	}
+
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Event", "Logs", "Contracts", "Unique addresses", "Sums"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := writeMarkdownRow(w, []string{e.Event, fmt.Sprint(e.Logs), fmt.Sprint(e.Contracts), fmt.Sprint(e.Addresses), e.sumsText()}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, e := range events {
+		line := fmt.Sprintf("event %v was emitted %v times by %v contracts with %v unique addresses", e.Event, e.Logs, e.Contracts, e.Addresses)
+		if len(e.Sums) > 0 {
+			line += "; sum of " + e.sumsText()
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"getBlock/metric"
+)
+
+// explainTransaction печатает подробный разбор всех Transfer логов транзакции.
+func explainTransaction(ctx context.Context, client *ethclient.Client, w io.Writer, txHash common.Hash) error {
+	receipt, err := client.TransactionReceipt(ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("failed to fetch receipt of %s: %w", txHash.Hex(), err)
+	}
+
+	fmt.Fprintf(w, "transaction %s\n", txHash.Hex())
+	fmt.Fprintf(w, "  block:   %v\n", receipt.BlockNumber)
+	fmt.Fprintf(w, "  status:  %d\n", receipt.Status)
+	fmt.Fprintf(w, "  logs:    %d\n", len(receipt.Logs))
+
+	found := 0
+	for _, vLog := range receipt.Logs {
+		transferEvent, err := metric.DecodeTransfer(*vLog)
+		if err != nil {
+			if len(vLog.Topics) > 0 && vLog.Topics[0] == metric.TransferEventHash {
+				fmt.Fprintf(w, "\nlog #%d: Transfer topic but not decodable as ERC20: %v\n", vLog.Index, err)
+			}
+			continue
+		}
+
+		found++
+		fmt.Fprintf(w, "\nlog #%d: ERC20 Transfer\n", vLog.Index)
+		fmt.Fprintf(w, "  token:   %s\n", vLog.Address.Hex())
+		fmt.Fprintf(w, "  from:    %s\n", transferEvent.From.Hex())
+		fmt.Fprintf(w, "  to:      %s\n", transferEvent.To.Hex())
+		fmt.Fprintf(w, "  value:   %s\n", transferEvent.Value)
+		fmt.Fprintf(w, "  block:   %d\n", vLog.BlockNumber)
+	}
+
+	if found == 0 {
+		fmt.Fprintln(w, "\nno ERC20 Transfer logs in this transaction")
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// healthCheckBody — лёгкий запрос, которым проверяется, что отключённый эндпоинт снова отвечает.
+const healthCheckBody = `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`
+
+// parseEndpoints разбирает -rpc-url: один URL или несколько через запятую.
+// Несколько эндпоинтов поддерживаются только для HTTP(S): подписки -watch идут через -ws-url.
+func parseEndpoints(raw string) ([]string, error) {
+	var endpoints []string
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			endpoints = append(endpoints, item)
+		}
+	}
+	if len(endpoints) < 2 {
+		return endpoints, nil
+	}
+
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("malformed RPC URL %s: %w", redactURL(endpoint), err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("RPC URL %s: failover between several endpoints needs http(s) URLs", redactURL(endpoint))
+		}
+	}
+	return endpoints, nil
+}
+
+type endpointState struct {
+	url     *url.URL
+	raw     string
+	healthy bool
+	// transport — next, а с -breaker-threshold — свой предохранитель эндпоинта поверх него.
+	transport http.RoundTripper
+}
+
+// failoverTransport — http.RoundTripper, который раздаёт запросы по кругу между эндпоинтами
+// -rpc-url и при сетевой ошибке, 429 или 5xx повторяет запрос на следующем. Упавший эндпоинт
+// исключается из ротации, пока фоновая проверка не получит от него ответ. С breakerThreshold > 0
+// у каждого эндпоинта свой circuitBreaker: падение одного провайдера не размыкает остальные.
+type failoverTransport struct {
+	next    http.RoundTripper
+	headers []rpcHeader
+
+	cursor atomic.Uint64
+
+	mu        sync.Mutex
+	endpoints []*endpointState
+}
+
+func newFailoverTransport(next http.RoundTripper, endpoints []string, headers []rpcHeader, breakerThreshold int, breakerCooldown time.Duration) (*failoverTransport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	t := &failoverTransport{next: next, headers: headers}
+	for _, raw := range endpoints {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("malformed RPC URL %s: %w", redactURL(raw), err)
+		}
+		endpoint := &endpointState{url: u, raw: raw, healthy: true, transport: next}
+		if breakerThreshold > 0 {
+			endpoint.transport = newCircuitBreaker(next, breakerThreshold, breakerCooldown)
+		}
+		t.endpoints = append(t.endpoints, endpoint)
+	}
+	return t, nil
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Тело нужно перечитывать при повторе на другом эндпоинте.
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for _, endpoint := range t.order() {
+		attempt := req.Clone(req.Context())
+		attempt.URL = endpoint.url
+		attempt.Host = endpoint.url.Host
+		attempt.Body = io.NopCloser(bytes.NewReader(body))
+		attempt.ContentLength = int64(len(body))
+
+		resp, err := endpoint.transport.RoundTrip(attempt)
+		switch {
+		case err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+			return nil, err
+		case errors.Is(err, ErrCircuitOpen):
+			// Предохранитель уже учёл отказы эндпоинта: просто идём к следующему.
+			lastErr = fmt.Errorf("%s: %w", redactURL(endpoint.raw), err)
+			continue
+		case err != nil:
+			lastErr = err
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			lastErr = fmt.Errorf("%s: %s", redactURL(endpoint.raw), resp.Status)
+			if !t.hasAlternative(endpoint) {
+				// Последний вариант: отдаём ответ как есть, rpc-клиент сам разберёт ошибку.
+				t.markDown(endpoint, lastErr)
+				return resp, nil
+			}
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+		t.markDown(endpoint, lastErr)
+	}
+	return nil, fmt.Errorf("all RPC endpoints failed, last error: %w", lastErr)
+}
+
+// order — эндпоинты для одного запроса: здоровые по кругу начиная со следующего, затем отключённые
+// (если упали все, пробуем их всё равно). Эндпоинты с разомкнутым предохранителем идут последними
+// и отказывают сразу, не дожидаясь сети.
+func (t *failoverTransport) order() []*endpointState {
+	start := int(t.cursor.Add(1)-1) % len(t.endpoints)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	healthy := make([]*endpointState, 0, len(t.endpoints))
+	var down, open []*endpointState
+	for i := range t.endpoints {
+		endpoint := t.endpoints[(start+i)%len(t.endpoints)]
+		switch {
+		case endpoint.breakerOpen():
+			open = append(open, endpoint)
+		case endpoint.healthy:
+			healthy = append(healthy, endpoint)
+		default:
+			down = append(down, endpoint)
+		}
+	}
+	return append(append(healthy, down...), open...)
+}
+
+func (e *endpointState) breakerOpen() bool {
+	breaker, ok := e.transport.(*circuitBreaker)
+	return ok && breaker.Open()
+}
+
+func (t *failoverTransport) hasAlternative(current *endpointState) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, endpoint := range t.endpoints {
+		if endpoint != current && endpoint.healthy && !endpoint.breakerOpen() {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *failoverTransport) markDown(endpoint *endpointState, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if endpoint.healthy {
+		logger.Warn("RPC endpoint failed, switching to the next one", "endpoint", redactURL(endpoint.raw), "err", err)
+	}
+	endpoint.healthy = false
+}
+
+func (t *failoverTransport) markUp(endpoint *endpointState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !endpoint.healthy {
+		logger.Info("RPC endpoint is healthy again", "endpoint", redactURL(endpoint.raw))
+	}
+	endpoint.healthy = true
+}
+
+// checkHealth каждые interval проверяет отключённые эндпоинты и возвращает ответившие в ротацию.
+func (t *failoverTransport) checkHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		t.mu.Lock()
+		var down []*endpointState
+		for _, endpoint := range t.endpoints {
+			if !endpoint.healthy {
+				down = append(down, endpoint)
+			}
+		}
+		t.mu.Unlock()
+
+		for _, endpoint := range down {
+			if err := t.probe(ctx, endpoint, interval); err != nil {
+				t.markDown(endpoint, err)
+				continue
+			}
+			t.markUp(endpoint)
+		}
+	}
+}
+
+func (t *failoverTransport) probe(ctx context.Context, endpoint *endpointState, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.raw, strings.NewReader(healthCheckBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range t.headers {
+		req.Header.Set(header.Name, header.Value)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFailoverSkipsOpenBreakers(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	down := map[string]bool{"a.invalid": true}
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[req.URL.Host]++
+		if down[req.URL.Host] {
+			return statusResponse(http.StatusBadGateway), nil
+		}
+		return statusResponse(http.StatusOK), nil
+	})
+	failover, err := newFailoverTransport(next, []string{"http://a.invalid", "http://b.invalid"}, nil, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func() error {
+		req, _ := http.NewRequest(http.MethodPost, "http://rpc.invalid", strings.NewReader("{}"))
+		resp, err := failover.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := send(); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	// Проверка здоровья вернула a в ротацию, но его предохранитель ещё разомкнут.
+	failover.markUp(failover.endpoints[0])
+	for i := 0; i < 4; i++ {
+		if err := send(); err != nil {
+			t.Fatalf("request %d after the health check: %v", i, err)
+		}
+	}
+	if calls["a.invalid"] != 1 {
+		t.Errorf("endpoint a was called %d times, want 1: its breaker is open", calls["a.invalid"])
+	}
+	if calls["b.invalid"] != 6 {
+		t.Errorf("endpoint b served %d requests, want 6", calls["b.invalid"])
+	}
+
+	// Разомкнуты все: запрос отказывает сразу с ErrCircuitOpen.
+	down["b.invalid"] = true
+	send()
+	before := calls["a.invalid"] + calls["b.invalid"]
+	if err := send(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if after := calls["a.invalid"] + calls["b.invalid"]; after != before {
+		t.Errorf("%d requests reached endpoints behind open breakers", after-before)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+// feedRecord — одна строка NDJSON ленты -follow-logs.
+type feedRecord struct {
+	Block    uint64 `json:"block"`
+	TxHash   string `json:"tx_hash"`
+	LogIndex uint   `json:"log_index"`
+	Token    string `json:"token"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+}
+
+// newFeedRecord — запись перевода в общем виде -follow-logs, -nats-url и -kafka-brokers.
+func newFeedRecord(vLog types.Log, transferEvent metric.TransferEvents) feedRecord {
+	return feedRecord{
+		Block:    vLog.BlockNumber,
+		TxHash:   vLog.TxHash.Hex(),
+		LogIndex: vLog.Index,
+		Token:    vLog.Address.Hex(),
+		From:     transferEvent.From.Hex(),
+		To:       transferEvent.To.Hex(),
+		Value:    transferEvent.Value.String(),
+	}
+}
+
+// transferFeed дописывает каждый разобранный перевод в файл по строке NDJSON.
+// Когда файл превышает maxSize байт, он переименовывается в path+".1" (прежний .1
+// перезаписывается) и запись продолжается в новый файл.
+type transferFeed struct {
+	path    string
+	maxSize int64
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func openTransferFeed(path string, maxSize int64) (*transferFeed, error) {
+	feed := &transferFeed{path: path, maxSize: maxSize}
+	if err := feed.open(); err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (t *transferFeed) open() error {
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open -follow-logs file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat -follow-logs file: %w", err)
+	}
+	t.f, t.size = f, info.Size()
+	return nil
+}
+
+func (t *transferFeed) rotate() error {
+	if err := t.f.Close(); err != nil {
+		return fmt.Errorf("failed to close -follow-logs file: %w", err)
+	}
+	if err := os.Rename(t.path, t.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate -follow-logs file: %w", err)
+	}
+	return t.open()
+}
+
+func (t *transferFeed) Write(vLog types.Log, transferEvent metric.TransferEvents) error {
+	line, err := json.Marshal(newFeedRecord(vLog, transferEvent))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.maxSize > 0 && t.size > 0 && t.size+int64(len(line)) > t.maxSize {
+		if err := t.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := t.f.Write(line)
+	t.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write -follow-logs file: %w", err)
+	}
+	return nil
+}
+
+func (t *transferFeed) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.f.Close()
+}
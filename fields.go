@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// metricField — колонка табличного вывода.
+type metricField struct {
+	Name   string
+	Header string
+	Value  func(Metric) string
+}
+
+var metricFields = []metricField{
+	{Name: "chain", Header: "Chain", Value: func(m Metric) string { return m.Chain }},
+	{Name: "address", Header: "Address", Value: func(m Metric) string { return m.Label() }},
+	{Name: "name", Header: "Name", Value: func(m Metric) string { return m.Name }},
+	{Name: "count", Header: "Count", Value: func(m Metric) string { return fmt.Sprint(m.Count) }},
+	{Name: "sent", Header: "Sent count", Value: func(m Metric) string { return fmt.Sprint(m.SentCount) }},
+	{Name: "received", Header: "Received count", Value: func(m Metric) string { return fmt.Sprint(m.ReceivedCount) }},
+	{Name: "score", Header: "Score", Value: func(m Metric) string { return strconv.FormatFloat(m.Score, 'f', 3, 64) }},
+	{Name: "raw_value", Header: "Raw value", Value: func(m Metric) string { return fmt.Sprint(m.RawValue) }},
+	{Name: "value", Header: "Value", Value: func(m Metric) string { return m.Value }},
+	{Name: "inflow", Header: "Received", Value: func(m Metric) string { return fmt.Sprint(m.InflowValue) }},
+	{Name: "sent_value", Header: "Sent", Value: func(m Metric) string { return formatDecimal(m.Sent) }},
+	{Name: "received_value", Header: "Received value", Value: func(m Metric) string { return formatDecimal(m.Received) }},
+	{Name: "usd", Header: "USD", Value: func(m Metric) string { return formatUSD(m.USD) }},
+	{Name: "rate", Header: "Per block", Value: func(m Metric) string { return strconv.FormatFloat(m.Rate, 'f', 3, 64) }},
+	{Name: "txs", Header: "Transactions", Value: func(m Metric) string { return fmt.Sprint(m.Txs) }},
+	{Name: "gas_used", Header: "Gas used", Value: func(m Metric) string { return fmt.Sprint(m.GasUsed) }},
+	{Name: "fee", Header: "Fee", Value: func(m Metric) string { return formatDecimal(m.Fee) }},
+	{Name: "per_tx", Header: "Per tx", Value: func(m Metric) string { return strconv.FormatFloat(m.PerTx, 'f', 2, 64) }},
+}
+
+func lookupField(name string) (metricField, bool) {
+	for _, field := range metricFields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return metricField{}, false
+}
+
+// parseFields разбирает -fields; пустая строка означает набор колонок по умолчанию.
+func parseFields(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := lookupField(name); !ok {
+			known := make([]string, len(metricFields))
+			for i, field := range metricFields {
+				known[i] = field.Name
+			}
+			return nil, fmt.Errorf("unknown field %q: expected one of %s", name, strings.Join(known, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("field %q is listed twice", name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (opts outputOptions) fields() []metricField {
+	names := opts.Fields
+	if len(names) == 0 {
+		names = []string{"address"}
+		if opts.ENS {
+			names = append(names, "name")
+		}
+		names = append(names, "count")
+		if opts.Sides {
+			names = append(names, "sent", "received")
+		}
+		if opts.Decay {
+			names = append(names, "score")
+		}
+		if opts.Decimals {
+			names = append(names, "raw_value", "value")
+		}
+		if opts.Inflow {
+			names = append(names, "inflow")
+		}
+		if opts.Volume {
+			names = append(names, "sent_value", "received_value")
+		}
+		if opts.USD {
+			names = append(names, "usd")
+		}
+		if opts.Rate {
+			names = append(names, "rate")
+		}
+		if opts.TxCounts {
+			names = append(names, "txs", "per_tx")
+		}
+		if opts.Gas {
+			names = append(names, "gas_used", "fee")
+		}
+	}
+
+	fields := make([]metricField, 0, len(names))
+	for _, name := range names {
+		field, _ := lookupField(name)
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func hasField(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
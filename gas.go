@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	sortFee = "fee"
+
+	// gasBatchSize — сколько eth_getTransactionReceipt уходит в одном JSON-RPC батче.
+	gasBatchSize = 100
+	// nativeDecimals — decimals нативной монеты сети, в которой платится газ.
+	nativeDecimals = 18
+)
+
+// nativeSymbols — символ монеты газа для -chain; для остальных сетей — ETH.
+var nativeSymbols = map[string]string{
+	"polygon": "POL",
+	"bsc":     "BNB",
+}
+
+func nativeSymbol(chain chainInfo) string {
+	if symbol, ok := nativeSymbols[chain.Name]; ok {
+		return symbol
+	}
+	return "ETH"
+}
+
+// gasReceipt — поля квитанции, нужные -gas. types.Receipt не содержит from, поэтому
+// ответ eth_getTransactionReceipt разбирается напрямую.
+type gasReceipt struct {
+	From              common.Address `json:"from"`
+	GasUsed           hexutil.Uint64 `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big   `json:"effectiveGasPrice"`
+}
+
+// gasCache запрашивает квитанции батчами в -enrich-concurrency горутин и запоминает их по хэшу.
+type gasCache struct {
+	client *ethclient.Client
+	stats  *Stats
+
+	mu       sync.Mutex
+	receipts map[common.Hash]*gasReceipt
+}
+
+func newGasCache(client *ethclient.Client, stats *Stats) *gasCache {
+	return &gasCache{client: client, stats: stats, receipts: make(map[common.Hash]*gasReceipt)}
+}
+
+func (c *gasCache) fetch(ctx context.Context, pool *enrichPool, hashes []common.Hash) error {
+	var missing []common.Hash
+	c.mu.Lock()
+	for _, hash := range hashes {
+		if _, ok := c.receipts[hash]; ok {
+			c.stats.IncCacheHit()
+		} else {
+			missing = append(missing, hash)
+		}
+	}
+	c.mu.Unlock()
+
+	batches := (len(missing) + gasBatchSize - 1) / gasBatchSize
+	return pool.Run(ctx, batches, func(ctx context.Context, i int) error {
+		start := i * gasBatchSize
+		end := start + gasBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		receipts := make([]*gasReceipt, end-start)
+		elems := make([]rpc.BatchElem, end-start)
+		for j, hash := range missing[start:end] {
+			elems[j] = rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &receipts[j]}
+		}
+		if err := c.client.Client().BatchCallContext(ctx, elems); err != nil {
+			c.stats.IncRPCError()
+			return fmt.Errorf("failed to send receipts batch: %w", err)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for j, elem := range elems {
+			if elem.Error != nil {
+				c.stats.IncRPCError()
+				return fmt.Errorf("failed to fetch receipt of %s: %w", missing[start+j].Hex(), elem.Error)
+			}
+			// Квитанции нет (транзакция снята реорганизацией): запоминаем, чтобы не спрашивать снова.
+			c.receipts[missing[start+j]] = receipts[j]
+		}
+		return nil
+	})
+}
+
+// fillGas проставляет строкам rows газ и комиссию транзакций, которые адрес отправил сам
+// (from квитанции), среди транзакций с его переводами. Квитанции запрашиваются только для
+// транзакций строк fetch, то есть напечатанного топа, а с -sort fee — всего рейтинга.
+func (c *transferCounter) fillGas(ctx context.Context, rows, fetch []Metric) error {
+	var hashes []common.Hash
+	seen := make(map[common.Hash]bool)
+	for _, m := range fetch {
+		for hash := range c.txs[m.Address] {
+			if !seen[hash] {
+				seen[hash] = true
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+	if err := c.gas.fetch(ctx, c.pool, hashes); err != nil {
+		return err
+	}
+
+	c.gas.mu.Lock()
+	defer c.gas.mu.Unlock()
+	for i := range rows {
+		var gasUsed uint64
+		fee := new(big.Int)
+		for hash := range c.txs[rows[i].Address] {
+			receipt := c.gas.receipts[hash]
+			if receipt == nil || receipt.From != rows[i].Address {
+				continue
+			}
+			gasUsed += uint64(receipt.GasUsed)
+			if receipt.EffectiveGasPrice != nil {
+				fee.Add(fee, new(big.Int).Mul(new(big.Int).SetUint64(uint64(receipt.GasUsed)), receipt.EffectiveGasPrice.ToInt()))
+			}
+		}
+		rows[i].GasUsed = gasUsed
+		rows[i].Fee = scaleValue(fee, nativeDecimals)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const formatGrafana = "grafana"
+
+// GrafanaSeries — один ряд в формате timeseries JSON/SimpleJSON datasource:
+// datapoints — пары [число переводов в окне, unix-время начала окна в миллисекундах].
+type GrafanaSeries struct {
+	Target     string     `json:"target"`
+	Datapoints [][2]int64 `json:"datapoints"`
+}
+
+func (c *transferCounter) countWindow(address common.Address, block uint64) {
+	start := block
+	if block >= c.fromBlock {
+		start = c.fromBlock + (block-c.fromBlock)/c.opts.Window*c.opts.Window
+	}
+
+	counts, ok := c.windowCounts[start]
+	if !ok {
+		counts = make(map[common.Address]int)
+		c.windowCounts[start] = counts
+	}
+	counts[address]++
+}
+
+// GrafanaSeries строит ряды для адресов из metrics; время окна берётся из заголовка его первого блока.
+func (c *transferCounter) GrafanaSeries(ctx context.Context, metrics []Metric) ([]GrafanaSeries, error) {
+	var starts []uint64
+	for start := c.fromBlock; start <= c.toBlock; start += c.opts.Window {
+		starts = append(starts, start)
+	}
+
+	timestamps := make([]int64, len(starts))
+	err := c.pool.Run(ctx, len(starts), func(ctx context.Context, i int) error {
+		header, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(starts[i]))
+		if err != nil {
+			return fmt.Errorf("failed to retrieve header of block %d: %w", starts[i], err)
+		}
+		timestamps[i] = int64(header.Time) * 1000
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]GrafanaSeries, 0, len(metrics))
+	for _, m := range metrics {
+		s := GrafanaSeries{Target: m.Label(), Datapoints: make([][2]int64, len(starts))}
+		if m.Name != "" {
+			s.Target = m.Name
+		}
+		for i, start := range starts {
+			s.Datapoints[i] = [2]int64{int64(c.windowCounts[start][m.Address]), timestamps[i]}
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+func writeGrafana(w io.Writer, series []GrafanaSeries) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(series)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	graphFormatDOT     = "dot"
+	graphFormatGraphML = "graphml"
+
+	// defaultGraphTop — сколько адресов рейтинга попадает в -graph вместе с их контрагентами.
+	defaultGraphTop = 20
+)
+
+// graphNode — вершина графа -graph: адрес из топа рейтинга (Rank > 0) или его контрагент.
+type graphNode struct {
+	Address common.Address
+	Name    string
+	Rank    int
+	// Transfers — переводы адреса за весь скан, Counterparties — его различные контрагенты.
+	Transfers      int
+	Counterparties int
+}
+
+// graphEdge — переводы From→To всех токенов; Tokens — сколько различных токенов шло по ребру.
+type graphEdge struct {
+	From, To  common.Address
+	Transfers int
+	Tokens    int
+}
+
+// graphFormatOf выбирает формат -graph: явный -graph-format или расширение файла (.graphml — GraphML, иначе DOT).
+func graphFormatOf(path, format string) (string, error) {
+	switch format {
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".graphml") {
+			return graphFormatGraphML, nil
+		}
+		return graphFormatDOT, nil
+	case graphFormatDOT, graphFormatGraphML:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown -graph-format %q: use dot or graphml", format)
+}
+
+// CounterpartyGraph строит граф переводов первых n адресов рейтинга: рёбра — все их входящие и
+// исходящие потоки, вершины — сами адреса и их контрагенты. Рёбра идут по убыванию числа переводов.
+func (c *transferCounter) CounterpartyGraph(metrics []Metric, n int) ([]graphNode, []graphEdge) {
+	if len(metrics) > n {
+		metrics = metrics[:n]
+	}
+	ranks := make(map[common.Address]int, len(metrics))
+	for i, m := range metrics {
+		ranks[m.Address] = i + 1
+	}
+
+	type edgeKey struct{ From, To common.Address }
+	edges := make(map[edgeKey]*graphEdge)
+	for flow, count := range c.flows {
+		if ranks[flow.From] == 0 && ranks[flow.To] == 0 {
+			continue
+		}
+		key := edgeKey{flow.From, flow.To}
+		edge, ok := edges[key]
+		if !ok {
+			edge = &graphEdge{From: flow.From, To: flow.To}
+			edges[key] = edge
+		}
+		edge.Transfers += count
+		edge.Tokens++
+	}
+
+	nodes := make([]graphNode, 0, len(metrics))
+	seen := make(map[common.Address]bool, len(metrics))
+	for i, m := range metrics {
+		nodes = append(nodes, graphNode{Address: m.Address, Name: m.Name, Rank: i + 1, Transfers: m.Count, Counterparties: len(c.counterparties[m.Address])})
+		seen[m.Address] = true
+	}
+	var others []graphNode
+	out := make([]graphEdge, 0, len(edges))
+	for _, edge := range edges {
+		out = append(out, *edge)
+		for _, address := range []common.Address{edge.From, edge.To} {
+			if !seen[address] {
+				seen[address] = true
+				others = append(others, graphNode{Address: address, Transfers: c.counts[address], Counterparties: len(c.counterparties[address])})
+			}
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].Address.Hex() < others[j].Address.Hex() })
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Transfers != out[j].Transfers {
+			return out[i].Transfers > out[j].Transfers
+		}
+		if out[i].From != out[j].From {
+			return out[i].From.Hex() < out[j].From.Hex()
+		}
+		return out[i].To.Hex() < out[j].To.Hex()
+	})
+	return append(nodes, others...), out
+}
+
+// writeGraphFile записывает граф в path целиком через временный файл: в -watch он переписывается
+// с каждым рейтингом.
+func writeGraphFile(path, format string, nodes []graphNode, edges []graphEdge) error {
+	var buf bytes.Buffer
+	var err error
+	if format == graphFormatGraphML {
+		err = writeGraphML(&buf, nodes, edges)
+	} else {
+		err = writeGraphDOT(&buf, nodes, edges)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write -graph: %w", err)
+	}
+	return nil
+}
+
+// writeGraphDOT пишет ориентированный граф Graphviz; адреса топа закрашены, вес ребра — число переводов.
+func writeGraphDOT(w io.Writer, nodes []graphNode, edges []graphEdge) error {
+	if _, err := fmt.Fprintln(w, "digraph transfers {"); err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		label := n.Address.Hex()
+		if n.Name != "" {
+			label = n.Name + "\n" + label
+		}
+		attrs := fmt.Sprintf("label=%s, transfers=%d, counterparties=%d", quoteDOT(label), n.Transfers, n.Counterparties)
+		if n.Rank > 0 {
+			// rank в DOT занят под выравнивание подграфов, поэтому место в рейтинге — ranking.
+			attrs += fmt.Sprintf(", ranking=%d, style=filled, fillcolor=lightblue", n.Rank)
+		}
+		if _, err := fmt.Fprintf(w, "  %q [%s];\n", n.Address.Hex(), attrs); err != nil {
+			return err
+		}
+	}
+	for _, e := range edges {
+		if _, err := fmt.Fprintf(w, "  %q -> %q [weight=%d, label=\"%d\", tokens=%d];\n", e.From.Hex(), e.To.Hex(), e.Transfers, e.Transfers, e.Tokens); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// quoteDOT экранирует строку DOT: кавычки и обратную косую черту, перевод строки — как \n.
+func quoteDOT(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// writeGraphML пишет граф в GraphML, который открывают Gephi, yEd и Cytoscape.
+func writeGraphML(w io.Writer, nodes []graphNode, edges []graphEdge) error {
+	escape := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	for _, key := range []string{
+		`<key id="label" for="node" attr.name="label" attr.type="string"/>`,
+		`<key id="rank" for="node" attr.name="rank" attr.type="int"/>`,
+		`<key id="transfers" for="node" attr.name="transfers" attr.type="int"/>`,
+		`<key id="counterparties" for="node" attr.name="counterparties" attr.type="int"/>`,
+		`<key id="weight" for="edge" attr.name="weight" attr.type="double"/>`,
+		`<key id="tokens" for="edge" attr.name="tokens" attr.type="int"/>`,
+	} {
+		b.WriteString("  " + key + "\n")
+	}
+	b.WriteString(`  <graph id="transfers" edgedefault="directed">` + "\n")
+	for _, n := range nodes {
+		label := n.Address.Hex()
+		if n.Name != "" {
+			label = n.Name
+		}
+		fmt.Fprintf(&b, "    <node id=%q>\n      <data key=\"label\">%s</data>\n", n.Address.Hex(), escape(label))
+		if n.Rank > 0 {
+			fmt.Fprintf(&b, "      <data key=\"rank\">%d</data>\n", n.Rank)
+		}
+		fmt.Fprintf(&b, "      <data key=\"transfers\">%d</data>\n      <data key=\"counterparties\">%d</data>\n    </node>\n", n.Transfers, n.Counterparties)
+	}
+	for i, e := range edges {
+		fmt.Fprintf(&b, "    <edge id=\"e%d\" source=%q target=%q>\n      <data key=\"weight\">%d</data>\n      <data key=\"tokens\">%d</data>\n    </edge>\n",
+			i, e.From.Hex(), e.To.Hex(), e.Transfers, e.Tokens)
+	}
+	b.WriteString("  </graph>\n</graphml>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
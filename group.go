@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/hex"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"getBlock/metric"
+)
+
+// groupedMetrics суммирует статистику по группам адресов вместо точного адреса:
+// по первым N hex-символам (-group-prefix) или по категории из -labels (-group-by-category).
+func (c *transferCounter) groupedMetrics() ([]Metric, error) {
+	groupOf := func(address common.Address) string {
+		return addressPrefix(address, c.opts.GroupPrefix)
+	}
+	if c.opts.GroupByCategory {
+		groupOf = c.category
+	}
+
+	groups := make(map[string]*Metric)
+
+	for address, count := range c.counts {
+		if address == (common.Address{}) && !c.opts.CountZero || c.excluded(address) {
+			continue
+		}
+
+		prefix := groupOf(address)
+		group, ok := groups[prefix]
+		if !ok {
+			group = &Metric{Group: prefix}
+			if c.opts.Decimals {
+				group.RawValue = new(big.Int)
+			}
+			groups[prefix] = group
+		}
+
+		group.Count += count
+		if c.opts.Decimals && c.rawValues[address] != nil {
+			group.RawValue.Add(group.RawValue, c.rawValues[address])
+		}
+	}
+
+	if len(groups) == 0 {
+		return nil, metric.ErrNoLogs
+	}
+
+	values := make(map[string]*big.Rat)
+	unscaled := make(map[string]bool)
+	if c.opts.Decimals {
+		for address := range c.unscaled {
+			unscaled[groupOf(address)] = true
+		}
+		for address, value := range c.values {
+			if address == (common.Address{}) && !c.opts.CountZero || c.excluded(address) {
+				continue
+			}
+			prefix := groupOf(address)
+			if values[prefix] == nil {
+				values[prefix] = new(big.Rat)
+			}
+			values[prefix].Add(values[prefix], value)
+		}
+	}
+
+	metrics := make([]Metric, 0, len(groups))
+	for prefix, group := range groups {
+		if c.opts.Decimals && !unscaled[prefix] {
+			group.Value = formatDecimal(values[prefix])
+		}
+		metrics = append(metrics, *group)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].Count != metrics[j].Count {
+			return metrics[i].Count > metrics[j].Count
+		}
+		return metrics[i].Group < metrics[j].Group
+	})
+
+	return metrics, nil
+}
+
+func addressPrefix(address common.Address, nibbles int) string {
+	return "0x" + hex.EncodeToString(address.Bytes())[:nibbles]
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// addressGrowth — сколько различных адресов участвовало в переводах диапазона и сколько из них
+// встретилось впервые. New известен только с -store: без истории прошлых прогонов сравнивать не с чем.
+type addressGrowth struct {
+	Active int
+	New    int
+	// Tracked — New посчитан по -store.
+	Tracked bool
+}
+
+func (c *transferCounter) trackGrowth(address common.Address) {
+	if address == (common.Address{}) && !c.opts.CountZero {
+		return
+	}
+	c.activeAddresses[address] = struct{}{}
+}
+
+// rememberKnown запоминает адреса прошлых прогонов из -store: активные адреса вне этого
+// множества считаются новыми.
+func (c *transferCounter) rememberKnown(totals map[common.Address]int) {
+	c.knownAddresses = make(map[common.Address]struct{}, len(totals))
+	for address := range totals {
+		c.knownAddresses[address] = struct{}{}
+	}
+}
+
+// rollGrowth переносит активные адреса в известные, чтобы следующий прогон -interval
+// считал активность и новые адреса только по своим блокам.
+func (c *transferCounter) rollGrowth() {
+	for address := range c.activeAddresses {
+		if c.knownAddresses != nil {
+			c.knownAddresses[address] = struct{}{}
+		}
+	}
+	c.activeAddresses = make(map[common.Address]struct{})
+}
+
+func (c *transferCounter) Growth() addressGrowth {
+	growth := addressGrowth{Active: len(c.activeAddresses), Tracked: c.knownAddresses != nil}
+	if growth.Tracked {
+		for address := range c.activeAddresses {
+			if _, known := c.knownAddresses[address]; !known {
+				growth.New++
+			}
+		}
+	}
+	return growth
+}
+
+func writeGrowth(w io.Writer, growth addressGrowth, stats ScanStats, opts outputOptions) error {
+	if opts.Format == formatMarkdown {
+		header, row := []string{"Blocks", "Active addresses"}, []string{fmt.Sprintf("%d-%d", stats.FromBlock, stats.ToBlock), fmt.Sprint(growth.Active)}
+		if growth.Tracked {
+			header, row = append(header, "New addresses"), append(row, fmt.Sprint(growth.New))
+		}
+		if err := writeMarkdownRow(w, header); err != nil {
+			return err
+		}
+		separator := make([]string, len(header))
+		for i := range separator {
+			separator[i] = "---"
+		}
+		if err := writeMarkdownRow(w, separator); err != nil {
+			return err
+		}
+		return writeMarkdownRow(w, row)
+	}
+
+	line := fmt.Sprintf("%d active addresses in blocks %d-%d", growth.Active, stats.FromBlock, stats.ToBlock)
+	if growth.Tracked {
+		share := 0.0
+		if growth.Active > 0 {
+			share = 100 * float64(growth.New) / float64(growth.Active)
+		}
+		line += fmt.Sprintf(", %d of them new (%.1f%%): not seen in the runs stored in -store", growth.New, share)
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"getBlock/metric"
+)
+
+const (
+	// grpcService — полное имя сервиса из metric.proto.
+	grpcService = "metric.v1.Metric"
+	// grpcStreamPoll — как часто StreamLeaderboard проверяет новые блоки.
+	grpcStreamPoll = 5 * time.Second
+	// grpcMaxMessage ограничивает размер запроса.
+	grpcMaxMessage = 1 << 20
+)
+
+// Коды статусов gRPC, которые возвращает -grpc.
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+)
+
+// grpcConfig — адрес и сертификат -grpc. gRPC идёт поверх HTTP/2, а net/http поднимает HTTP/2
+// только поверх TLS, поэтому сертификат обязателен.
+type grpcConfig struct {
+	Addr, Cert, Key string
+}
+
+// grpcError — ошибка с кодом статуса gRPC.
+type grpcError struct {
+	code int
+	err  error
+}
+
+func (e *grpcError) Error() string {
+	return e.err.Error()
+}
+
+// grpcStatus переводит HTTP-статус scanRange в код gRPC.
+func grpcStatus(status int, err error) error {
+	code := grpcInternal
+	switch status {
+	case http.StatusBadRequest:
+		code = grpcInvalidArgument
+	case http.StatusRequestEntityTooLarge:
+		code = grpcResourceExhausted
+	case http.StatusBadGateway:
+		code = grpcUnavailable
+	}
+	return &grpcError{code: code, err: err}
+}
+
+// grpcHandler разбирает вызовы сервиса metric.v1.Metric (см. metric.proto) без сгенерированного кода:
+// сообщения кодируются вручную через protowire. Каждый вызов сканирует свой диапазон, как REST API.
+type grpcHandler struct {
+	api *apiServer
+	// ctx отменяется при остановке; по нему завершаются открытые StreamLeaderboard.
+	ctx context.Context
+}
+
+func (h *grpcHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	err := h.call(w, r)
+	code, message := grpcOK, ""
+	if err != nil {
+		code, message = grpcInternal, err.Error()
+		var status *grpcError
+		if errors.As(err, &status) {
+			code = status.code
+		}
+		if code == grpcInternal || code == grpcUnavailable {
+			logger.Error("gRPC call failed", "method", r.URL.Path, "err", err)
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", grpcEscape(message))
+	}
+}
+
+func (h *grpcHandler) call(w http.ResponseWriter, r *http.Request) error {
+	method := strings.TrimPrefix(r.URL.Path, "/"+grpcService+"/")
+	if method == r.URL.Path {
+		return &grpcError{code: grpcUnimplemented, err: fmt.Errorf("unknown service in %s", r.URL.Path)}
+	}
+	request, err := readGRPCMessage(r.Body)
+	if err != nil {
+		return err
+	}
+	send := func(message []byte) error {
+		if err := writeGRPCMessage(w, message); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	switch method {
+	case "GetTopAddresses":
+		response, err := h.getTopAddresses(r.Context(), request)
+		if err != nil {
+			return err
+		}
+		return send(response)
+	case "GetAddressStats":
+		response, err := h.getAddressStats(r.Context(), request)
+		if err != nil {
+			return err
+		}
+		return send(response)
+	case "StreamLeaderboard":
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-h.ctx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		err := h.streamLeaderboard(ctx, request, send)
+		if h.ctx.Err() != nil {
+			return nil
+		}
+		return err
+	default:
+		return &grpcError{code: grpcUnimplemented, err: fmt.Errorf("unknown method %s", method)}
+	}
+}
+
+// readGRPCMessage читает единственное сообщение запроса: флаг сжатия, длина и тело.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, &grpcError{code: grpcInvalidArgument, err: fmt.Errorf("failed to read request: %w", err)}
+	}
+	if prefix[0] != 0 {
+		return nil, &grpcError{code: grpcUnimplemented, err: errors.New("compressed requests are not supported")}
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessage {
+		return nil, &grpcError{code: grpcResourceExhausted, err: fmt.Errorf("request of %d bytes exceeds %d", size, grpcMaxMessage)}
+	}
+	message := make([]byte, size)
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, &grpcError{code: grpcInvalidArgument, err: fmt.Errorf("failed to read request: %w", err)}
+	}
+	return message, nil
+}
+
+func writeGRPCMessage(w io.Writer, message []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+// grpcEscape кодирует grpc-message процентами, как требует протокол.
+func grpcEscape(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// grpcRequest — поля запросов metric.proto; у всех сообщений одинаковые номера полей.
+type grpcRequest struct {
+	N        uint64
+	Address  string
+	From, To string
+}
+
+func parseGRPCRequest(message []byte) (grpcRequest, error) {
+	var req grpcRequest
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return req, &grpcError{code: grpcInvalidArgument, err: fmt.Errorf("malformed request: %w", protowire.ParseError(n))}
+		}
+		message = message[n:]
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			req.N, n = protowire.ConsumeVarint(message)
+		case num <= 4 && typ == protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(message)
+			switch num {
+			case 2:
+				req.From = string(value)
+			case 3:
+				req.To = string(value)
+			case 4:
+				req.Address = string(value)
+			}
+		default:
+			// Неизвестные поля пропускаются, как в protobuf.
+			n = protowire.ConsumeFieldValue(num, typ, message)
+		}
+		if n < 0 {
+			return req, &grpcError{code: grpcInvalidArgument, err: fmt.Errorf("malformed request: %w", protowire.ParseError(n))}
+		}
+		message = message[n:]
+	}
+	return req, nil
+}
+
+// encodeAddressMetric кодирует AddressMetric; rank считается с 1.
+func encodeAddressMetric(m Metric, rank int) []byte {
+	var b []byte
+	b = appendGRPCString(b, 1, m.Label())
+	b = appendGRPCString(b, 2, m.Name)
+	b = appendGRPCString(b, 3, m.Chain)
+	b = appendGRPCVarint(b, 4, uint64(rank))
+	b = appendGRPCVarint(b, 5, uint64(m.Count))
+	b = appendGRPCVarint(b, 6, uint64(m.SentCount))
+	b = appendGRPCVarint(b, 7, uint64(m.ReceivedCount))
+	if m.Score != 0 {
+		b = protowire.AppendTag(b, 8, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(m.Score))
+	}
+	if m.RawValue != nil {
+		b = appendGRPCString(b, 9, m.RawValue.String())
+	}
+	b = appendGRPCString(b, 10, m.Value)
+	if m.Sent != nil || m.Received != nil {
+		b = appendGRPCString(b, 11, formatDecimal(m.Sent))
+		b = appendGRPCString(b, 12, formatDecimal(m.Received))
+	}
+	if m.USD != nil {
+		b = appendGRPCString(b, 13, formatUSD(m.USD))
+	}
+	return b
+}
+
+// appendGRPCString и appendGRPCVarint пропускают нулевые значения, как proto3.
+func appendGRPCString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendGRPCVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendGRPCMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+// grpcTopN разбирает n запроса так же, как /metrics/top: 0 — значение по умолчанию.
+func grpcTopN(n uint64) (int, error) {
+	if n == 0 {
+		return topN, nil
+	}
+	if n > maxAPITop {
+		return 0, &grpcError{code: grpcInvalidArgument, err: fmt.Errorf("invalid n %d: expected 1-%d", n, maxAPITop)}
+	}
+	return int(n), nil
+}
+
+func (h *grpcHandler) getTopAddresses(ctx context.Context, message []byte) ([]byte, error) {
+	req, err := parseGRPCRequest(message)
+	if err != nil {
+		return nil, err
+	}
+	n, err := grpcTopN(req.N)
+	if err != nil {
+		return nil, err
+	}
+	metrics, counter, status, err := h.api.scanRange(ctx, req.From, req.To)
+	if err != nil {
+		return nil, grpcStatus(status, err)
+	}
+	if len(metrics) > n {
+		metrics = metrics[:n]
+	}
+
+	stats := counter.Stats()
+	var b []byte
+	for i, m := range metrics {
+		b = appendGRPCMessage(b, 1, encodeAddressMetric(m, i+1))
+	}
+	b = appendGRPCVarint(b, 2, stats.FromBlock)
+	b = appendGRPCVarint(b, 3, stats.ToBlock)
+	return b, nil
+}
+
+func (h *grpcHandler) getAddressStats(ctx context.Context, message []byte) ([]byte, error) {
+	req, err := parseGRPCRequest(message)
+	if err != nil {
+		return nil, err
+	}
+	if !common.IsHexAddress(req.Address) {
+		return nil, &grpcError{code: grpcInvalidArgument, err: fmt.Errorf("invalid address %q", req.Address)}
+	}
+	metrics, counter, status, err := h.api.scanRange(ctx, req.From, req.To)
+	if err != nil {
+		return nil, grpcStatus(status, err)
+	}
+
+	stats := counter.Stats()
+	var b []byte
+	if rank, m, found := metric.RankOf(metrics, common.HexToAddress(req.Address)); found {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(true))
+		b = appendGRPCMessage(b, 2, encodeAddressMetric(m, rank))
+	}
+	b = appendGRPCVarint(b, 3, uint64(len(metrics)))
+	b = appendGRPCVarint(b, 4, stats.FromBlock)
+	b = appendGRPCVarint(b, 5, stats.ToBlock)
+	return b, nil
+}
+
+// streamLeaderboard сканирует диапазон от from до последнего блока и присылает топ n целиком,
+// затем каждые grpcStreamPoll досчитывает новые блоки и присылает только изменения: строки,
+// у которых сменились место или счётчики, и адреса, выбывшие из топа.
+func (h *grpcHandler) streamLeaderboard(ctx context.Context, message []byte, send func([]byte) error) error {
+	req, err := parseGRPCRequest(message)
+	if err != nil {
+		return err
+	}
+	n, err := grpcTopN(req.N)
+	if err != nil {
+		return err
+	}
+	metrics, counter, status, err := h.api.scanRange(ctx, req.From, "")
+	if err != nil {
+		return grpcStatus(status, err)
+	}
+
+	previous := make(map[string][]byte)
+	update := func(metrics []Metric, snapshot bool) error {
+		if len(metrics) > n {
+			metrics = metrics[:n]
+		}
+		current := make(map[string][]byte, len(metrics))
+		var b []byte
+		for i, m := range metrics {
+			row := encodeAddressMetric(m, i+1)
+			current[m.Label()] = row
+			if snapshot || !bytes.Equal(previous[m.Label()], row) {
+				b = appendGRPCMessage(b, 1, row)
+			}
+		}
+		for label := range previous {
+			if _, ok := current[label]; !ok {
+				b = appendGRPCString(b, 2, label)
+			}
+		}
+		previous = current
+		if !snapshot && len(b) == 0 {
+			return nil
+		}
+
+		stats := counter.Stats()
+		if snapshot {
+			b = protowire.AppendTag(b, 3, protowire.VarintType)
+			b = protowire.AppendVarint(b, protowire.EncodeBool(true))
+		}
+		b = appendGRPCVarint(b, 4, stats.FromBlock)
+		b = appendGRPCVarint(b, 5, stats.ToBlock)
+		return send(b)
+	}
+	if err := update(metrics, true); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(grpcStreamPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		head, err := h.api.client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return grpcStatus(http.StatusBadGateway, fmt.Errorf("failed to retrieve the latest block header: %w", err))
+		}
+		stats := counter.Stats()
+		if head.Number.Uint64() <= stats.ToBlock {
+			continue
+		}
+		from := new(big.Int).SetUint64(stats.ToBlock + 1)
+		logs, err := counter.fetchLogs(ctx, h.api.client, from, head.Number)
+		if err != nil {
+			return grpcStatus(http.StatusBadGateway, err)
+		}
+		counter.SetRange(stats.FromBlock, head.Number.Uint64())
+		if err := counter.AddLogs(ctx, logs); err != nil {
+			return grpcStatus(http.StatusBadGateway, err)
+		}
+		metrics, err := counter.Metrics()
+		if err != nil && !errors.Is(err, metric.ErrNoLogs) {
+			return err
+		}
+		h.api.tag(metrics)
+		if err := update(metrics, false); err != nil {
+			return err
+		}
+	}
+}
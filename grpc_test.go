@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// rawCodec передаёт сообщения gRPC готовыми байтами: сгенерированного кода для metric.proto нет
+// ни у сервера, ни у теста.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) { return *v.(*[]byte), nil }
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// grpcTestConn поднимает -grpc поверх узла-заглушки с переводами logs в блоках до 12 и
+// подключается к нему клиентом google.golang.org/grpc.
+func grpcTestConn(t *testing.T, logs []types.Log) *grpc.ClientConn {
+	t.Helper()
+	client := dialTestRPC(t, func(method string, params []json.RawMessage) (any, error) {
+		switch method {
+		case "eth_getBlockByNumber":
+			return &types.Header{Number: big.NewInt(12), Difficulty: big.NewInt(0)}, nil
+		case "eth_getLogs":
+			return logs, nil
+		}
+		return nil, fmt.Errorf("unexpected %s", method)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	server := httptest.NewUnstartedServer(&grpcHandler{api: &apiServer{client: client, opts: testScanOptions()}, ctx: ctx})
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	conn, err := grpc.Dial(strings.TrimPrefix(server.URL, "https://"),
+		grpc.WithTransportCredentials(credentials.NewClientTLSFromCert(pool, "example.com")),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(rawCodec{})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// grpcRow — поля AddressMetric, которые проверяет тест.
+type grpcRow struct {
+	Address     string
+	Rank, Count uint64
+}
+
+// decodeGRPCRows разбирает повторяющееся поле field с AddressMetric и varint-поля ответа.
+func decodeGRPCRows(t *testing.T, message []byte, field protowire.Number) ([]grpcRow, map[protowire.Number]uint64) {
+	t.Helper()
+	var rows []grpcRow
+	varints := make(map[protowire.Number]uint64)
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			t.Fatalf("malformed response: %v", protowire.ParseError(n))
+		}
+		message = message[n:]
+		switch {
+		case num == field && typ == protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(message)
+			rows = append(rows, decodeGRPCRow(t, value))
+		case typ == protowire.VarintType:
+			varints[num], n = protowire.ConsumeVarint(message)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, message)
+		}
+		if n < 0 {
+			t.Fatalf("malformed response: %v", protowire.ParseError(n))
+		}
+		message = message[n:]
+	}
+	return rows, varints
+}
+
+func decodeGRPCRow(t *testing.T, message []byte) grpcRow {
+	t.Helper()
+	var row grpcRow
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		message = message[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(message)
+			row.Address = string(value)
+		case num == 4:
+			row.Rank, n = protowire.ConsumeVarint(message)
+		case num == 5:
+			row.Count, n = protowire.ConsumeVarint(message)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, message)
+		}
+		if n < 0 {
+			t.Fatalf("malformed AddressMetric: %v", protowire.ParseError(n))
+		}
+		message = message[n:]
+	}
+	return row
+}
+
+func grpcTopRequest(n uint64, from, to string) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.VarintType)
+	b = protowire.AppendVarint(b, n)
+	b = appendGRPCString(b, 2, from)
+	return appendGRPCString(b, 3, to)
+}
+
+func TestGRPCClient(t *testing.T) {
+	conn := grpcTestConn(t, []types.Log{
+		transferLog(testAddress(1), testAddress(2), 5, 10, 0),
+		transferLog(testAddress(1), testAddress(3), 5, 11, 0),
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	request := grpcTopRequest(2, "10", "12")
+	var response []byte
+	if err := conn.Invoke(ctx, "/"+grpcService+"/GetTopAddresses", &request, &response); err != nil {
+		t.Fatalf("GetTopAddresses: %v", err)
+	}
+	rows, varints := decodeGRPCRows(t, response, 1)
+	want := []grpcRow{{testAddress(1).Hex(), 1, 2}, {testAddress(2).Hex(), 2, 1}}
+	if fmt.Sprint(rows) != fmt.Sprint(want) {
+		t.Errorf("metrics = %v, want %v", rows, want)
+	}
+	if varints[2] != 10 || varints[3] != 12 {
+		t.Errorf("blocks = %d-%d, want 10-12", varints[2], varints[3])
+	}
+
+	request = grpcTopRequest(5000, "10", "12")
+	err := conn.Invoke(ctx, "/"+grpcService+"/GetTopAddresses", &request, &response)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("n = 5000: got %v, want InvalidArgument", err)
+	}
+	err = conn.Invoke(ctx, "/"+grpcService+"/Unknown", &request, &response)
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("unknown method: got %v, want Unimplemented", err)
+	}
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/"+grpcService+"/StreamLeaderboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request = grpcTopRequest(1, "10", "")
+	if err := stream.SendMsg(&request); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.RecvMsg(&response); err != nil {
+		t.Fatalf("StreamLeaderboard: %v", err)
+	}
+	rows, varints = decodeGRPCRows(t, response, 1)
+	if len(rows) != 1 || rows[0].Address != testAddress(1).Hex() || varints[3] != 1 {
+		t.Errorf("first update = %v (snapshot %d), want a snapshot with %s", rows, varints[3], testAddress(1).Hex())
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// headerCache отдаёт повторные HeaderByNumber одного прогона из памяти.
+// Запрос последнего блока (nil) не кэшируется: его ответ меняется с каждым блоком.
+type headerCache struct {
+	client  ChainReader
+	stats   *Stats
+	timeout time.Duration
+
+	mu      sync.Mutex
+	headers map[string]*types.Header
+}
+
+func newHeaderCache(client ChainReader, stats *Stats, timeout time.Duration) *headerCache {
+	return &headerCache{
+		client:  client,
+		stats:   stats,
+		timeout: timeout,
+		headers: make(map[string]*types.Header),
+	}
+}
+
+func (c *headerCache) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	ctx, cancel := withRequestTimeout(ctx, c.timeout)
+	defer cancel()
+
+	if number == nil {
+		header, err := c.client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			c.stats.IncRPCError()
+		}
+		return header, err
+	}
+
+	key := number.String()
+	c.mu.Lock()
+	header, ok := c.headers[key]
+	c.mu.Unlock()
+	if ok {
+		c.stats.IncCacheHit()
+		return header, nil
+	}
+
+	header, err := c.client.HeaderByNumber(ctx, number)
+	if err != nil {
+		c.stats.IncRPCError()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.headers[key] = header
+	c.mu.Unlock()
+	return header, nil
+}
+
+// Prefetch заполняет кэш заголовками блоков numbers: по prefetchBatchSize eth_getBlockByNumber
+// в одном JSON-RPC батче вместо запроса на блок. Клиент без батчей (не ethclient) запрашивает по одному.
+func (c *headerCache) Prefetch(ctx context.Context, pool *enrichPool, numbers []uint64) error {
+	var missing []uint64
+	c.mu.Lock()
+	for _, number := range numbers {
+		if _, ok := c.headers[new(big.Int).SetUint64(number).String()]; ok {
+			c.stats.IncCacheHit()
+		} else {
+			missing = append(missing, number)
+		}
+	}
+	c.mu.Unlock()
+
+	batcher, ok := c.client.(interface{ Client() *rpc.Client })
+	if !ok {
+		return pool.Run(ctx, len(missing), func(ctx context.Context, i int) error {
+			_, err := c.HeaderByNumber(ctx, new(big.Int).SetUint64(missing[i]))
+			return err
+		})
+	}
+
+	batches := (len(missing) + prefetchBatchSize - 1) / prefetchBatchSize
+	return pool.Run(ctx, batches, func(ctx context.Context, i int) error {
+		start := i * prefetchBatchSize
+		end := start + prefetchBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		headers := make([]*types.Header, end-start)
+		elems := make([]rpc.BatchElem, end-start)
+		for j, number := range missing[start:end] {
+			elems[j] = rpc.BatchElem{Method: "eth_getBlockByNumber", Args: []interface{}{hexutil.EncodeUint64(number), false}, Result: &headers[j]}
+		}
+		ctx, cancel := withRequestTimeout(ctx, c.timeout)
+		defer cancel()
+		if err := batcher.Client().BatchCallContext(ctx, elems); err != nil {
+			c.stats.IncRPCError()
+			return fmt.Errorf("failed to send headers batch: %w", err)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for j, elem := range elems {
+			number := missing[start+j]
+			if elem.Error != nil {
+				c.stats.IncRPCError()
+				return fmt.Errorf("failed to fetch header of block %d: %w", number, elem.Error)
+			}
+			if headers[j] == nil {
+				return fmt.Errorf("failed to fetch header of block %d: %w", number, ethereum.NotFound)
+			}
+			c.headers[new(big.Int).SetUint64(number).String()] = headers[j]
+		}
+		return nil
+	})
+}
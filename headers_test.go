@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// countingChain считает запросы заголовков к fixtureChain по номерам блоков ("latest" для nil).
+type countingChain struct {
+	*fixtureChain
+	calls map[string]int
+}
+
+func (c *countingChain) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	c.calls[blockLabel(number)]++
+	return c.fixtureChain.HeaderByNumber(ctx, number)
+}
+
+func TestHeaderCache(t *testing.T) {
+	chain := &countingChain{fixtureChain: newFixtureChain(fixtureLogs(), 0), calls: make(map[string]int)}
+	stats := new(Stats)
+	cache := newHeaderCache(chain, stats, 0)
+	ctx := context.Background()
+
+	lookups := []*big.Int{nil, big.NewInt(5), big.NewInt(5), nil, big.NewInt(7), big.NewInt(5)}
+	for _, number := range lookups {
+		header, err := cache.HeaderByNumber(ctx, number)
+		if err != nil {
+			t.Fatalf("HeaderByNumber(%s): %v", blockLabel(number), err)
+		}
+		if number != nil && header.Number.Cmp(number) != 0 {
+			t.Fatalf("HeaderByNumber(%s) returned block %v", number, header.Number)
+		}
+	}
+
+	want := map[string]int{blockLatest: 2, "5": 1, "7": 1}
+	for label, n := range want {
+		if chain.calls[label] != n {
+			t.Errorf("block %s requested %d times, want %d", label, chain.calls[label], n)
+		}
+	}
+	if hits := stats.Snapshot().CacheHits; hits != 2 {
+		t.Errorf("cache hits = %d, want 2", hits)
+	}
+
+	if _, err := cache.HeaderByNumber(ctx, big.NewInt(99)); err == nil {
+		t.Fatal("a block after head was found")
+	}
+	if _, err := cache.HeaderByNumber(ctx, big.NewInt(99)); err == nil || chain.calls["99"] != 2 {
+		t.Errorf("a failed lookup was cached: %d requests", chain.calls["99"])
+	}
+}
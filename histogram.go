@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultHistogramBins — верхние границы корзин: 1, 2-5, 6-20, 21-100, 101+.
+const defaultHistogramBins = "1,5,20,100"
+
+// HistogramBin — число адресов, у которых количество переводов попадает в [Min, Max].
+// Max == 0 означает корзину без верхней границы.
+type HistogramBin struct {
+	Min, Max  int
+	Addresses int
+}
+
+func (b HistogramBin) label() string {
+	switch {
+	case b.Max == 0:
+		return fmt.Sprintf("%d+", b.Min)
+	case b.Min == b.Max:
+		return strconv.Itoa(b.Min)
+	default:
+		return fmt.Sprintf("%d-%d", b.Min, b.Max)
+	}
+}
+
+// parseHistogramBins разбирает возрастающий список верхних границ вида "1,5,20,100".
+func parseHistogramBins(s string) ([]int, error) {
+	var bounds []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bound, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bin boundary %q: %w", part, err)
+		}
+		if bound < 1 {
+			return nil, fmt.Errorf("invalid bin boundary %d: must be at least 1", bound)
+		}
+		if len(bounds) > 0 && bound <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("bin boundaries must be increasing, got %d after %d", bound, bounds[len(bounds)-1])
+		}
+		bounds = append(bounds, bound)
+	}
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("no bin boundaries given")
+	}
+	return bounds, nil
+}
+
+func Histogram(metrics []Metric, bounds []int) []HistogramBin {
+	bins := make([]HistogramBin, len(bounds)+1)
+	low := 1
+	for i, bound := range bounds {
+		bins[i] = HistogramBin{Min: low, Max: bound}
+		low = bound + 1
+	}
+	bins[len(bounds)] = HistogramBin{Min: low}
+
+	for _, m := range metrics {
+		if m.Count < 1 {
+			continue
+		}
+		i := sort.SearchInts(bounds, m.Count)
+		bins[i].Addresses++
+	}
+	return bins
+}
+
+func writeHistogram(w io.Writer, bins []HistogramBin, opts outputOptions) error {
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Transfers", "Addresses"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---"}); err != nil {
+			return err
+		}
+		for _, b := range bins {
+			if err := writeMarkdownRow(w, []string{b.label(), strconv.Itoa(b.Addresses)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "%-10s %s\n", "transfers", "addresses"); err != nil {
+		return err
+	}
+	for _, b := range bins {
+		if _, err := fmt.Fprintf(w, "%-10s %d\n", b.label(), b.Addresses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
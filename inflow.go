@@ -0,0 +1,32 @@
+package main
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// countInflow суммирует только полученное адресом значение; отправленное не вычитается.
+func (c *transferCounter) countInflow(to common.Address, value *big.Int) {
+	if c.inflow[to] == nil {
+		c.inflow[to] = new(big.Int)
+	}
+	c.inflow[to].Add(c.inflow[to], value)
+}
+
+// sortByInflow переупорядочивает весь рейтинг по полученным суммам, при равенстве — по count.
+func (c *transferCounter) sortByInflow(metrics []Metric) {
+	for i := range metrics {
+		metrics[i].InflowValue = c.inflow[metrics[i].Address]
+		if metrics[i].InflowValue == nil {
+			metrics[i].InflowValue = new(big.Int)
+		}
+	}
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if cmp := metrics[i].InflowValue.Cmp(metrics[j].InflowValue); cmp != 0 {
+			return cmp > 0
+		}
+		return metrics[i].Count > metrics[j].Count
+	})
+}
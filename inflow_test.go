@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestInflowRanking(t *testing.T) {
+	// 0x0a получает 100 и отправляет 90: чистый приток 10, оборот 190, inflow 100.
+	// 0x0b получает 90 (приток 90), 0x0c — 60; 0x01 и 0x02 только отправляют.
+	a, b, c := testAddress(0x0a), testAddress(0x0b), testAddress(0x0c)
+	logs := []types.Log{
+		transferLog(testAddress(1), a, 100, 1, 0),
+		transferLog(a, b, 90, 2, 0),
+		transferLog(testAddress(2), c, 60, 3, 0),
+	}
+
+	inflowOpts := testScanOptions()
+	inflowOpts.Inflow = true
+	inflow := countTestLogs(t, inflowOpts, logs)
+	want := []struct {
+		address common.Address
+		inflow  int64
+	}{{a, 100}, {b, 90}, {c, 60}, {testAddress(1), 0}, {testAddress(2), 0}}
+	for i, w := range want {
+		if inflow[i].Address != w.address || inflow[i].InflowValue.Int64() != w.inflow {
+			t.Errorf("inflow row %d = %s %v, want %s %d", i+1, inflow[i].Address.Hex(), inflow[i].InflowValue, w.address.Hex(), w.inflow)
+		}
+	}
+
+	// Оборот ставит отправителя 0x01 выше получателя 0x0b, а inflow — наоборот.
+	valueOpts := testScanOptions()
+	valueOpts.Decimals = true
+	valueOpts.Volume = true
+	valueOpts.TokenRegistry = map[common.Address]tokenInfo{testToken: {Decimals: 0}}
+	byValue := sortMetrics(countTestLogs(t, valueOpts, logs), sortValue, "", "")
+	if byValue[0].Address != a || byValue[1].Address != testAddress(1) {
+		t.Errorf("-sort value starts with %s, %s; want %s, %s", byValue[0].Address.Hex(), byValue[1].Address.Hex(), a.Hex(), testAddress(1).Hex())
+	}
+
+	// Чистый приток (получено - отправлено) у 0x0a меньше, чем у 0x0b и 0x0c, но inflow ставит его первым.
+	net := make(map[common.Address]*big.Rat)
+	for _, m := range byValue {
+		net[m.Address] = new(big.Rat).Sub(ratOrZero(m.Received), ratOrZero(m.Sent))
+	}
+	if net[a].Cmp(big.NewRat(10, 1)) != 0 || net[a].Cmp(net[b]) >= 0 || net[a].Cmp(net[c]) >= 0 {
+		t.Errorf("net flow of 0x0a is %v (0x0b %v, 0x0c %v), want 10 and below both", net[a], net[b], net[c])
+	}
+}
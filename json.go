@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const formatJSON = "json"
+
+// metricJSON — строка рейтинга в -format json; тот же формат читает -baseline.
+type metricJSON struct {
+	Chain         string          `json:"chain,omitempty"`
+	Address       *common.Address `json:"address,omitempty"`
+	Group         string          `json:"group,omitempty"`
+	Name          string          `json:"name,omitempty"`
+	Count         int             `json:"count"`
+	SentCount     int             `json:"sent,omitempty"`
+	ReceivedCount int             `json:"received,omitempty"`
+	Score         float64         `json:"score,omitempty"`
+	RawValue      *big.Int        `json:"raw_value,omitempty"`
+	Value         string          `json:"value,omitempty"`
+	Inflow        *big.Int        `json:"inflow_value,omitempty"`
+	Rate          float64         `json:"rate,omitempty"`
+	Sent          string          `json:"sent_value,omitempty"`
+	Received      string          `json:"received_value,omitempty"`
+	USD           string          `json:"usd,omitempty"`
+	Txs           int             `json:"txs,omitempty"`
+	PerTx         float64         `json:"transfers_per_tx,omitempty"`
+	GasUsed       uint64          `json:"gas_used,omitempty"`
+	Fee           string          `json:"fee,omitempty"`
+}
+
+func toMetricJSON(m Metric) metricJSON {
+	out := metricJSON{Chain: m.Chain, Group: m.Group, Name: m.Name, Count: m.Count, SentCount: m.SentCount, ReceivedCount: m.ReceivedCount, Score: m.Score, RawValue: m.RawValue, Value: m.Value, Inflow: m.InflowValue, Rate: m.Rate, Txs: m.Txs, PerTx: m.PerTx, GasUsed: m.GasUsed}
+	if m.Sent != nil || m.Received != nil {
+		out.Sent, out.Received = formatDecimal(m.Sent), formatDecimal(m.Received)
+	}
+	if m.USD != nil {
+		out.USD = formatUSD(m.USD)
+	}
+	if m.Fee != nil {
+		out.Fee = formatDecimal(m.Fee)
+	}
+	if m.Group == "" {
+		address := m.Address
+		out.Address = &address
+	}
+	return out
+}
+
+func (m metricJSON) metric() Metric {
+	out := Metric{Chain: m.Chain, Group: m.Group, Name: m.Name, Count: m.Count, SentCount: m.SentCount, ReceivedCount: m.ReceivedCount, Score: m.Score, RawValue: m.RawValue, Value: m.Value, InflowValue: m.Inflow, Rate: m.Rate, Txs: m.Txs, PerTx: m.PerTx, GasUsed: m.GasUsed}
+	if m.Address != nil {
+		out.Address = *m.Address
+	}
+	if m.Sent != "" || m.Received != "" {
+		out.Sent, _ = new(big.Rat).SetString(m.Sent)
+		out.Received, _ = new(big.Rat).SetString(m.Received)
+	}
+	if m.USD != "" {
+		out.USD, _ = new(big.Rat).SetString(m.USD)
+	}
+	if m.Fee != "" {
+		out.Fee, _ = new(big.Rat).SetString(m.Fee)
+	}
+	return out
+}
+
+// jsonField — ключ и значение поля -fields в объекте -format json; ключи те же, что у metricJSON.
+type jsonField struct {
+	key   string
+	value func(metricJSON) any
+}
+
+// optional — пустая строка, как omitempty у metricJSON, выводится null.
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+var jsonFields = map[string]jsonField{
+	"chain":          {"chain", func(m metricJSON) any { return m.Chain }},
+	"address":        {"address", func(m metricJSON) any { return m.Address }},
+	"name":           {"name", func(m metricJSON) any { return m.Name }},
+	"count":          {"count", func(m metricJSON) any { return m.Count }},
+	"sent":           {"sent", func(m metricJSON) any { return m.SentCount }},
+	"received":       {"received", func(m metricJSON) any { return m.ReceivedCount }},
+	"score":          {"score", func(m metricJSON) any { return m.Score }},
+	"raw_value":      {"raw_value", func(m metricJSON) any { return m.RawValue }},
+	"value":          {"value", func(m metricJSON) any { return optional(m.Value) }},
+	"inflow":         {"inflow_value", func(m metricJSON) any { return m.Inflow }},
+	"sent_value":     {"sent_value", func(m metricJSON) any { return optional(m.Sent) }},
+	"received_value": {"received_value", func(m metricJSON) any { return optional(m.Received) }},
+	"usd":            {"usd", func(m metricJSON) any { return optional(m.USD) }},
+	"rate":           {"rate", func(m metricJSON) any { return m.Rate }},
+	"txs":            {"txs", func(m metricJSON) any { return m.Txs }},
+	"gas_used":       {"gas_used", func(m metricJSON) any { return m.GasUsed }},
+	"fee":            {"fee", func(m metricJSON) any { return optional(m.Fee) }},
+	"per_tx":         {"transfers_per_tx", func(m metricJSON) any { return m.PerTx }},
+}
+
+// jsonFormatter пишет все поля metricJSON, а с -fields — только перечисленные и в их порядке,
+// как колонки -format csv.
+type jsonFormatter struct {
+	opts outputOptions
+}
+
+// Write пишет JSON-массив построчно: "[", объекты через запятую, "]" — без сборки всего
+// массива в памяти. Encoder добавляет перевод строки после каждого объекта, поэтому
+// каждая строка вывода, кроме последней "]", содержит ровно одну запись.
+func (f jsonFormatter) Write(w io.Writer, metrics []Metric, _ ScanStats) error {
+	encoder := json.NewEncoder(w)
+	for i, m := range metrics {
+		separator := ","
+		if i == 0 {
+			separator = "["
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		var err error
+		if len(f.opts.Fields) > 0 {
+			err = writeJSONFields(w, toMetricJSON(m), f.opts.Fields)
+		} else {
+			err = encoder.Encode(toMetricJSON(m))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	closing := "]\n"
+	if len(metrics) == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(w, closing)
+	return err
+}
+
+// writeJSONFields пишет объект из полей names в их порядке: encoding/json упорядочил бы ключи map.
+// Строки групп -group-prefix и -group-by-category выводят поле address как group.
+func writeJSONFields(w io.Writer, m metricJSON, names []string) error {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range names {
+		field := jsonFields[name]
+		key, value := field.key, field.value(m)
+		if name == "address" && m.Address == nil {
+			key, value = "group", m.Group
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%q:%s", key, encoded)
+	}
+	b.WriteString("}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// loadMetrics читает рейтинг, сохранённый ранее через -format json или -format report.
+func loadMetrics(path string) ([]Metric, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	var rows []metricJSON
+	if err := json.Unmarshal(data, &rows); err != nil {
+		var report Report
+		if reportErr := json.Unmarshal(data, &report); reportErr != nil {
+			return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+		}
+		rows = report.Metrics
+	}
+
+	metrics := make([]Metric, len(rows))
+	for i, row := range rows {
+		metrics[i] = row.metric()
+	}
+	return metrics, nil
+}
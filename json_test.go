@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestJSONFormatterStreamsValidArray(t *testing.T) {
+	many := make([]Metric, 5000)
+	for i := range many {
+		many[i] = Metric{Address: testAddress(byte(i)), Count: 5000 - i, RawValue: big.NewInt(int64(i))}
+	}
+	tests := []struct {
+		name    string
+		metrics []Metric
+	}{
+		{"empty", nil},
+		{"one row", many[:1]},
+		{"large ranking", many},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (jsonFormatter{}).Write(&buf, tt.metrics, ScanStats{}); err != nil {
+				t.Fatal(err)
+			}
+			var rows []metricJSON
+			if err := json.Unmarshal(buf.Bytes(), &rows); err != nil {
+				t.Fatalf("output is not a JSON array: %v", err)
+			}
+			if rows == nil || len(rows) != len(tt.metrics) {
+				t.Fatalf("decoded %d rows, want %d", len(rows), len(tt.metrics))
+			}
+			for i, row := range rows {
+				if row.Count != tt.metrics[i].Count {
+					t.Fatalf("row %d has count %d, want %d", i, row.Count, tt.metrics[i].Count)
+				}
+			}
+			// Одна запись на строку: вывод можно читать построчно, не разбирая массив целиком.
+			if lines := strings.Count(buf.String(), "\n"); len(tt.metrics) > 0 && lines != len(tt.metrics)+1 {
+				t.Errorf("%d lines for %d rows", lines, len(tt.metrics))
+			}
+		})
+	}
+}
+
+func TestJSONFormatterFields(t *testing.T) {
+	metrics := []Metric{
+		{Address: testAddress(1), Count: 5, RawValue: big.NewInt(70)},
+		{Group: "0x12", Count: 3},
+	}
+	var buf bytes.Buffer
+	if err := (jsonFormatter{opts: outputOptions{Fields: []string{"count", "address", "value"}}}).Write(&buf, metrics, ScanStats{}); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"count":5,"address":"` + testAddress(1).Hex() + `","value":null}
+,{"count":3,"group":"0x12","value":null}
+]
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rows); err != nil {
+		t.Fatalf("output is not a JSON array: %v", err)
+	}
+	if _, ok := rows[0]["raw_value"]; ok {
+		t.Errorf("raw_value is not in -fields but was written: %v", rows[0])
+	}
+
+	// Каждое поле -fields есть и в JSON.
+	for _, field := range metricFields {
+		if _, ok := jsonFields[field.Name]; !ok {
+			t.Errorf("field %s has no JSON key", field.Name)
+		}
+	}
+}
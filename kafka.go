@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/segmentio/kafka-go"
+
+	"getBlock/metric"
+)
+
+const (
+	// kafkaDialTimeout — ожидание соединения с брокером при проверке на старте.
+	kafkaDialTimeout = 10 * time.Second
+	// kafkaBatchTimeout — сколько сообщения копятся в пачку перед отправкой.
+	kafkaBatchTimeout = 100 * time.Millisecond
+)
+
+// kafkaPublisher публикует каждый разобранный перевод сообщением JSON (как строка -follow-logs)
+// в Kafka. Ключ сообщения — адрес токена, поэтому переводы одного токена попадают в одну партицию
+// по порядку. Отправка асинхронная: ошибка пачки возвращается следующей публикацией или Close.
+type kafkaPublisher struct {
+	writer *kafka.Writer
+	topic  string
+
+	mu  sync.Mutex
+	err error
+}
+
+// openKafkaPublisher проверяет, что первый отвечающий брокер из brokers (host:port через запятую)
+// доступен, и готовит публикацию в topic.
+func openKafkaPublisher(brokers, topic string) (*kafkaPublisher, error) {
+	var addrs []string
+	for _, broker := range strings.Split(brokers, ",") {
+		broker = strings.TrimSpace(broker)
+		if broker == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(broker); err != nil {
+			return nil, fmt.Errorf("invalid -kafka-brokers entry %q: expected host:port", broker)
+		}
+		addrs = append(addrs, broker)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("invalid -kafka-brokers %q: expected host:port[,host:port]", brokers)
+	}
+	if topic == "" || strings.ContainsAny(topic, " \t\r\n") {
+		return nil, fmt.Errorf("invalid -kafka-topic %q", topic)
+	}
+	if err := checkKafkaBrokers(addrs); err != nil {
+		return nil, err
+	}
+
+	p := &kafkaPublisher{topic: topic}
+	p.writer = &kafka.Writer{
+		Addr:         kafka.TCP(addrs...),
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: kafkaBatchTimeout,
+		RequiredAcks: kafka.RequireAll,
+		Async:        true,
+		// Темы с {token} заранее не известны; создаст ли их брокер, решает его auto.create.topics.enable.
+		AllowAutoTopicCreation: true,
+		Completion: func(_ []kafka.Message, err error) {
+			if err != nil {
+				p.setErr(fmt.Errorf("failed to publish to Kafka: %w", err))
+			}
+		},
+	}
+	return p, nil
+}
+
+// checkKafkaBrokers запрашивает список брокеров кластера: так неверный адрес виден сразу, а не при первом переводе.
+func checkKafkaBrokers(addrs []string) error {
+	var lastErr error
+	for _, addr := range addrs {
+		ctx, cancel := context.WithTimeout(context.Background(), kafkaDialTimeout)
+		conn, err := kafka.DialContext(ctx, "tcp", addr)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		conn.SetDeadline(time.Now().Add(kafkaDialTimeout))
+		_, err = conn.Brokers()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+	}
+	return fmt.Errorf("failed to connect to Kafka: %w", lastErr)
+}
+
+func (p *kafkaPublisher) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *kafkaPublisher) failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// kafkaMessage — сообщение перевода для темы topic, где {token} заменяется адресом токена.
+func kafkaMessage(topic string, vLog types.Log, transferEvent metric.TransferEvents) (kafka.Message, error) {
+	value, err := json.Marshal(newFeedRecord(vLog, transferEvent))
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	token := strings.ToLower(vLog.Address.Hex())
+	return kafka.Message{
+		Topic: strings.ReplaceAll(topic, natsTokenPlaceholder, token),
+		Key:   []byte(token),
+		Value: value,
+	}, nil
+}
+
+func (p *kafkaPublisher) Write(vLog types.Log, transferEvent metric.TransferEvents) error {
+	if err := p.failed(); err != nil {
+		return err
+	}
+	message, err := kafkaMessage(p.topic, vLog, transferEvent)
+	if err != nil {
+		return err
+	}
+	if err := p.writer.WriteMessages(context.Background(), message); err != nil {
+		return fmt.Errorf("failed to publish to Kafka: %w", err)
+	}
+	return nil
+}
+
+// Close отправляет накопленные сообщения и возвращает первую ошибку отправки.
+func (p *kafkaPublisher) Close() error {
+	if err := p.writer.Close(); err != nil {
+		return fmt.Errorf("failed to flush Kafka writer: %w", err)
+	}
+	return p.failed()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+
+	"getBlock/metric"
+)
+
+func TestKafkaMessage(t *testing.T) {
+	vLog := transferLog(testAddress(1), testAddress(2), 7, 10, 3)
+	event := metric.TransferEvents{From: testAddress(1), To: testAddress(2), Value: big.NewInt(7)}
+	message, err := kafkaMessage("erc20.{token}", vLog, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := strings.ToLower(testToken.Hex())
+	if message.Topic != "erc20."+token || string(message.Key) != token {
+		t.Errorf("topic %s, key %s; want erc20.%s keyed by %s", message.Topic, message.Key, token, token)
+	}
+	var record feedRecord
+	if err := json.Unmarshal(message.Value, &record); err != nil {
+		t.Fatal(err)
+	}
+	if want := newFeedRecord(vLog, event); record != want {
+		t.Errorf("message = %+v, want %+v", record, want)
+	}
+}
+
+func TestKafkaPublisherInvalid(t *testing.T) {
+	// Порт, на котором никто не слушает: брокер недоступен уже на старте.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+
+	for _, tc := range []struct{ brokers, topic, want string }{
+		{"", "metric.transfers", "invalid -kafka-brokers"},
+		{"localhost", "metric.transfers", "expected host:port"},
+		{"localhost:9092", "metric transfers", "invalid -kafka-topic"},
+		{closed, "metric.transfers", "failed to connect to Kafka"},
+	} {
+		_, err := openKafkaPublisher(tc.brokers, tc.topic)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("openKafkaPublisher(%q, %q) = %v, want %q", tc.brokers, tc.topic, err, tc.want)
+		}
+	}
+}
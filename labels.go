@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const unknownCategory = "unknown"
+
+// addressLabel — запись файла -labels. Поддерживаются обе формы:
+// {"0x...": "Binance"} и {"0x...": {"name": "Binance", "category": "exchange"}}.
+type addressLabel struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+func (l *addressLabel) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		l.Category = ""
+		return json.Unmarshal(data, &l.Name)
+	}
+
+	type plain addressLabel
+	return json.Unmarshal(data, (*plain)(l))
+}
+
+func loadLabels(path string) (map[common.Address]addressLabel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read labels: %w", err)
+	}
+
+	var raw map[string]addressLabel
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse labels %s: %w", path, err)
+	}
+
+	labels := make(map[common.Address]addressLabel, len(raw))
+	for key, label := range raw {
+		normalized := strings.ToLower(strings.TrimSpace(key))
+		if !common.IsHexAddress(normalized) {
+			return nil, fmt.Errorf("invalid address %q in labels", key)
+		}
+		labels[common.HexToAddress(normalized)] = label
+	}
+	return labels, nil
+}
+
+// applyLabels подписывает адреса из файла -labels; ENS потом дополняет только неподписанные.
+func applyLabels(labels map[common.Address]addressLabel, metrics []Metric) {
+	for i := range metrics {
+		if metrics[i].Group != "" {
+			continue
+		}
+		if label, ok := labels[metrics[i].Address]; ok {
+			metrics[i].Name = label.Name
+		}
+	}
+}
+
+// parseExcludedLabels разбирает -exclude-labels: категории -labels через запятую, без учёта регистра.
+func parseExcludedLabels(raw string) map[string]bool {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+
+	excluded := make(map[string]bool)
+	for _, category := range strings.Split(raw, ",") {
+		if category = strings.ToLower(strings.TrimSpace(category)); category != "" {
+			excluded[category] = true
+		}
+	}
+	return excluded
+}
+
+// excluded — адрес подписан в -labels категорией из -exclude-labels.
+func (c *transferCounter) excluded(address common.Address) bool {
+	if len(c.opts.ExcludeLabels) == 0 {
+		return false
+	}
+	label, ok := c.opts.Labels[address]
+	return ok && c.opts.ExcludeLabels[strings.ToLower(label.Category)]
+}
+
+// filterExcluded убирает из рейтинга адреса исключённых категорий (биржи, роутеры).
+func (c *transferCounter) filterExcluded(metrics []Metric) []Metric {
+	filtered := metrics[:0]
+	for _, m := range metrics {
+		if !c.excluded(m.Address) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+func (c *transferCounter) category(address common.Address) string {
+	if label, ok := c.opts.Labels[address]; ok && label.Category != "" {
+		return label.Category
+	}
+	return unknownCategory
+}
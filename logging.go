@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logger — общий журнал программы; до setupLogging пишет текстом с уровнем info.
+// Стандартный log перенаправляется в него же через logWriter.
+var logger = slog.New(newPlainHandler(logOutput, slog.LevelInfo))
+
+// logOutput — куда пишут обработчики журнала: stderr, после разбора флагов — он же со скрытыми
+// URL, в -tui — панель.
+var logOutput = &logDestination{w: os.Stderr}
+
+type logDestination struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (d *logDestination) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.w.Write(p)
+}
+
+// setLogOutput подменяет вывод журнала и возвращает прежний.
+func setLogOutput(w io.Writer) io.Writer {
+	logOutput.mu.Lock()
+	defer logOutput.mu.Unlock()
+	previous := logOutput.w
+	logOutput.w = w
+	return previous
+}
+
+// parseLogLevel разбирает -log-level: debug, info, warn или error.
+func parseLogLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("unknown -log-level %q: use debug, info, warn or error", value)
+	}
+	return level, nil
+}
+
+// setupLogging настраивает журнал по -log-level и -log-format и направляет в него стандартный log.
+func setupLogging(level slog.Level, format string) error {
+	var handler slog.Handler
+	switch format {
+	case logFormatText:
+		handler = newPlainHandler(logOutput, level)
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(logOutput, &slog.HandlerOptions{Level: level})
+	default:
+		return fmt.Errorf("unknown -log-format %q: use text or json", format)
+	}
+	logger = slog.New(handler)
+	slog.SetDefault(logger)
+	// slog.SetDefault перенастраивает и стандартный log, поэтому он подключается после.
+	log.SetFlags(0)
+	log.SetOutput(logWriter{})
+	return nil
+}
+
+// logWriter переводит в журнал строки, которые сторонние пакеты пишут стандартным log: уровень
+// берётся из привычных префиксов сообщений ("warning: ", "error ..."), остальное — info.
+// Сама программа пишет через logger.
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	message := strings.TrimRight(string(p), "\n")
+	level := slog.LevelInfo
+	switch lower := strings.ToLower(message); {
+	case strings.HasPrefix(lower, "warning: "):
+		level, message = slog.LevelWarn, message[len("warning: "):]
+	case strings.HasPrefix(lower, "error"):
+		level = slog.LevelError
+	}
+	logger.Log(context.Background(), level, message)
+	return len(p), nil
+}
+
+// plainHandler пишет записи так же, как раньше писал стандартный log: время, сообщение и
+// атрибуты key=value; предупреждения начинаются с "warning: ".
+type plainHandler struct {
+	w      io.Writer
+	level  slog.Leveler
+	attrs  string
+	prefix string
+}
+
+func newPlainHandler(w io.Writer, level slog.Leveler) *plainHandler {
+	return &plainHandler{w: w, level: level}
+}
+
+func (h *plainHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *plainHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	}
+	if r.Level == slog.LevelWarn {
+		b.WriteString("warning: ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		writePlainAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteByte('\n')
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *plainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		writePlainAttr(&b, h.prefix, a)
+	}
+	clone := *h
+	clone.attrs += b.String()
+	return &clone
+}
+
+func (h *plainHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+func writePlainAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, attr := range a.Value.Group() {
+			writePlainAttr(b, prefix+a.Key+".", attr)
+		}
+		return
+	}
+	value := a.Value.String()
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, value)
+}
+
+// progressInterval — как часто длинный скан пишет строку о ходе работы.
+const progressInterval = 10 * time.Second
+
+// scanProgress считает ход скана [from, to]: сколько блоков уже запрошено, сколько логов
+// получено и разобрано. Оценка оставшегося времени идёт по блокам, а когда все окна
+// получены — по разобранным логам.
+type scanProgress struct {
+	mu               sync.Mutex
+	total, blocks    uint64
+	fetched, decoded int
+	started, last    time.Time
+	fetchedAt        time.Time
+	fetchedAtDecoded int
+}
+
+func newScanProgress(from, to uint64) *scanProgress {
+	now := time.Now()
+	return &scanProgress{total: to - from + 1, started: now, last: now}
+}
+
+// Fetched отмечает полученное окно блоков; у nil ничего не делает.
+func (p *scanProgress) Fetched(blocks uint64, logs int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blocks += blocks
+	p.fetched += logs
+	if p.blocks >= p.total && p.fetchedAt.IsZero() {
+		p.fetchedAt, p.fetchedAtDecoded = time.Now(), p.decoded
+	}
+	p.report()
+}
+
+// Decoded отмечает разобранный лог; у nil ничего не делает.
+func (p *scanProgress) Decoded() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decoded++
+	p.report()
+}
+
+func (p *scanProgress) report() {
+	now := time.Now()
+	if now.Sub(p.last) < progressInterval {
+		return
+	}
+	p.last = now
+
+	var eta time.Duration
+	switch {
+	case p.blocks < p.total && p.blocks > 0:
+		eta = time.Duration(float64(now.Sub(p.started)) * float64(p.total-p.blocks) / float64(p.blocks))
+	case p.blocks >= p.total && p.decoded > p.fetchedAtDecoded && p.decoded < p.fetched:
+		done := p.decoded - p.fetchedAtDecoded
+		eta = time.Duration(float64(now.Sub(p.fetchedAt)) * float64(p.fetched-p.decoded) / float64(done))
+	}
+	// Длительности строками: JSON-обработчик иначе пишет их в наносекундах.
+	attrs := []any{"blocks", p.blocks, "of", p.total, "logs", p.fetched, "decoded", p.decoded, "elapsed", now.Sub(p.started).Round(time.Second).String()}
+	if eta = eta.Round(time.Second); eta > 0 {
+		attrs = append(attrs, "eta", eta.String())
+	}
+	logger.Info("scan progress", attrs...)
+}
@@ -2,131 +2,1660 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"math/big"
 	"os"
-	"sort"
-	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/joho/godotenv"
+
+	"getBlock/metric"
+)
+
+const (
+	directionIn   = "in"
+	directionOut  = "out"
+	directionBoth = "both"
 )
 
-type TransferEvents struct {
-	From  common.Address
-	To    common.Address
-	Value *big.Int
+type scanOptions struct {
+	Direction    string
+	Decimals     bool
+	MaxLogs      int
+	ChunkSize    uint64
+	Workers      int
+	FetchRPS     float64
+	MaxTotalLogs int
+	ByTxSender   bool
+	GroupPrefix  int
+	ByToken      bool
+	Contracts    []common.Address
+	FromAny      []common.Address
+	ToAny        []common.Address
+	CountZero    bool
+	TopTokens    bool
+	TopTokensBy  string
+	// Snapshot — запоминать адреса каждого токена для балансов -snapshot.
+	Snapshot bool
+	// Wash — запоминать потоки между адресами для -wash.
+	Wash bool
+	// Graph — запоминать потоки и контрагентов для -graph.
+	Graph bool
+	// ValueStats — запоминать суммы переводов для -value-stats.
+	ValueStats bool
+	// TopSpenders — запрашивать вместе с Transfer и события Approval для рейтинга spender'ов.
+	TopSpenders bool
+	// Standard — стандарт токенов -standard: erc20, erc721 или erc1155.
+	Standard string
+
+	SuccessfulOnly bool
+
+	ApprovalSpenders []common.Address
+
+	MinCounterparties int
+
+	CountMode string
+
+	// ValueSample — доля транзакций, чьи значения суммируются (0 — все).
+	ValueSample float64
+
+	// RequestTimeout ограничивает каждый FilterLogs/HeaderByNumber (0 — без ограничения).
+	RequestTimeout time.Duration
+	// PartialOK — пропускать окна, которые не удалось получить, вместо остановки скана.
+	PartialOK bool
+
+	Labels map[common.Address]addressLabel
+	// ExcludeLabels — категории -exclude-labels (в нижнем регистре), убираемые из рейтинга.
+	ExcludeLabels   map[string]bool
+	GroupByCategory bool
+
+	DedupeLogs bool
+	// Watch — счётчик -watch: запоминает учтённые логи, чтобы откатить их при реорганизации.
+	Watch bool
+
+	// Inflow включает учёт полученных сумм и рейтинг по ним.
+	Inflow bool
+	// Sides включает раздельный учёт отправленных и полученных переводов;
+	// RankBy = sent|received строит рейтинг по одной из сторон.
+	Sides  bool
+	RankBy string
+
+	// Rate включает учёт первого и последнего блока адресов для поля rate.
+	Rate bool
+	// TxCounts включает учёт различных транзакций адресов для полей txs и per_tx.
+	TxCounts bool
+	// Gas — учитывать транзакции адресов, чтобы после рейтинга запросить их газ для -gas.
+	Gas bool
+
+	// Volume включает раздельный учёт отправленного и полученного объёма (-sort volume).
+	Volume bool
+	// Prices — источник цен -price-source для USD-объёма; PriceInterval — блоков на одну цену.
+	Prices        priceSource
+	PriceInterval uint64
+
+	// Store — файл -store с результатами прошлых прогонов; nil, если не задан.
+	Store *metricStore
+
+	// Detail — адрес -detail, для которого сохраняются учтённые переводы; nil, если не задан.
+	Detail *common.Address
+	// MinValue — порог -min-value в единицах токена; переводы не меньше него попадают в отчёт о китах.
+	MinValue *big.Rat
+
+	// FromBlock и ToBlock — явные границы диапазона; nil — от -lookback и до последнего блока.
+	FromBlock, ToBlock *big.Int
+
+	// Lookback — сколько последних блоков сканировать; LookbackDuration, если задан,
+	// заменяет его окном по времени, BlockTime — средний интервал блоков для оценки.
+	Lookback         uint64
+	LookbackDuration time.Duration
+	BlockTime        time.Duration
+
+	// Feed — лента -follow-logs; nil, если не задана.
+	Feed *transferFeed
+	// Export — выгрузка -parquet-dir; nil, если не задана.
+	Export *parquetSink
+	// Publish — публикация -nats-url; nil, если не задана.
+	Publish *natsPublisher
+	// Kafka — публикация -kafka-brokers; nil, если не задана.
+	Kafka *kafkaPublisher
+
+	// Events — события из -abi и -abi-dir; если заданы, считаются они вместо Transfer.
+	Events *eventRegistry
+	// TopEvents — собирать сводку по каждому событию для -top-events.
+	TopEvents bool
+	// Bucket — окно -bucket: block, hour или day; пусто — не считать активность по блокам.
+	Bucket string
+	// Growth — считать различные активные адреса и, с Store, новые среди них для -growth.
+	Growth bool
+
+	// Window — ширина окна в блоках для временного ряда -format grafana (0 — не считать).
+	Window uint64
+
+	EnrichConcurrency int
+	EnrichRPS         float64
+
+	Decay         string
+	DecayHalfLife float64
+
+	TokenRegistry map[common.Address]tokenInfo
+	// TokenMetadata — запоминать встреченные токены для -token-metadata.
+	TokenMetadata bool
 }
 
-type Metric struct {
-	Address common.Address
-	Count   int
+// Metric — строка рейтинга из пакета metric, на котором построен CLI.
+type Metric = metric.Metric
+
+// transferTopics строит фильтр топиков: topic0 — сигнатура Transfer, topic1 — from, topic2 — to.
+// В режиме -approvals-to: topic0 — Approval, topic2 — spender.
+// Индексированные адреса в топиках хранятся дополненными нулями слева до 32 байт.
+func (opts scanOptions) transferTopics() [][]common.Hash {
+	if opts.Events != nil {
+		return opts.Events.Topics()
+	}
+	if len(opts.ApprovalSpenders) > 0 {
+		return [][]common.Hash{{approvalEventHash}, nil, addressTopics(opts.ApprovalSpenders)}
+	}
+	if opts.Standard == standardERC721 || opts.Standard == standardERC1155 {
+		return opts.nftTopics()
+	}
+
+	topics := [][]common.Hash{{metric.TransferEventHash}}
+	if opts.TopSpenders {
+		topics[0] = append(topics[0], approvalEventHash)
+	}
+	if len(opts.FromAny) == 0 && len(opts.ToAny) == 0 {
+		return topics
+	}
+
+	topics = append(topics, addressTopics(opts.FromAny))
+	if len(opts.ToAny) > 0 {
+		topics = append(topics, addressTopics(opts.ToAny))
+	}
+	return topics
+}
+
+func addressTopics(addresses []common.Address) []common.Hash {
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	topics := make([]common.Hash, 0, len(addresses))
+	for _, address := range addresses {
+		topics = append(topics, common.BytesToHash(address.Bytes()))
+	}
+	return topics
 }
 
 func main() {
-	err := godotenv.Load()
+	// go-ethereum/log в init ставит slog по умолчанию с DiscardHandler, а вместе с ним
+	// глушится и стандартный log; до разбора -log-level пишем в stderr с уровнем info.
+	if err := setupLogging(slog.LevelInfo, logFormatText); err != nil {
+		os.Exit(exitStatus(err))
+	}
+	os.Exit(exitStatus(run(os.Args[1:])))
+}
+
+// run разбирает флаги и выполняет скан. Ошибки возвращаются, а не завершают процесс, чтобы
+// отложенные закрытия (-out, -parquet-dir, -follow-logs, -nats-url, -kafka-brokers, профили)
+// успели выполниться до выхода из main.
+func run(args []string) error {
+	configPath := flag.String("config", "", "YAML or TOML (.toml) file with default flag values; METRIC_* environment variables and command-line flags take precedence")
+	rpcURL := flag.String("rpc-url", "", "RPC endpoint (default https://go.getblock.io/$ETH_API_KEY, or the endpoint of -chain); several comma-separated http(s) URLs are used round-robin with failover")
+	healthInterval := flag.Duration("health-interval", 30*time.Second, "how often RPC endpoints of -rpc-url taken out of rotation after a failure are checked again")
+	chainName := flag.String("chain", "", "EVM network: ethereum, polygon, bsc, arbitrum, base or a chains entry of -config; selects the RPC endpoint, checks its chain ID and tags the output")
+	addressesOnly := flag.Bool("addresses-only", false, "print only ranked addresses, one per line")
+	var headers headerFlags
+	flag.Var(&headers, "rpc-header", "extra HTTP header for RPC requests as \"Name: Value\" (repeatable)")
+	direction := flag.String("direction", directionBoth, "count transfers by role: in, out or both")
+	standard := flag.String("standard", standardERC20, "token standard to rank: erc20, erc721 (Transfer with tokenId) or erc1155 (TransferSingle/TransferBatch)")
+	priceSourceName := flag.String("price-source", "", "with -decimals, convert transfer values to USD at block time using chainlink (on-chain feeds from -price-feeds) or coingecko")
+	priceFeeds := flag.String("price-feeds", "", "for -price-source chainlink: JSON file mapping token addresses to their Chainlink TOKEN/USD feeds")
+	priceInterval := flag.Uint64("price-interval", 300, "number of blocks that share one USD price lookup per token")
+	coinGeckoURL := flag.String("coingecko-url", defaultCoinGeckoURL, "CoinGecko API base URL for -price-source coingecko (key from COINGECKO_API_KEY)")
+	coinGeckoPlatform := flag.String("coingecko-platform", "", "CoinGecko platform id of the scanned chain (default follows -chain, ethereum without it)")
+	decimals := flag.Bool("decimals", false, "look up token decimals and show raw and decimal-adjusted value sums")
+	watchLogs := flag.Bool("watch", false, "keep running and update the ranking from new blocks via a log subscription")
+	flag.BoolVar(watchLogs, "follow", false, "alias of -watch")
+	serveMetricsAddr := flag.String("serve-metrics", "", "serve Prometheus metrics of the latest ranking on http://ADDR/metrics (e.g. :9090) and keep running until interrupted")
+	grpcAddr := flag.String("grpc", "", "with -serve-api, also serve the gRPC service from metric.proto on ADDR (e.g. :9090); requires -grpc-cert and -grpc-key")
+	grpcCert := flag.String("grpc-cert", "", "for -grpc: TLS certificate file (PEM)")
+	grpcKey := flag.String("grpc-key", "", "for -grpc: TLS private key file (PEM)")
+	serveAPIAddr := flag.String("serve-api", "", "instead of a single scan, serve a JSON REST API on ADDR (e.g. :8080): GET /metrics/top?n=&from=&to= and GET /address/{addr}?from=&to=")
+	storePath := flag.String("store", "", "keep per-block per-address counts in this SQLite database (a JSON file if the name ends in .json) and on later runs scan only the blocks after the last stored one")
+	interval := flag.Duration("interval", 0, "daemon mode: repeat the scan every interval over the blocks produced since the last run, appending them to -store")
+	watchEvery := flag.Int("watch-every", 1, "in -watch mode print the refreshed ranking every N blocks with transfers")
+	wsURL := flag.String("ws-url", "", "ws(s) RPC endpoint used for -watch subscriptions")
+	proxy := flag.String("proxy", "", "route RPC connections through this proxy: http://host:port or socks5://[user:pass@]host:port")
+	maxLogs := flag.Int("max-logs", 0, "abort if a single FilterLogs window returns more than N logs (0 = no limit)")
+	workers := flag.Int("workers", 1, "number of -chunk-size windows fetched in parallel")
+	partialOK := flag.Bool("partial-ok", false, "with -chunk-size, skip windows that still fail after retries and rank the rest, listing the missing block ranges (default: stop the scan)")
+	rpcRetries := flag.Int("rpc-retries", 3, "retry an HTTP RPC request this many times on network errors, 429 and 5xx responses (0 = fail at once)")
+	rpcBackoff := flag.Duration("rpc-backoff", 500*time.Millisecond, "base delay of the exponential backoff between -rpc-retries (randomized, doubled per attempt)")
+	rpcBackoffMax := flag.Duration("rpc-backoff-max", 30*time.Second, "upper bound of one -rpc-retries delay")
+	rpcRPS := flag.Float64("rpc-rps", 0, "limit all HTTP RPC requests, including retries, to N per second (0 = no limit)")
+	fetchRPS := flag.Float64("fetch-rps", 0, "limit FilterLogs requests of the scan to N per second across all -workers (0 = no limit)")
+	chunkSize := flag.Uint64("chunk-size", 0, "fetch logs in windows of N blocks (0 = windows of 2000 blocks); windows over the provider's limit are split in half automatically")
+	maxTotalLogs := flag.Int("max-total-logs", 0, "abort once more than N logs were processed in total (0 = no limit)")
+	format := flag.String("format", formatText, "output format: text, markdown, json, csv, report (one JSON object with block range and timestamp), bars (ASCII bar chart) or grafana (JSON time series of the top addresses)")
+	barWidth := flag.Int("bar-width", 40, "for -format bars: width of the longest bar in characters")
+	shortAddr := flag.Bool("short-addr", false, "abbreviate addresses as 0x1234…abcd in text, markdown and bars output")
+	tui := flag.Bool("tui", false, "in -watch mode show a live dashboard (ranking, block height, transfers per second, top tokens, recent log lines) instead of printing the ranking")
+	gasFlag := flag.Bool("gas", false, "also fetch receipts of the printed addresses' transactions and report the gas used and fees paid in the native coin for the ones they sent")
+	txCounts := flag.Bool("tx-counts", false, "also count distinct transactions per address and the average transfers per transaction (fields txs and per_tx)")
+	growthFlag := flag.Bool("growth", false, "also print the number of distinct active addresses in the range and, with -store, how many of them were never seen before")
+	bucket := flag.String("bucket", "", "also print transfers and active addresses per block, hour or day (UTC) after the ranking, for activity curves")
+	grafanaWindow := flag.Uint64("grafana-window", 10, "for -format grafana: number of blocks per datapoint")
+	fieldList := flag.String("fields", "", "comma-separated columns and their order for tabular and JSON output: address,name,count,score,raw_value,value,inflow")
+	watchlist := flag.String("watchlist", "", "comma-separated addresses to alert on, optionally with per-address thresholds: 0xaddr=N")
+	alertThreshold := flag.Int("alert-threshold", 0, "default transfer count that triggers a watchlist alert")
+	byTxSender := flag.Bool("by-tx-sender", false, "attribute each transfer to the account that sent its transaction (one extra RPC call per transaction)")
+	groupPrefix := flag.Int("group-prefix", 0, "aggregate addresses by their first N hex characters instead of ranking exact addresses (1-40)")
+	tokenMetadataFlag := flag.Bool("token-metadata", false, "call symbol(), name() and decimals() on every token seen in the logs and label tokens in -by-token, -top-tokens, -detail and -format report")
+	tokenCache := flag.String("token-cache", defaultTokenCachePath(), "JSON file caching -token-metadata between runs (empty = no cache)")
+	tokenRegistry := flag.String("token-registry", "", "JSON file with token symbols and decimals; on-chain decimals() is only called for unknown tokens")
+	byToken := flag.Bool("by-token", false, "rank (address, token) pairs instead of addresses")
+	resolveProxy := flag.Bool("resolve-proxy", false, "with -by-token, note the EIP-1967 implementation behind proxied token contracts")
+	ens := flag.Bool("ens", false, "show ENS reverse-resolved names next to ranked addresses (mainnet only)")
+	ensCache := flag.String("ens-cache", defaultENSCachePath(), "JSON file caching -ens names, including addresses without one, between runs (empty = no cache)")
+	ensCacheTTL := flag.Duration("ens-cache-ttl", 24*time.Hour, "how long a cached -ens result is trusted before it is looked up again (0 = forever)")
+	contractList := flag.String("contracts", "", "comma-separated token contracts; the node returns only logs emitted by these contracts")
+	fromAny := flag.String("from-any", "", "comma-separated senders; the node returns only transfers from these addresses")
+	toAny := flag.String("to-any", "", "comma-separated recipients; the node returns only transfers to these addresses")
+	countZero := flag.Bool("count-zero", false, "include the zero address (mint source / burn target) in the ranking")
+	valueStats := flag.Bool("value-stats", false, "also report the distribution of transfer values per token: min, max, mean, median, p90, p99 and a histogram by powers of ten (keeps every value in memory)")
+	wash := flag.Bool("wash", false, "after the ranking, flag addresses whose transfers mostly go to themselves or come straight back (A->B->A) with a suspicion score")
+	washThreshold := flag.Float64("wash-threshold", defaultWashThreshold, "for -wash: minimum share (0-1) of self-transfers and round trips to report an address")
+	graphPath := flag.String("graph", "", "write the counterparty graph of the top -graph-top addresses (their transfers with every counterparty, weighted by transfer count) to this DOT or GraphML file")
+	graphFormat := flag.String("graph-format", "", "format of -graph: dot or graphml (default follows the file extension: .graphml is GraphML, anything else DOT)")
+	graphTop := flag.Int("graph-top", defaultGraphTop, "for -graph: number of top ranked addresses whose counterparties are included")
+	compare := flag.Bool("compare", false, "after the ranking, compare the scanned range with the range of the same length right before it (or -compare-from/-compare-to): per-address and per-token changes")
+	compareFrom := flag.String("compare-from", "", "for -compare: first block of the range to compare with")
+	compareTo := flag.String("compare-to", "", "for -compare: last block of the range to compare with")
+	snapshot := flag.Bool("snapshot", false, "after the scan, query the balances of every scanned address in each token at the end block via Multicall3 and print the top holders")
+	multicallFlag := flag.String("multicall", defaultMulticall, "for -snapshot: address of the Multicall3 contract")
+	snapshotBatch := flag.Int("snapshot-batch", defaultSnapshotBatch, "for -snapshot: balanceOf calls per aggregate3 call")
+	topTokens := flag.Bool("top-tokens", false, "also rank token contracts by the events they emitted and the distinct addresses that used them (keeps a set per token)")
+	topSpenders := flag.Bool("top-spenders", false, "also fetch ERC20 Approval events and rank spenders (routers, bridges) by the approvals they received")
+	topTokensBy := flag.String("top-tokens-by", tokensByAddresses, "ranking key of -top-tokens: addresses (distinct participants) or transfers (emitted events)")
+	breakerThreshold := flag.Int("breaker-threshold", 0, "stop sending RPC requests after N consecutive failures (0 = disabled)")
+	breakerCooldown := flag.Duration("breaker-cooldown", 30*time.Second, "how long the circuit breaker stays open before a trial request")
+	logsFile := flag.String("logs-file", "", "count a JSON array of logs (eth_getLogs format) from this file instead of querying RPC")
+	decay := flag.String("decay", "", "rank by a recency-weighted score: linear or exp (see -decay-half-life)")
+	decayHalfLife := flag.Float64("decay-half-life", 25, "for -decay exp: number of blocks after which a transfer's weight halves")
+	successfulOnly := flag.Bool("successful-only", false, "skip logs of transactions whose receipt status is not successful (one receipt call per transaction)")
+	rankOf := flag.String("rank-of", "", "print only the rank and count of this address")
+	minValue := flag.String("min-value", "", "also report every single transfer worth at least this many token units (needs -decimals) with tx hash, token, from and to")
+	detail := flag.String("detail", "", "instead of the ranking, list every transfer that was counted for this address, sorted by block")
+	outPath := flag.String("out", "", "write the ranking to this file instead of stdout")
+	gzipOut := flag.Bool("gzip", false, "gzip-compress the -out file (enabled automatically for .gz paths)")
+	approvalsTo := flag.String("approvals-to", "", "rank owners by Approval events granted to any of these comma-separated spenders instead of counting transfers")
+	enrichConcurrency := flag.Int("enrich-concurrency", 8, "parallel RPC calls for decimals, receipts, transaction senders and ENS lookups")
+	enrichRPS := flag.Float64("enrich-rps", 0, "limit enrichment RPC calls per second across all workers (0 = unlimited)")
+	explain := flag.String("explain", "", "print a detailed decode of the Transfer logs of this transaction hash and exit")
+	histogram := flag.Bool("histogram", false, "instead of ranking, report how many addresses fall into each transfer-count bin")
+	histogramBins := flag.String("histogram-bins", defaultHistogramBins, "for -histogram: comma-separated increasing upper bounds of the bins; the last bin is open-ended")
+	atHash := flag.String("at-hash", "", "scan only the block with this hash instead of the latest 100 blocks")
+	minCounterparties := flag.Int("min-counterparties", 0, "drop addresses that exchanged transfers with fewer than N distinct counterparties")
+	sortBy := flag.String("sort", sortCount, "order of the printed top entries: count (alias total), value, volume, score, rate or address (byte-wise, for stable diffs); inflow, sent and received rank the whole scan by received value, sent or received transfers")
+	flag.StringVar(sortBy, "sort-by", sortCount, "alias of -sort")
+	sortSecondary := flag.String("sort-secondary", "", "sort key that breaks ties of -sort; remaining ties are broken by address")
+	order := flag.String("order", "", "asc or desc; default is desc for -sort count and asc for -sort address")
+	abiDir := flag.String("abi-dir", "", "count every non-anonymous event from the ABI JSON files in this directory by its indexed address parameters instead of Transfer")
+	abiFiles := flag.String("abi", "", "comma-separated ABI JSON files or directories whose events are counted like -abi-dir (both can be combined)")
+	eventNames := flag.String("events", "", "with -abi or -abi-dir, count only these comma-separated events (name like Swap or full signature)")
+	eventSums := flag.String("event-sum", "", "with -abi or -abi-dir, comma-separated Event.param integer parameters summed per event in -top-events (e.g. Swap.amount0In,Deposit.amount)")
+	topEvents := flag.Bool("top-events", false, "with -abi or -abi-dir, also list every event with its log count, emitting contracts, distinct addresses and -event-sum totals")
+	supply := flag.Bool("supply", false, "also print minted (from 0x0) and burned (to 0x0) value and the net issuance, in total and per token")
+	topShare := flag.Float64("top-share", 0, "instead of the top 5, print the fewest top addresses that together reach this fraction of all counted transfers (e.g. 0.8)")
+	lookback := flag.Uint64("lookback", defaultLookback, "number of latest blocks to scan, including the head")
+	flag.Uint64Var(lookback, "last-n", defaultLookback, "alias of -lookback")
+	fromBlockFlag := flag.String("from-block", "", "first block of the range; default is -lookback blocks before -to-block")
+	toBlockFlag := flag.String("to-block", blockLatest, "last block of the range, inclusive: a number or latest")
+	countMode := flag.String("count-mode", countModeSum, "rank value per address: sum of sent and received transfers, or max of the two")
+	followLogs := flag.String("follow-logs", "", "append every decoded transfer as a JSON line to this file as it is counted")
+	natsURL := flag.String("nats-url", "", "publish every decoded transfer as a JSON message to this NATS server (nats://[user:pass@|token@]host[:port])")
+	natsSubject := flag.String("nats-subject", "metric.transfers", "for -nats-url: subject to publish to; {token} is replaced with the token address")
+	kafkaBrokers := flag.String("kafka-brokers", "", "publish every decoded transfer as a JSON message to Kafka through these brokers (host:port[,host:port])")
+	kafkaTopic := flag.String("kafka-topic", "metric.transfers", "for -kafka-brokers: topic to publish to; {token} is replaced with the token address")
+	parquetDir := flag.String("parquet-dir", "", "write every decoded transfer (block, timestamp, tx hash, log index, token, from, to, value) to Parquet files in this directory")
+	parquetRows := flag.Int("parquet-rows", defaultParquetRows, "for -parquet-dir: rows per Parquet file; a new file is started once a file is full")
+	logRotateSize := flag.Int64("log-rotate-size", 0, "for -follow-logs: rotate the file to FILE.1 once it would exceed this many bytes (0 = never)")
+	includePending := flag.Bool("include-pending", false, "experimental: also print a separate, tentative ranking of transfers the node predicts for pending transactions")
+	valueSample := flag.Float64("value-sample", 0, "with -decimals: sum values of only this fraction of transactions (chosen by tx hash) and extrapolate; counts still use every log")
+	timeout := flag.Duration("timeout", 0, "abort the whole run after this long (0 = no limit)")
+	requestTimeout := flag.Duration("request-timeout", 0, "fail a single FilterLogs or HeaderByNumber call after this long (0 = no limit)")
+	baselinePath := flag.String("baseline", "", "compare the ranking with one saved earlier by -format json or report: new, dropped and changed addresses")
+	labelsPath := flag.String("labels", "", "JSON file naming addresses: {\"0x...\": \"Binance\"} or {\"0x...\": {\"name\": \"Binance\", \"category\": \"exchange\"}}")
+	excludeLabels := flag.String("exclude-labels", "", "comma-separated -labels categories (e.g. exchange,router) whose addresses are left out of the ranking")
+	groupByCategory := flag.Bool("group-by-category", false, "aggregate counts per -labels category; unlabeled addresses go to \"unknown\"")
+	dedupeLogs := flag.Bool("dedupe-logs", false, "count a log repeated by the provider (same tx hash and log index) only once")
+	eoaOnly := flag.Bool("eoa-only", false, "keep only externally owned accounts (addresses without code; one eth_getCode per ranked address)")
+	contractsOnly := flag.Bool("contracts-only", false, "keep only contracts (addresses with code; one eth_getCode per ranked address)")
+	audit := flag.Bool("audit", false, "write a JSON audit record (queries sent, logs per query, redacted provider URL, stats) to OUT.audit.json, or to stderr without -out")
+	lookbackDuration := flag.Duration("lookback-duration", 0, "scan the blocks of this last period (e.g. 2h) instead of -lookback blocks")
+	blockTime := flag.Duration("block-time", 12*time.Second, "average block time used to estimate -lookback-duration when the timestamp search fails")
+	watchlistFile := flag.String("watchlist-file", "", "in -watch mode, notify about every new transfer of the addresses in this file (one per line, optionally followed by a label)")
+	notifyWebhook := flag.String("notify-webhook", "", "URL that receives a JSON POST for every -watchlist-file transfer")
+	telegramChat := flag.String("telegram-chat", "", "Telegram chat id that receives -watchlist-file transfers from the bot in TELEGRAM_BOT_TOKEN")
+	alertExit := flag.Bool("alert-exit", false, "exit with status 2 when a watchlist alert fires")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the scan to this file")
+	traceFile := flag.String("trace", "", "write a runtime execution trace of the scan to this file")
+	logLevel := flag.String("log-level", "info", "minimum level of log messages on stderr: debug, info, warn or error")
+	logFormat := flag.String("log-format", logFormatText, "format of log messages on stderr: text or json (one object per line with time, level, msg and attributes)")
+	showVersion := flag.Bool("version", false, "print version information and exit")
+	flags, cmd, err := parseCommandLine(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
 	if err != nil {
-		log.Fatal("error loading .env file")
+		return err
 	}
-	ctx := context.Background()
 
-	apiKey := os.Getenv("ETH_API_KEY")
-	url := fmt.Sprintf("https://go.getblock.io/%s", apiKey)
+	if *showVersion {
+		fmt.Println(versionString())
+		return nil
+	}
 
-	client, err := ethclient.DialContext(ctx, url)
+	// .env читается до METRIC_*, чтобы переопределения можно было держать и в нём.
+	envErr := godotenv.Load()
+	if err := applyEnv(flags); err != nil {
+		return err
+	}
+	var configuredChains map[string]chainConfig
+	if *configPath != "" {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		if err := applyConfig(flags, cfg); err != nil {
+			return err
+		}
+		configuredChains = cfg.Chains
+	}
+	if cmd.finish != nil {
+		if err := cmd.finish(flags); err != nil {
+			return err
+		}
+	}
+	level, err := parseLogLevel(*logLevel)
 	if err != nil {
-		log.Fatal("error in dialing Ethereum client")
-		return
+		return err
+	}
+	if err := setupLogging(level, *logFormat); err != nil {
+		return err
+	}
+
+	var chain chainInfo
+	if *chainName != "" {
+		resolved, endpoint, err := resolveChain(*chainName, configuredChains)
+		if err != nil {
+			return fmt.Errorf("invalid -chain: %v", err)
+		}
+		chain = resolved
+		if *rpcURL == "" {
+			*rpcURL = endpoint.RPCURL
+		}
+		if *wsURL == "" {
+			*wsURL = endpoint.WSURL
+		}
 	}
 
-	metrics, err := currentBlock(ctx, client)
+	switch *format {
+	case formatText, formatMarkdown, formatJSON, formatCSV, formatReport:
+	case formatBars:
+		if *barWidth < 1 {
+			return fmt.Errorf("invalid -bar-width %d: expected at least 1", *barWidth)
+		}
+	case formatGrafana:
+		if *grafanaWindow == 0 {
+			return errors.New("invalid -grafana-window 0: expected at least 1 block")
+		}
+		if *logsFile != "" {
+			return errors.New("-format grafana needs block timestamps from RPC and cannot be combined with -logs-file")
+		}
+		if *addressesOnly || *byToken || *topTokens || *groupPrefix > 0 || *histogram || *rankOf != "" {
+			return errors.New("-format grafana cannot be combined with -addresses-only, -by-token, -top-tokens, -group-prefix, -histogram or -rank-of")
+		}
+	default:
+		return fmt.Errorf("invalid -format %q: expected text, markdown, json, csv, report, bars or grafana", *format)
+	}
+
+	if *groupPrefix < 0 || *groupPrefix > 40 {
+		return fmt.Errorf("invalid -group-prefix %d: expected 1-40", *groupPrefix)
+	}
+
+	fields, err := parseFields(*fieldList)
 	if err != nil {
-		fmt.Printf("error in currenBlock:%v", err)
+		return fmt.Errorf("invalid -fields: %v", err)
+	}
+	if len(fields) > 0 && *byToken {
+		return errors.New("-fields cannot be combined with -by-token")
 	}
 
-	for i := 0; i < 5; i++ {
-		fmt.Printf("address %v used ERC20 %v times\n", metrics[i].Address, metrics[i].Count)
+	if *sortBy == sortTotal {
+		*sortBy = sortCount
+	}
+	if *sortSecondary == sortTotal {
+		*sortSecondary = sortCount
+	}
+	if err := validateSort(*sortBy, *sortSecondary, *order); err != nil {
+		return err
+	}
+	if *sortSecondary == sortInflow {
+		return errors.New("inflow ranks the whole scan and can only be the primary -sort")
+	}
+	withEvents := *abiDir != "" || *abiFiles != ""
+	if !withEvents && (*eventNames != "" || *eventSums != "" || *topEvents) {
+		return errors.New("-events, -event-sum and -top-events need -abi or -abi-dir")
+	}
+	withSides := *sortBy == sortSent || *sortBy == sortReceived || *sortSecondary == sortSent || *sortSecondary == sortReceived || hasField(fields, "sent") || hasField(fields, "received")
+	if withSides && (*direction != directionBoth || *byTxSender || *groupPrefix > 0 || *groupByCategory || *byToken || *approvalsTo != "" || withEvents || *decay != "") {
+		return errors.New("sent and received counts need both directions and cannot be combined with -direction, -by-tx-sender, -group-prefix, -group-by-category, -by-token, -approvals-to, -abi-dir or -decay")
+	}
+	withRate := *sortBy == sortRate || *sortSecondary == sortRate || hasField(fields, "rate")
+	if withRate && (*groupPrefix > 0 || *groupByCategory || *byToken) {
+		return errors.New("rate is tracked per address and cannot be combined with -group-prefix, -group-by-category or -by-token")
+	}
+
+	withTxs := *txCounts || *sortBy == sortTxs || *sortSecondary == sortTxs || hasField(fields, "txs") || hasField(fields, "per_tx")
+	if withTxs && (*groupPrefix > 0 || *groupByCategory || *byToken || *storePath != "") {
+		return errors.New("transaction counts are tracked per address in this run and cannot be combined with -group-prefix, -group-by-category, -by-token or -store")
+	}
+
+	withGas := *gasFlag || *sortBy == sortFee || *sortSecondary == sortFee || hasField(fields, "gas_used") || hasField(fields, "fee")
+	if withGas && (*logsFile != "" || *groupPrefix > 0 || *groupByCategory || *byToken || *storePath != "") {
+		return errors.New("gas is fetched from receipts for the printed addresses and cannot be combined with -logs-file, -group-prefix, -group-by-category, -by-token or -store")
+	}
+
+	withVolume := *sortBy == sortVolume || *sortSecondary == sortVolume || hasField(fields, "sent_value") || hasField(fields, "received_value")
+	if withVolume && !*decimals {
+		return errors.New("volume is normalized by token decimals and requires -decimals")
+	}
+	if withVolume && (*groupPrefix > 0 || *groupByCategory || *byToken || *byTxSender || *valueSample > 0) {
+		return errors.New("volume is tracked per sender and recipient and cannot be combined with -group-prefix, -group-by-category, -by-token, -by-tx-sender or -value-sample")
 	}
-}
 
-func currentBlock(ctx context.Context, client *ethclient.Client) ([]Metric, error) {
-	block, err := client.HeaderByNumber(ctx, nil)
+	if err := validatePriceSource(*priceSourceName); err != nil {
+		return err
+	}
+	withUSD := *priceSourceName != ""
+	if !withUSD && (*sortBy == sortUSD || *sortSecondary == sortUSD || hasField(fields, "usd")) {
+		return errors.New("USD values need -price-source")
+	}
+	if withUSD && !*decimals {
+		return errors.New("-price-source converts decimal-adjusted values and requires -decimals")
+	}
+	if withUSD && (*logsFile != "" || *groupPrefix > 0 || *groupByCategory || *byToken || *byTxSender || *valueSample > 0 || *priceInterval == 0) {
+		return errors.New("-price-source needs RPC, a -price-interval of at least 1 block and cannot be combined with -logs-file, -group-prefix, -group-by-category, -by-token, -by-tx-sender or -value-sample")
+	}
+	if *coinGeckoPlatform == "" {
+		*coinGeckoPlatform = "ethereum"
+		if chain.Name != "" {
+			*coinGeckoPlatform = coinGeckoPlatforms[chain.Name]
+		}
+	}
 
+	if *sortBy == sortInflow && (*direction == directionOut || *byTxSender || *groupPrefix > 0 || *groupByCategory || *approvalsTo != "" || withEvents || *decay != "") {
+		return errors.New("-sort inflow cannot be combined with -direction out, -by-tx-sender, -group-prefix, -group-by-category, -approvals-to, -abi-dir or -decay")
+	}
+
+	if *topShare < 0 || *topShare > 1 {
+		return fmt.Errorf("invalid -top-share %v: expected a fraction in (0, 1]", *topShare)
+	}
+	if *topShare > 0 && *decay != "" {
+		return errors.New("-top-share is based on transfer counts and cannot be combined with -decay")
+	}
+
+	switch *countMode {
+	case countModeSum:
+	case countModeMax:
+		if *direction != directionBoth || *byTxSender || *groupPrefix > 0 || *approvalsTo != "" || withEvents {
+			return errors.New("-count-mode max needs both directions and cannot be combined with -direction, -by-tx-sender, -group-prefix, -approvals-to or -abi-dir")
+		}
+	default:
+		return fmt.Errorf("invalid -count-mode %q: expected sum or max", *countMode)
+	}
+
+	if *logRotateSize < 0 {
+		return fmt.Errorf("invalid -log-rotate-size %d", *logRotateSize)
+	}
+	if *parquetRows <= 0 {
+		return errors.New("-parquet-rows must be positive")
+	}
+	if *parquetDir != "" && *logsFile != "" {
+		return errors.New("-parquet-dir needs block timestamps from RPC and cannot be combined with -logs-file")
+	}
+
+	if *logRotateSize > 0 && *followLogs == "" {
+		return errors.New("-log-rotate-size requires -follow-logs")
+	}
+
+	if *includePending && (*logsFile != "" || *atHash != "" || *format == formatGrafana || *byToken || *histogram || *rankOf != "") {
+		return errors.New("-include-pending cannot be combined with -logs-file, -at-hash, -format grafana, -by-token, -histogram or -rank-of")
+	}
+
+	if *valueSample < 0 || *valueSample > 1 {
+		return fmt.Errorf("invalid -value-sample %v: expected a fraction in (0, 1]", *valueSample)
+	}
+	if *valueSample > 0 && (!*decimals || *byToken || *groupPrefix > 0 || *supply) {
+		return errors.New("-value-sample requires -decimals and cannot be combined with -by-token, -group-prefix or -supply")
+	}
+
+	if *lookbackDuration < 0 || *blockTime <= 0 {
+		return errors.New("invalid -lookback-duration or -block-time: expected positive durations")
+	}
+
+	if *lookback == 0 {
+		return errors.New("invalid -lookback 0: expected at least 1 block")
+	}
+
+	fromBlock, err := parseBlockFlag(*fromBlockFlag)
+	if err == nil && fromBlock == nil && *fromBlockFlag != "" {
+		err = errors.New("expected a block number")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve the latest block header: %v", err)
+		return fmt.Errorf("invalid -from-block: %v", err)
+	}
+	toBlock, err := parseBlockFlag(*toBlockFlag)
+	if err != nil {
+		return fmt.Errorf("invalid -to-block: %v", err)
+	}
+	if fromBlock != nil && toBlock != nil && fromBlock.Cmp(toBlock) > 0 {
+		return fmt.Errorf("invalid range: -from-block %v is after -to-block %v", fromBlock, toBlock)
+	}
+	if fromBlock != nil && *lookbackDuration > 0 {
+		return errors.New("-from-block and -lookback-duration cannot be combined")
+	}
+	if (fromBlock != nil || toBlock != nil) && (*logsFile != "" || *atHash != "") {
+		return errors.New("-from-block and -to-block cannot be combined with -logs-file or -at-hash")
+	}
+	if *rpcRetries < 0 || *rpcBackoff < 0 || *rpcBackoffMax < *rpcBackoff || *rpcRPS < 0 {
+		return errors.New("invalid -rpc-retries, -rpc-backoff, -rpc-backoff-max or -rpc-rps: expected non-negative values and -rpc-backoff-max >= -rpc-backoff")
+	}
+	if *workers < 1 || *fetchRPS < 0 {
+		return errors.New("invalid -workers or -fetch-rps: expected -workers >= 1 and -fetch-rps >= 0")
+	}
+	if *workers > 1 && *chunkSize == 0 {
+		return errors.New("-workers fetches -chunk-size windows in parallel and needs -chunk-size")
+	}
+	if *partialOK && (*chunkSize == 0 || *storePath != "") {
+		return errors.New("-partial-ok skips failed -chunk-size windows: it needs -chunk-size and cannot be combined with -store, whose checkpoints would step over the gaps")
 	}
 
-	latestBlockNumber := block.Number
-	blockNumber := new(big.Int).Sub(latestBlockNumber, big.NewInt(int64(99)))
+	if *grpcAddr != "" && *serveAPIAddr == "" {
+		return errors.New("-grpc requires -serve-api")
+	}
+	if *grpcAddr != "" && (*grpcCert == "" || *grpcKey == "") {
+		return errors.New("-grpc requires -grpc-cert and -grpc-key: gRPC runs over HTTP/2, which is served over TLS only")
+	}
+	if (*grpcCert != "" || *grpcKey != "") && *grpcAddr == "" {
+		return errors.New("-grpc-cert and -grpc-key require -grpc")
+	}
 
-	transferEventABI := `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+	if *serveAPIAddr != "" && (*logsFile != "" || *atHash != "" || *watchLogs || *followLogs != "" || *serveMetricsAddr != "" || *audit) {
+		return errors.New("-serve-api scans per request and cannot be combined with -logs-file, -at-hash, -watch, -follow-logs, -serve-metrics or -audit")
+	}
 
-	transferEventSignature := []byte("Transfer(address,address,uint256)")
-	transferEventHash := crypto.Keccak256Hash(transferEventSignature)
+	if *storePath != "" && (*fromBlockFlag != "" || *logsFile != "" || *atHash != "" || *watchLogs || *serveAPIAddr != "" || *byTxSender || *byToken || *decay != "" || *countMode != countModeSum ||
+		*minCounterparties > 0 || *valueSample > 0 || *approvalsTo != "" || withEvents || *fromAny != "" || *toAny != "" || *format == formatGrafana) {
+		return errors.New("-store keeps plain per-address counts and cannot be combined with -from-block, -logs-file, -at-hash, -watch, -serve-api, -by-tx-sender, -by-token, -decay, " +
+			"-count-mode max, -min-counterparties, -value-sample, -approvals-to, -abi-dir, -from-any, -to-any or -format grafana")
+	}
 
-	query := ethereum.FilterQuery{
-		FromBlock: blockNumber,
-		ToBlock:   latestBlockNumber,
-		Topics:    [][]common.Hash{{transferEventHash}},
+	if *tui && !*watchLogs {
+		return errors.New("-tui shows the -watch stream and requires -watch")
+	}
+	if *tui && !stdoutIsTerminal() {
+		return errors.New("-tui needs stdout to be a terminal")
 	}
 
-	logs, err := client.FilterLogs(ctx, query)
+	if *interval < 0 {
+		return errors.New("-interval must not be negative")
+	}
+	if *interval > 0 && *storePath == "" {
+		return errors.New("-interval needs -store to remember the last scanned block between runs")
+	}
+	if *interval > 0 && *toBlockFlag != blockLatest {
+		return errors.New("-interval scans up to the latest block and cannot be combined with -to-block")
+	}
 
+	if *valueStats && *format != formatText && *format != formatMarkdown && *format != formatReport {
+		return errors.New("-value-stats needs -format text, markdown or report")
+	}
+	if *valueStats && *storePath != "" {
+		return errors.New("-value-stats needs every transfer value of the range and cannot be combined with -store")
+	}
+
+	if *wash && *format != formatText && *format != formatMarkdown {
+		return errors.New("-wash is printed after the ranking and needs -format text or markdown")
+	}
+	if *wash && (*byTxSender || *storePath != "") {
+		return errors.New("-wash follows transfers between addresses and cannot be combined with -by-tx-sender or -store")
+	}
+	if *washThreshold < 0 || *washThreshold > 1 {
+		return fmt.Errorf("invalid -wash-threshold %v: expected a share between 0 and 1", *washThreshold)
+	}
+
+	if *graphPath != "" && (*byTxSender || *byToken || *groupPrefix > 0 || *groupByCategory || *approvalsTo != "" || *abiDir != "" || *abiFiles != "" || *storePath != "") {
+		return errors.New("-graph follows transfers between addresses and cannot be combined with -by-tx-sender, -by-token, -group-prefix, -group-by-category, -approvals-to, -abi, -abi-dir or -store")
+	}
+	graphFileFormat, err := graphFormatOf(*graphPath, *graphFormat)
 	if err != nil {
-		return nil, fmt.Errorf("failed to filter logs: %v", err)
+		return err
+	}
+	if *graphTop < 1 {
+		return fmt.Errorf("invalid -graph-top %d", *graphTop)
 	}
 
-	contractAbi, err := abi.JSON(strings.NewReader(string(transferEventABI)))
+	if *compare && *format != formatText && *format != formatMarkdown {
+		return errors.New("-compare is printed after the ranking and needs -format text or markdown")
+	}
+	if *compare && (*logsFile != "" || *storePath != "" || *watchLogs || *interval > 0) {
+		return errors.New("-compare scans a second range over RPC and cannot be combined with -logs-file, -store, -watch or -interval")
+	}
+	if (*compareFrom != "" || *compareTo != "") && !*compare {
+		return errors.New("-compare-from and -compare-to require -compare")
+	}
+	if (*compareFrom == "") != (*compareTo == "") {
+		return errors.New("-compare-from and -compare-to must be given together")
+	}
+	compareFromBlock, err := parseBlockFlag(*compareFrom)
 	if err != nil {
-		return nil, fmt.Errorf("failed in marshall abi contract: %v", err)
+		return fmt.Errorf("invalid -compare-from: %v", err)
+	}
+	compareToBlock, err := parseBlockFlag(*compareTo)
+	if err != nil {
+		return fmt.Errorf("invalid -compare-to: %v", err)
+	}
+	if compareFromBlock != nil && compareToBlock != nil && compareFromBlock.Cmp(compareToBlock) > 0 {
+		return fmt.Errorf("-compare-from %v is after -compare-to %v", compareFromBlock, compareToBlock)
 	}
 
-	metric := make(map[common.Address]int)
+	if *snapshot && *format != formatText && *format != formatMarkdown {
+		return errors.New("-snapshot is printed after the ranking and needs -format text or markdown")
+	}
+	if *snapshot && (*logsFile != "" || *storePath != "") {
+		return errors.New("-snapshot queries balances of the addresses seen in this scan and cannot be combined with -logs-file or -store")
+	}
+	if !common.IsHexAddress(*multicallFlag) {
+		return fmt.Errorf("invalid -multicall address %q", *multicallFlag)
+	}
+	if *snapshotBatch <= 0 {
+		return errors.New("-snapshot-batch must be positive")
+	}
 
-	for _, vLog := range logs {
-		if len(vLog.Topics) != 3 || len(vLog.Data) == 0 {
-			continue
+	if *growthFlag && *format != formatText && *format != formatMarkdown {
+		return errors.New("-growth is printed after the ranking and needs -format text or markdown")
+	}
+
+	if err := validateBucket(*bucket); err != nil {
+		return err
+	}
+	if *bucket != "" {
+		if *format != formatText && *format != formatMarkdown && *format != formatCSV {
+			return errors.New("-bucket is printed after the ranking and needs -format text, markdown or csv")
+		}
+		if *storePath != "" {
+			return errors.New("-bucket cannot be combined with -store: stored blocks keep only per-address counts")
+		}
+		if *bucket != bucketBlock && *logsFile != "" {
+			return errors.New("-bucket hour and day need block timestamps from RPC and cannot be combined with -logs-file")
+		}
+	}
+
+	if *watchEvery < 1 {
+		return fmt.Errorf("invalid -watch-every %d: expected at least 1 block", *watchEvery)
+	}
+	if toBlock != nil && *watchLogs {
+		return errors.New("-watch continues from the chain head and cannot be combined with -to-block")
+	}
+
+	if *gzipOut && *outPath == "" {
+		return errors.New("-gzip requires -out")
+	}
+
+	if *explain != "" {
+		if len(common.FromHex(*explain)) != common.HashLength {
+			return fmt.Errorf("invalid -explain transaction hash %q", *explain)
+		}
+		if *logsFile != "" {
+			return errors.New("-explain needs RPC and cannot be combined with -logs-file")
+		}
+	}
+
+	if *atHash != "" {
+		if len(common.FromHex(*atHash)) != common.HashLength {
+			return fmt.Errorf("invalid -at-hash block hash %q", *atHash)
+		}
+		if *logsFile != "" || *watchLogs {
+			return errors.New("-at-hash selects a single block and cannot be combined with -logs-file or -watch")
+		}
+	}
+
+	if *minCounterparties < 0 {
+		return fmt.Errorf("invalid -min-counterparties %d", *minCounterparties)
+	}
+	if *minCounterparties > 0 && (*byTxSender || *groupPrefix > 0 || *approvalsTo != "") {
+		return errors.New("-min-counterparties cannot be combined with -by-tx-sender, -group-prefix or -approvals-to")
+	}
+
+	var histogramBounds []int
+	if *histogram {
+		histogramBounds, err = parseHistogramBins(*histogramBins)
+		if err != nil {
+			return fmt.Errorf("invalid -histogram-bins: %v", err)
+		}
+		if *rankOf != "" || *byToken {
+			return errors.New("-histogram cannot be combined with -rank-of or -by-token")
+		}
+	}
+
+	var baseline []Metric
+	if *baselinePath != "" {
+		if *byToken || *histogram || *rankOf != "" || *format == formatGrafana {
+			return errors.New("-baseline cannot be combined with -by-token, -histogram, -rank-of or -format grafana")
+		}
+		baseline, err = loadMetrics(*baselinePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	var labels map[common.Address]addressLabel
+	if *labelsPath != "" {
+		labels, err = loadLabels(*labelsPath)
+		if err != nil {
+			return err
+		}
+	}
+	excludedLabels := parseExcludedLabels(*excludeLabels)
+	if excludedLabels != nil && labels == nil {
+		return errors.New("-exclude-labels requires -labels")
+	}
+	if *groupByCategory {
+		if labels == nil {
+			return errors.New("-group-by-category requires -labels")
+		}
+		if *groupPrefix > 0 || *byToken || *rankOf != "" || *decay != "" || *minCounterparties > 0 || *countMode != countModeSum || *format == formatGrafana {
+			return errors.New("-group-by-category cannot be combined with -group-prefix, -by-token, -rank-of, -decay, -min-counterparties, -count-mode max or -format grafana")
+		}
+	}
+
+	if *eoaOnly && *contractsOnly {
+		return errors.New("-eoa-only and -contracts-only cannot be combined")
+	}
+	if (*eoaOnly || *contractsOnly) && (*logsFile != "" || *byToken || *groupPrefix > 0 || *groupByCategory) {
+		return errors.New("-eoa-only and -contracts-only need RPC and cannot be combined with -logs-file, -by-token, -group-prefix or -group-by-category")
+	}
+
+	var rankAddress common.Address
+	if *rankOf != "" {
+		if !common.IsHexAddress(*rankOf) {
+			return fmt.Errorf("invalid -rank-of address %q", *rankOf)
+		}
+		if *groupPrefix > 0 || *byToken {
+			return errors.New("-rank-of cannot be combined with -group-prefix or -by-token")
+		}
+		rankAddress = common.HexToAddress(*rankOf)
+	}
+
+	var whaleThreshold *big.Rat
+	if *minValue != "" {
+		whaleThreshold, err = parseMinValue(*minValue)
+		if err != nil {
+			return err
+		}
+		if !*decimals {
+			return errors.New("-min-value is in token units and needs -decimals")
+		}
+		if *valueSample > 0 || *byTxSender || *approvalsTo != "" || withEvents {
+			return errors.New("-min-value cannot be combined with -value-sample, -by-tx-sender, -approvals-to or -abi-dir")
+		}
+	}
+
+	var detailAddress *common.Address
+	if *detail != "" {
+		if !common.IsHexAddress(*detail) {
+			return fmt.Errorf("invalid -detail address %q", *detail)
+		}
+		if *rankOf != "" || *byToken || *groupPrefix > 0 || *groupByCategory || *histogram || *approvalsTo != "" || withEvents || *format == formatGrafana {
+			return errors.New("-detail cannot be combined with -rank-of, -by-token, -group-prefix, -group-by-category, -histogram, -approvals-to, -abi-dir or -format grafana")
+		}
+		address := common.HexToAddress(*detail)
+		detailAddress = &address
+	}
+
+	if err := validateDecay(*decay, *decayHalfLife); err != nil {
+		return err
+	}
+	if *decay != "" && (*watchLogs || *groupPrefix > 0) {
+		return errors.New("-decay is relative to the end of the scanned range and cannot be combined with -watch or -group-prefix")
+	}
+	// Реорганизация в -watch откатывает только счётчики рейтинга; сводки этих флагов остались бы
+	// с переводами снятых блоков.
+	if *watchLogs && (*topTokens || *supply || *bucket != "" || *txCounts || *growthFlag) {
+		return errors.New("-top-tokens, -supply, -bucket, -tx-counts and -growth cannot be rewound after a chain reorganization and cannot be combined with -watch")
+	}
+
+	contracts, err := parseAddressList(*contractList)
+	if err != nil {
+		return fmt.Errorf("invalid -contracts: %v", err)
+	}
+
+	fromAddresses, err := parseAddressList(*fromAny)
+	if err != nil {
+		return fmt.Errorf("invalid -from-any: %v", err)
+	}
+	toAddresses, err := parseAddressList(*toAny)
+	if err != nil {
+		return fmt.Errorf("invalid -to-any: %v", err)
+	}
+
+	approvalSpenders, err := parseAddressList(*approvalsTo)
+	if err != nil {
+		return fmt.Errorf("invalid -approvals-to: %v", err)
+	}
+	if len(approvalSpenders) > 0 && (len(fromAddresses) > 0 || len(toAddresses) > 0 || *byTxSender || *decimals || *direction != directionBoth) {
+		return errors.New("-approvals-to cannot be combined with -from-any, -to-any, -by-tx-sender, -decimals or -direction")
+	}
+
+	if *byToken && *groupPrefix > 0 {
+		return errors.New("-by-token and -group-prefix cannot be combined")
+	}
+
+	switch *direction {
+	case directionIn, directionOut, directionBoth:
+	default:
+		return fmt.Errorf("invalid -direction %q: expected in, out or both", *direction)
+	}
+
+	switch *topTokensBy {
+	case tokensByAddresses, tokensByTransfers:
+	default:
+		return fmt.Errorf("invalid -top-tokens-by %q: expected addresses or transfers", *topTokensBy)
+	}
+
+	if *topSpenders && (*approvalsTo != "" || withEvents || *fromAny != "" || *toAny != "" || *standard != standardERC20) {
+		return errors.New("-top-spenders cannot be combined with -approvals-to, -abi-dir, -from-any, -to-any or -standard erc721|erc1155")
+	}
+
+	if err := validateStandard(*standard); err != nil {
+		return err
+	}
+	if *standard != standardERC20 && (*decimals || *supply || *approvalsTo != "" || withEvents || *detail != "" || *byTxSender || *minCounterparties > 0 || *countMode == countModeMax || *sortBy == sortInflow) {
+		return errors.New("-standard erc721 and erc1155 count token transfers without values and cannot be combined with -decimals, -supply, -approvals-to, -abi-dir, -detail, -by-tx-sender, -min-counterparties, -count-mode max or -sort inflow")
+	}
+
+	alerts, err := newAlerter(*watchlist, *alertThreshold)
+	if err != nil {
+		return fmt.Errorf("invalid -watchlist: %v", err)
+	}
+
+	var notifier *transferNotifier
+	if *watchlistFile != "" || *notifyWebhook != "" || *telegramChat != "" {
+		telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN")
+		switch {
+		case *watchlistFile == "":
+			return errors.New("-notify-webhook and -telegram-chat need -watchlist-file")
+		case *notifyWebhook == "" && *telegramChat == "":
+			return errors.New("-watchlist-file needs -notify-webhook or -telegram-chat")
+		case *telegramChat != "" && telegramToken == "":
+			return errors.New("-telegram-chat needs the bot token in TELEGRAM_BOT_TOKEN")
+		case !*watchLogs:
+			return errors.New("-watchlist-file notifies about new transfers and needs -watch")
+		case *approvalsTo != "" || withEvents || *standard != standardERC20 || *byTxSender:
+			return errors.New("-watchlist-file follows ERC20 transfers and cannot be combined with -approvals-to, -abi-dir, -standard erc721|erc1155 or -by-tx-sender")
+		}
+		watched, err := loadWatchlistFile(*watchlistFile)
+		if err != nil {
+			return err
+		}
+		notifier = newTransferNotifier(watched, *notifyWebhook, telegramToken, *telegramChat)
+	}
+
+	var events *eventRegistry
+	if withEvents {
+		if *approvalsTo != "" || *fromAny != "" || *toAny != "" || *decimals || *byTxSender || *direction != directionBoth || *minCounterparties > 0 {
+			return errors.New("-abi and -abi-dir cannot be combined with -approvals-to, -from-any, -to-any, -decimals, -by-tx-sender, -direction or -min-counterparties")
+		}
+		var sources []string
+		if *abiDir != "" {
+			sources = append(sources, *abiDir)
+		}
+		sources = append(sources, parseList(*abiFiles)...)
+		events, err = loadEventRegistry(sources, parseList(*eventNames), parseList(*eventSums))
+		if err != nil {
+			return err
+		}
+		logger.Info("counting events", "events", events.Names())
+	}
+
+	var registry map[common.Address]tokenInfo
+	if *tokenRegistry != "" {
+		registry, err = loadTokenRegistry(*tokenRegistry)
+		if err != nil {
+			return err
+		}
+	}
+
+	offline := *logsFile != ""
+	if offline && (*watchLogs || *byTxSender || *successfulOnly || *ens || *resolveProxy) {
+		return errors.New("-logs-file works without RPC and cannot be combined with -watch, -by-tx-sender, -successful-only, -ens or -resolve-proxy")
+	}
+
+	if envErr != nil && *rpcURL == "" && !offline {
+		return errors.New("error loading .env file")
+	}
+	ctx, stop := withShutdown(context.Background())
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	url := *rpcURL
+	if url == "" {
+		apiKey := os.Getenv("ETH_API_KEY")
+		url = fmt.Sprintf("https://go.getblock.io/%s", apiKey)
+	}
+	endpoints, err := parseEndpoints(url)
+	if err != nil {
+		return fmt.Errorf("invalid -rpc-url: %v", err)
+	}
+	if len(endpoints) > 0 {
+		url = endpoints[0]
+	}
+
+	subscriptionURL := url
+	if *wsURL != "" {
+		subscriptionURL = *wsURL
+	}
+	redactURLList := append([]string{subscriptionURL}, endpoints...)
+	setLogOutput(redactingWriter{w: os.Stderr, urls: redactURLList})
+
+	if *watchLogs {
+		isWebSocket, err := isWebSocketURL(subscriptionURL)
+		if err != nil {
+			return fmt.Errorf("invalid -ws-url: %v", err)
+		}
+		if !isWebSocket {
+			return errors.New("-watch needs a WebSocket endpoint for log subscriptions, but the RPC URL is http(s); pass -ws-url ws://... or wss://...")
+		}
+	}
+
+	stopProfiling, err := startProfiling(*cpuProfile, *traceFile)
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
+	dial := dialConfig{Headers: headers}
+	if *proxy != "" {
+		dial.Proxy, err = parseProxyURL(*proxy)
+		if err != nil {
+			return fmt.Errorf("invalid -proxy: %v", err)
+		}
+		dial.Transport = proxyTransport(dial.Proxy)
+	}
+	// Предохранитель стоит под повторами и у каждого эндпоинта свой: повтор после отказа одного
+	// провайдера уходит на следующий, а не упирается в общий разомкнутый предохранитель.
+	if len(endpoints) > 1 && !offline {
+		failover, err := newFailoverTransport(dial.Transport, endpoints, headers, *breakerThreshold, *breakerCooldown)
+		if err != nil {
+			return err
+		}
+		go failover.checkHealth(ctx, *healthInterval)
+		dial.Transport = failover
+	} else if *breakerThreshold > 0 && !offline {
+		dial.Transport = newCircuitBreaker(dial.Transport, *breakerThreshold, *breakerCooldown)
+	}
+	if (*rpcRetries > 0 || *rpcRPS > 0) && !offline {
+		dial.Transport = newRetryTransport(dial.Transport, *rpcRetries, *rpcBackoff, *rpcBackoffMax, *rpcRPS)
+	}
+
+	var client *ethclient.Client
+	if !offline {
+		client, err = dialClient(ctx, url, dial)
+		if err != nil {
+			return fmt.Errorf("error in dialing Ethereum client %s: %v", redactURL(url), redactErr(err, endpoints...))
+		}
+		if err := checkChainID(ctx, client, chain); err != nil {
+			return redactErr(err, endpoints...)
 		}
+	}
+
+	if *explain != "" {
+		if err := explainTransaction(ctx, client, os.Stdout, common.HexToHash(*explain)); err != nil {
+			return fmt.Errorf("error in explain: %v", redactErr(err, endpoints...))
+		}
+		return nil
+	}
+
+	var feed *transferFeed
+	if *followLogs != "" {
+		feed, err = openTransferFeed(*followLogs, *logRotateSize)
+		if err != nil {
+			return err
+		}
+		defer feed.Close()
+	}
 
-		var transferEvent TransferEvents
-		transferEvent.From = common.HexToAddress(vLog.Topics[1].Hex())
-		transferEvent.To = common.HexToAddress(vLog.Topics[2].Hex())
+	var export *parquetSink
+	if *parquetDir != "" {
+		export, err = openParquetSink(*parquetDir, *parquetRows)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := export.Close(); err != nil {
+				logger.Error("failed to close -parquet-dir", "err", err)
+			}
+		}()
+	}
+
+	var publisher *natsPublisher
+	if *natsURL != "" {
+		publisher, err = openNATSPublisher(*natsURL, *natsSubject)
+		if err != nil {
+			return redactErr(err, *natsURL)
+		}
+		defer func() {
+			if err := publisher.Close(); err != nil {
+				logger.Error("failed to close -nats-url", "err", err)
+			}
+		}()
+	}
 
-		err := contractAbi.UnpackIntoInterface(&transferEvent, "Transfer", vLog.Data)
+	var kafkaSink *kafkaPublisher
+	if *kafkaBrokers != "" {
+		kafkaSink, err = openKafkaPublisher(*kafkaBrokers, *kafkaTopic)
 		if err != nil {
-			fmt.Println("Error unpacking from ABI: ", err)
+			return err
+		}
+		defer func() {
+			if err := kafkaSink.Close(); err != nil {
+				logger.Error("failed to close -kafka-brokers", "err", err)
+			}
+		}()
+	}
+
+	prices, err := newPriceSource(*priceSourceName, client, *priceFeeds, *coinGeckoURL, *coinGeckoPlatform)
+	if err != nil {
+		return err
+	}
+
+	var windowBlocks uint64
+	if *format == formatGrafana {
+		windowBlocks = *grafanaWindow
+	}
+
+	opts := scanOptions{
+		Direction:    *direction,
+		Decimals:     *decimals,
+		MaxLogs:      *maxLogs,
+		ChunkSize:    *chunkSize,
+		PartialOK:    *partialOK,
+		Workers:      *workers,
+		FetchRPS:     *fetchRPS,
+		MaxTotalLogs: *maxTotalLogs,
+		ByTxSender:   *byTxSender,
+		GroupPrefix:  *groupPrefix,
+		ByToken:      *byToken,
+		Contracts:    contracts,
+		FromAny:      fromAddresses,
+		ToAny:        toAddresses,
+		CountZero:    *countZero,
+		TopTokens:    *topTokens || *tui || *compare,
+		Snapshot:     *snapshot,
+		Wash:         *wash,
+		Graph:        *graphPath != "",
+		ValueStats:   *valueStats,
+		TopTokensBy:  *topTokensBy,
+		TopSpenders:  *topSpenders,
+		Standard:     *standard,
+
+		Decay:         *decay,
+		DecayHalfLife: *decayHalfLife,
+
+		SuccessfulOnly: *successfulOnly,
+
+		ApprovalSpenders: approvalSpenders,
+
+		MinCounterparties: *minCounterparties,
+
+		CountMode: *countMode,
+		Lookback:  *lookback,
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+
+		LookbackDuration: *lookbackDuration,
+		BlockTime:        *blockTime,
+
+		ValueSample: *valueSample,
+
+		Labels:          labels,
+		ExcludeLabels:   excludedLabels,
+		GroupByCategory: *groupByCategory,
+
+		DedupeLogs: *dedupeLogs,
+		Watch:      *watchLogs,
+
+		Inflow:        *sortBy == sortInflow,
+		Sides:         withSides,
+		RankBy:        *sortBy,
+		Rate:          withRate,
+		TxCounts:      withTxs,
+		Gas:           withGas,
+		Volume:        withVolume,
+		Detail:        detailAddress,
+		MinValue:      whaleThreshold,
+		Prices:        prices,
+		PriceInterval: *priceInterval,
+
+		RequestTimeout: *requestTimeout,
+
+		Events:    events,
+		TopEvents: *topEvents || *eventSums != "",
+		Bucket:    *bucket,
+		Growth:    *growthFlag,
+		Feed:      feed,
+		Export:    export,
+		Publish:   publisher,
+		Kafka:     kafkaSink,
+
+		Window: windowBlocks,
+
+		EnrichConcurrency: *enrichConcurrency,
+		EnrichRPS:         *enrichRPS,
+
+		TokenRegistry: registry,
+		TokenMetadata: *tokenMetadataFlag,
+	}
+	if *storePath != "" {
+		opts.Store, err = openStore(*storePath, *direction, contracts)
+		if err != nil {
+			return err
+		}
+		defer opts.Store.Close()
+	}
+	counter := newTransferCounter(client, opts)
+	if opts.Store != nil {
+		if err := counter.rewindStore(ctx); err != nil {
+			return redactErr(err, endpoints...)
+		}
+		if err := counter.seedFromStore(); err != nil {
+			return err
+		}
+	}
+
+	if *serveAPIAddr != "" {
+		grpc := grpcConfig{Addr: *grpcAddr, Cert: *grpcCert, Key: *grpcKey}
+		if err := serveAPI(ctx, *serveAPIAddr, grpc, client, opts, chain); err != nil {
+			return redactErr(err, endpoints...)
+		}
+		return nil
+	}
+
+	out, closeOut, err := openOutput(*outPath, *gzipOut)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := closeOut(); err != nil {
+			logger.Error("failed to close output", "err", err)
+		}
+	}()
+
+	proxies := newProxyResolver(client)
+	var names *ensResolver
+	if *ens {
+		names, err = newENSResolver(client, *ensCache, *ensCacheTTL)
+		if err != nil {
+			return err
+		}
+	}
+	output := outputOptions{Format: *format, AddressesOnly: *addressesOnly, Decimals: *decimals, ENS: *ens, Fields: fields, Decay: *decay != "", Approvals: len(approvalSpenders) > 0, Events: events != nil, Category: *groupByCategory, Inflow: *sortBy == sortInflow, Sides: withSides, Rate: withRate, TxCounts: withTxs, Gas: withGas, Volume: withVolume, USD: withUSD, Chain: chain, Standard: *standard, Sort: *sortBy, SortSecondary: *sortSecondary, Order: *order, TopShare: *topShare, BarWidth: *barWidth, ShortAddr: *shortAddr}
+	var exporter *metricsExporter
+	if *serveMetricsAddr != "" {
+		exporter = new(metricsExporter)
+		if err := serveMetrics(ctx, *serveMetricsAddr, exporter); err != nil {
+			return err
+		}
+	}
+
+	if *tokenMetadataFlag {
+		output.Tokens, err = openTokenMetadata(client, *tokenCache, registry)
+		if err != nil {
+			return err
+		}
+	}
+
+	report := func(metrics []Metric) error {
+		if *valueStats {
+			output.ValueStats = counter.ValueDistributions(ctx)
+		}
+		if output.Tokens != nil {
+			resolveTokens(ctx, counter.pool, output.Tokens, counter.Tokens())
+			if err := output.Tokens.Save(); err != nil {
+				logger.Warn(err.Error())
+			}
+		}
+		if *eoaOnly || *contractsOnly {
+			filtered, err := filterByCode(ctx, counter.pool, counter.codes, metrics, *contractsOnly)
+			if err != nil {
+				return fmt.Errorf("error checking address code: %v", redactErr(err, endpoints...))
+			}
+			metrics = filtered
+		}
+		if chain.Name != "" {
+			tagChain(metrics, chain.Name)
+		}
+		if labels != nil {
+			applyLabels(labels, metrics)
+		}
+		if *ens && !*addressesOnly {
+			resolveNames(ctx, names, metrics)
+		}
+		if withGas && !*addressesOnly {
+			// -sort fee упорядочивает весь рейтинг, и комиссия нужна каждому адресу до сортировки.
+			fetch := metrics
+			if output.Sort != sortFee && output.SortSecondary != sortFee {
+				fetch = output.top(metrics)
+			}
+			if err := counter.fillGas(ctx, metrics, fetch); err != nil {
+				logger.Warn("gas usage is unavailable", "err", redactErr(err, endpoints...))
+			}
+		}
+		if *graphPath != "" {
+			nodes, edges := counter.CounterpartyGraph(metrics, *graphTop)
+			if err := writeGraphFile(*graphPath, graphFileFormat, nodes, edges); err != nil {
+				return err
+			}
+		}
+
+		if *format == formatGrafana {
+			top := metrics
+			if len(top) > topN {
+				top = top[:topN]
+			}
+			series, err := counter.GrafanaSeries(ctx, top)
+			if err != nil {
+				return fmt.Errorf("error building grafana series: %v", redactErr(err, endpoints...))
+			}
+			if err := writeGrafana(out, series); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		} else if *histogram {
+			if err := writeHistogram(out, Histogram(metrics, histogramBounds), output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		} else if detailAddress != nil {
+			if err := writeDetail(out, *detailAddress, counter.Details(), output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		} else if *rankOf != "" {
+			if rank, m, found := metric.RankOf(metrics, rankAddress); found {
+				fmt.Fprintf(out, "address %v is ranked #%d of %d with %v transfers\n", m.Address.Hex(), rank, len(metrics), m.Count)
+			} else {
+				fmt.Fprintf(out, "address %v has no transfers in the scanned range\n", rankAddress.Hex())
+			}
+		} else if *byToken {
+			pairs, _ := counter.PairMetrics()
+			if err := writePairMetrics(out, pairs, output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+			if *resolveProxy {
+				reportProxies(ctx, proxies, pairs)
+			}
+		} else if err := writeMetrics(out, metrics, counter.Stats(), output); err != nil {
+			return fmt.Errorf("error writing output: %v", err)
+		}
+
+		if baseline != nil {
+			if err := writeBaselineDiff(out, diffBaseline(baseline, output.top(metrics), metrics)); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *valueSample > 0 {
+			if err := counter.writeValueSample(out); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *includePending {
+			reportPending(ctx, client, out, counter, output)
+		}
+
+		if *supply {
+			if err := writeSupply(out, counter.Stats(), counter.SupplyByToken(), output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *growthFlag {
+			if err := writeGrowth(out, counter.Growth(), counter.Stats(), output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *topTokens {
+			if err := writeTokenMetrics(out, counter.TokenMetrics(), output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *valueStats && *format != formatReport {
+			if err := writeValueStats(out, output.ValueStats, output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *wash {
+			if err := writeWash(out, counter.WashSuspects(*washThreshold), *washThreshold, output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *compare {
+			stats := counter.Stats()
+			from, to := compareFromBlock, compareToBlock
+			if from == nil {
+				prevFrom, prevTo, ok := previousRange(stats.FromBlock, stats.ToBlock)
+				if !ok {
+					return fmt.Errorf("-compare: there are no blocks before block %d to compare with", stats.FromBlock)
+				}
+				from, to = new(big.Int).SetUint64(prevFrom), new(big.Int).SetUint64(prevTo)
+			}
+			comparison, err := compareRange(ctx, client, opts, counter, from, to)
+			if err != nil {
+				return fmt.Errorf("error in compare: %v", redactErr(err, endpoints...))
+			}
+			if err := writeComparison(out, comparison, stats, output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *snapshot {
+			holders, err := counter.Snapshot(ctx, client, common.HexToAddress(*multicallFlag), *snapshotBatch)
+			if err != nil {
+				return fmt.Errorf("error in snapshot: %v", redactErr(err, endpoints...))
+			}
+			if err := writeSnapshot(out, holders, counter.Stats().ToBlock, output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if whaleThreshold != nil {
+			if err := writeWhales(out, counter.Whales(), whaleThreshold, output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *bucket != "" {
+			buckets, err := counter.Buckets(ctx)
+			if err != nil {
+				return fmt.Errorf("error building buckets: %v", redactErr(err, endpoints...))
+			}
+			if err := writeBuckets(out, buckets, output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *topEvents || *eventSums != "" {
+			if err := writeEventMetrics(out, counter.EventMetrics(), output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if *topSpenders {
+			if err := writeSpenderMetrics(out, counter.SpenderMetrics(), output); err != nil {
+				return fmt.Errorf("error writing output: %v", err)
+			}
+		}
+
+		if exporter != nil {
+			exporter.Update(metrics, counter.Stats())
+		}
+
+		fired := alerts.Check(metrics)
+		for _, alert := range fired {
+			// Тревога идёт в журнал на stderr: stdout может быть JSON или CSV рейтинга.
+			logger.Warn("ALERT: watched address reached its threshold", "address", alert.Address.Hex(), "transfers", alert.Count, "threshold", alert.Threshold)
+		}
+		if len(fired) > 0 && *alertExit {
+			return &exitError{code: exitAlert}
+		}
+		return nil
+	}
+
+	var metrics []Metric
+	if offline {
+		metrics, err = countLogsFile(ctx, *logsFile, counter)
+	} else if *atHash != "" {
+		metrics, err = blockByHash(ctx, client, counter, common.HexToHash(*atHash))
+	} else {
+		metrics, err = currentBlock(ctx, client, counter)
+	}
+	if interrupted(ctx, err) {
+		if store := counter.opts.Store; store != nil && store.LastBlock != nil {
+			logger.Info("scan interrupted; saved blocks are kept in -store, rerun to resume", "last_block", *store.LastBlock)
+		} else {
+			logger.Info("scan interrupted; no results written")
+		}
+		return &exitError{code: exitInterrupted}
+	}
+	if err != nil {
+		// Прерванный -max-logs, -max-total-logs или -request-timeout скан не печатает рейтинг: пустой
+		// вывод с кодом 0 не отличить от диапазона без переводов.
+		return scanFailed(fmt.Errorf("failed to rank transfers: %v", redactErr(err, endpoints...)))
+	}
+	if err := report(metrics); err != nil {
+		return err
+	}
+
+	if *audit {
+		provider := url
+		if offline {
+			provider = ""
+		}
+		if err := counter.audit.Write(auditPath(*outPath), provider, counter.Stats()); err != nil {
+			return err
+		}
+	}
+
+	if *interval > 0 {
+		if err := runDaemon(ctx, client, counter, *interval, report, endpoints); err != nil && !interrupted(ctx, err) {
+			return scanFailed(redactErr(err, endpoints...))
+		}
+		return nil
+	}
+
+	if *watchLogs {
+		wsClient := client
+		if subscriptionURL != url {
+			wsClient, err = dialClient(ctx, subscriptionURL, dial)
+			if err != nil {
+				return fmt.Errorf("error in dialing WebSocket client %s: %v", redactURL(subscriptionURL), redactErr(err, subscriptionURL))
+			}
+		}
+
+		if notifier != nil {
+			// Переводы начального диапазона уже в прошлом: уведомляем только о новых.
+			counter.notifier = notifier
+			go notifier.Run(ctx)
+		}
+		var dash *dashboard
+		var logs io.Writer
+		if *tui {
+			dash = newDashboard(output)
+			// Панель показывает журнал на экране: ключи в URL скрываются так же, как в stderr.
+			logs = setLogOutput(redactingWriter{w: dash, urls: redactURLList})
+			dash.Start()
+		}
+		err := watch(ctx, wsClient, counter, *watchEvery, report, dash)
+		if dash != nil {
+			dash.Stop()
+			setLogOutput(logs)
+		}
+		if interrupted(ctx, err) {
+			// Остановка -watch сигналом — штатная: печатаем итоговый рейтинг и выходим с 0.
+			if metrics, err := counter.Metrics(); err == nil {
+				logger.Info("final ranking", "to_block", counter.toBlock)
+				return report(metrics)
+			}
+			return nil
+		}
+		if err != nil {
+			return scanFailed(fmt.Errorf("error in watch: %v", redactErr(err, append([]string{subscriptionURL}, endpoints...)...)))
+		}
+	}
+
+	if exporter != nil {
+		// Без -watch рейтинг больше не меняется, но эндпоинт остаётся доступным для скрейпа.
+		<-ctx.Done()
+	}
+	return nil
+}
+
+func reportProxies(ctx context.Context, proxies *proxyResolver, pairs []PairMetric) {
+	noted := make(map[common.Address]bool)
+	for i := 0; i < topN && i < len(pairs); i++ {
+		token := pairs[i].Token
+		if noted[token] {
 			continue
 		}
+		noted[token] = true
+
+		implementation, isProxy, err := proxies.Implementation(ctx, token)
+		if err != nil {
+			logger.Warn(err.Error())
+			continue
+		}
+		if isProxy {
+			logger.Info("token is an EIP-1967 proxy", "token", token.Hex(), "implementation", implementation.Hex())
+		}
+	}
+}
 
-		metric[transferEvent.From]++
-		metric[transferEvent.To]++
+func currentBlock(ctx context.Context, client ChainReader, counter *transferCounter) ([]Metric, error) {
+	// ToBlock == nil — последний блок.
+	block, err := counter.headers.HeaderByNumber(ctx, counter.opts.ToBlock)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve the %s block header: %w", blockLabel(counter.opts.ToBlock), err)
+	}
+
+	if block == nil || block.Number == nil {
+		return nil, fmt.Errorf("%s block header has no number", blockLabel(counter.opts.ToBlock))
+	}
+
+	var blockNumber, latestBlockNumber *big.Int
+	if resume, ok := counter.resumeFrom(); ok {
+		blockNumber, latestBlockNumber = resume, block.Number
+		if blockNumber.Cmp(latestBlockNumber) > 0 {
+			logger.Info("no new blocks since the last run stored in -store", "block", latestBlockNumber)
+			return counter.Metrics()
+		}
+		logger.Info("resuming from -store", "block", blockNumber)
+	} else if counter.opts.FromBlock != nil {
+		blockNumber, latestBlockNumber = counter.opts.FromBlock, block.Number
+		if blockNumber.Cmp(latestBlockNumber) > 0 {
+			return nil, fmt.Errorf("-from-block %v is after the end of the range %v", blockNumber, latestBlockNumber)
+		}
+	} else {
+		lookback := counter.lookbackBlocks(ctx, block)
+		var truncated bool
+		blockNumber, latestBlockNumber, truncated = resolveRange(block.Number, lookback)
+		if truncated {
+			logger.Info("-lookback exceeds the chain height, scanning every block from genesis",
+				"lookback", lookback, "height", latestBlockNumber, "blocks", new(big.Int).Add(latestBlockNumber, big.NewInt(1)))
+		}
 	}
 
-	return SortAddressesByCount(metric)
+	scanner := metric.NewScanner(client, metric.WithCounter(counter))
+	if _, _, err := scanner.CountTransfers(ctx, blockNumber, latestBlockNumber); err != nil {
+		return nil, err
+	}
+	counter.warnFailedRanges()
+	if counter.opts.Store != nil {
+		if err := counter.checkpoint(ctx, latestBlockNumber.Uint64()); err != nil {
+			return nil, err
+		}
+	}
+	return counter.Metrics()
 }
 
-func SortAddressesByCount(logsMap map[common.Address]int) ([]Metric, error) {
-	if len(logsMap) == 0 {
-		return nil, fmt.Errorf("no logs in map to sort")
+// blockByHash считает переводы одного блока; BlockHash в FilterQuery исключает FromBlock/ToBlock.
+func blockByHash(ctx context.Context, client *ethclient.Client, counter *transferCounter, hash common.Hash) ([]Metric, error) {
+	requestCtx, cancel := withRequestTimeout(ctx, counter.opts.RequestTimeout)
+	header, err := client.HeaderByHash(requestCtx, hash)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve block %s: %w", hash.Hex(), err)
+	}
+
+	query := ethereum.FilterQuery{
+		BlockHash: &hash,
+		Addresses: counter.opts.Contracts,
+		Topics:    counter.opts.transferTopics(),
+	}
+
+	requestCtx, cancel = withRequestTimeout(ctx, counter.opts.RequestTimeout)
+	logs, err := client.FilterLogs(requestCtx, query)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to filter logs: %w", err)
+	}
+	counter.audit.Record(query, len(logs))
+
+	if counter.opts.MaxLogs > 0 && len(logs) > counter.opts.MaxLogs {
+		return nil, fmt.Errorf("block %s returned %d logs, more than -max-logs %d: raise the limit",
+			hash.Hex(), len(logs), counter.opts.MaxLogs)
 	}
 
-	counters := make([]Metric, 0, len(logsMap))
+	counter.SetRange(header.Number.Uint64(), header.Number.Uint64())
+	return countLogs(ctx, logs, counter)
+}
 
-	for address, count := range logsMap {
-		if address != (common.Address{}) {
-			counters = append(counters, Metric{Address: address, Count: count})
+func countLogsFile(ctx context.Context, path string, counter *transferCounter) ([]Metric, error) {
+	logs, err := loadLogs(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("loaded logs", "count", len(logs), "file", path)
+	if len(counter.opts.Contracts) > 0 {
+		logs = filterContracts(logs, counter.opts.Contracts)
+	}
+
+	if len(logs) > 0 {
+		from, to := logs[0].BlockNumber, logs[0].BlockNumber
+		for _, vLog := range logs {
+			if vLog.BlockNumber < from {
+				from = vLog.BlockNumber
+			}
+			if vLog.BlockNumber > to {
+				to = vLog.BlockNumber
+			}
 		}
+		counter.SetRange(from, to)
 	}
 
-	// Сортировка среза  в порядке убывания.
-	sort.Slice(counters, func(i, j int) bool {
-		return counters[i].Count > counters[j].Count
-	})
+	return countLogs(ctx, logs, counter)
+}
 
-	return counters, nil
+func countLogs(ctx context.Context, logs []types.Log, counter *transferCounter) ([]Metric, error) {
+	if err := counter.AddLogs(ctx, logs); err != nil {
+		return nil, err
+	}
+	return counter.Metrics()
 }
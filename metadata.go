@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// symbol() и name()
+	symbolSelector = []byte{0x95, 0xd8, 0x9b, 0x41}
+	nameSelector   = []byte{0x06, 0xfd, 0xde, 0x03}
+)
+
+var stringArguments = func() abi.Arguments {
+	stringType, err := abi.NewType("string", "", nil)
+	if err != nil {
+		panic(fmt.Sprintf("failed in marshall abi string type: %v", err))
+	}
+	return abi.Arguments{{Type: stringType}}
+}()
+
+// tokenMetadata — то, что токен сообщает о себе; пустые поля — контракт не реализует метод.
+type tokenMetadata struct {
+	Symbol   string `json:"symbol,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Decimals *uint8 `json:"decimals,omitempty"`
+}
+
+// defaultTokenCachePath — файл кэша -token-cache по умолчанию в пользовательском каталоге кэша.
+func defaultTokenCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "getblock", "tokens.json")
+}
+
+// tokenMetadataResolver получает symbol(), name() и decimals() токенов и хранит их в JSON-файле
+// между запусками: метаданные контракта не меняются, так что каждый токен запрашивается один раз.
+type tokenMetadataResolver struct {
+	client *ethclient.Client
+	path   string
+
+	mu    sync.Mutex
+	known map[common.Address]tokenMetadata
+	used  map[common.Address]struct{}
+	dirty bool
+}
+
+// openTokenMetadata читает кэш path (если он есть); записи -token-registry имеют приоритет над кэшем.
+func openTokenMetadata(client *ethclient.Client, path string, registry map[common.Address]tokenInfo) (*tokenMetadataResolver, error) {
+	r := &tokenMetadataResolver{
+		client: client,
+		path:   path,
+		known:  make(map[common.Address]tokenMetadata),
+		used:   make(map[common.Address]struct{}),
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("failed to read token cache: %w", err)
+		default:
+			if err := json.Unmarshal(data, &r.known); err != nil {
+				return nil, fmt.Errorf("failed to parse token cache %s: %w", path, err)
+			}
+		}
+	}
+
+	for token, info := range registry {
+		decimals := info.Decimals
+		r.known[token] = tokenMetadata{Symbol: info.Symbol, Name: r.known[token].Name, Decimals: &decimals}
+	}
+	return r, nil
+}
+
+func (r *tokenMetadataResolver) Metadata(ctx context.Context, token common.Address) tokenMetadata {
+	r.mu.Lock()
+	r.used[token] = struct{}{}
+	metadata, ok := r.known[token]
+	r.mu.Unlock()
+	if ok || r.client == nil {
+		return metadata
+	}
+
+	metadata, err := fetchTokenMetadata(ctx, r.client, token)
+	if err != nil {
+		// Сбой RPC не кэшируется: в следующий раз попробуем снова.
+		logger.Warn("token metadata lookup failed", "token", token.Hex(), "err", err)
+		return metadata
+	}
+
+	r.mu.Lock()
+	r.known[token] = metadata
+	r.dirty = true
+	r.mu.Unlock()
+	return metadata
+}
+
+// Label — адрес токена с символом в скобках, если символ известен.
+func (r *tokenMetadataResolver) Label(token common.Address) string {
+	if r == nil {
+		return token.Hex()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if symbol := r.known[token].Symbol; symbol != "" {
+		return token.Hex() + " (" + symbol + ")"
+	}
+	return token.Hex()
+}
+
+// Used — метаданные токенов, которые встретились в этом прогоне, для -format report.
+func (r *tokenMetadataResolver) Used() map[string]tokenMetadata {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used := make(map[string]tokenMetadata, len(r.used))
+	for token := range r.used {
+		used[token.Hex()] = r.known[token]
+	}
+	return used
+}
+
+// Save записывает кэш, если появились новые токены.
+func (r *tokenMetadataResolver) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.path == "" || !r.dirty {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.known, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode token cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create token cache directory: %w", err)
+	}
+	if err := writeFileAtomic(r.path, data); err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+	r.dirty = false
+	return nil
+}
+
+// fetchTokenMetadata вызывает symbol(), name() и decimals(). Revert или пустой ответ метода
+// означает, что он не реализован, и поле остаётся пустым; ошибкой считается только сбой RPC.
+func fetchTokenMetadata(ctx context.Context, client *ethclient.Client, token common.Address) (tokenMetadata, error) {
+	var metadata tokenMetadata
+
+	call := func(selector []byte) ([]byte, error) {
+		output, err := client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: selector}, nil)
+		if isRevert(err) {
+			return nil, nil
+		}
+		return output, err
+	}
+
+	for _, field := range []struct {
+		selector []byte
+		value    *string
+	}{
+		{symbolSelector, &metadata.Symbol},
+		{nameSelector, &metadata.Name},
+	} {
+		output, err := call(field.selector)
+		if err != nil {
+			return metadata, fmt.Errorf("failed to call token metadata on %s: %w", token.Hex(), err)
+		}
+		*field.value = decodeTokenString(output)
+	}
+
+	output, err := call(decimalsSelector)
+	if err != nil {
+		return metadata, fmt.Errorf("failed to call decimals() on %s: %w", token.Hex(), err)
+	}
+	if len(output) == 32 {
+		if value := new(big.Int).SetBytes(output); value.IsUint64() && value.Uint64() <= 77 {
+			decimals := uint8(value.Uint64())
+			metadata.Decimals = &decimals
+		}
+	}
+	return metadata, nil
+}
+
+// isRevert отличает revert вызова (код 3 или "execution reverted") от сбоя RPC.
+func isRevert(err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return rpcErr.ErrorCode() == 3 || strings.Contains(strings.ToLower(rpcErr.Error()), "revert")
+}
+
+// decodeTokenString разбирает ABI string; старые токены (например, MKR) возвращают bytes32.
+func decodeTokenString(output []byte) string {
+	if len(output) == 32 {
+		return string(bytes.TrimRight(output, "\x00"))
+	}
+
+	values, err := stringArguments.Unpack(output)
+	if err != nil || len(values) != 1 {
+		return ""
+	}
+	value, _ := values[0].(string)
+	return value
+}
+
+// resolveTokens подгружает метаданные токенов через общий пул обогащения.
+func resolveTokens(ctx context.Context, pool *enrichPool, resolver *tokenMetadataResolver, tokens []common.Address) {
+	_ = pool.Run(ctx, len(tokens), func(ctx context.Context, i int) error {
+		resolver.Metadata(ctx, tokens[i])
+		return nil
+	})
+}
+
+// Tokens — контракты токенов, встреченные в учтённых переводах, в порядке адресов.
+func (c *transferCounter) Tokens() []common.Address {
+	tokens := make([]common.Address, 0, len(c.seenTokens))
+	for token := range c.seenTokens {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return bytes.Compare(tokens[i].Bytes(), tokens[j].Bytes()) < 0
+	})
+	return tokens
+}
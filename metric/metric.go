@@ -0,0 +1,80 @@
+// Package metric считает ERC20 Transfer по адресам и строит рейтинг самых активных адресов.
+// CLI в корне модуля добавляет к нему обогащение, фильтры и форматы вывода.
+package metric
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Metric — строка рейтинга: адрес (или группа адресов) и накопленные по нему значения.
+type Metric struct {
+	Address  common.Address
+	Name     string
+	Group    string
+	Count    int
+	Score    float64
+	RawValue *big.Int
+	Value    string
+	// InflowValue — сырая сумма только полученных переводов (-sort inflow).
+	InflowValue *big.Int
+	// Rate — событий на блок между первым и последним блоком активности адреса.
+	Rate float64
+	// Chain — сеть, в которой посчитана строка; пусто, если сеть не задана.
+	Chain string
+	// Sent и Received — отправленный и полученный объём в единицах токенов (с учётом decimals).
+	Sent, Received *big.Rat
+	// SentCount и ReceivedCount — число отправленных и полученных переводов по отдельности.
+	SentCount, ReceivedCount int
+	// Txs — различные транзакции адреса, PerTx — переводов на транзакцию (Count / Txs).
+	Txs   int
+	PerTx float64
+	// GasUsed и Fee — газ и комиссия в нативной монете транзакций, отправленных самим адресом (-gas).
+	GasUsed uint64
+	Fee     *big.Rat
+	// USD — отправленный и полученный объём в долларах по цене токена на блоке перевода.
+	USD *big.Rat
+}
+
+// Label — подпись строки: группа или адрес в hex.
+func (m Metric) Label() string {
+	if m.Group != "" {
+		return m.Group
+	}
+	return m.Address.Hex()
+}
+
+// ErrNoLogs возвращается, если в диапазоне не нашлось ни одного Transfer лога.
+var ErrNoLogs = errors.New("no logs in map to sort")
+
+func SortAddressesByCount(logsMap map[common.Address]int) ([]Metric, error) {
+	if len(logsMap) == 0 {
+		return nil, ErrNoLogs
+	}
+
+	counters := make([]Metric, 0, len(logsMap))
+
+	for address, count := range logsMap {
+		if address != (common.Address{}) {
+			counters = append(counters, Metric{Address: address, Count: count})
+		}
+	}
+
+	SortByCount(counters)
+	return counters, nil
+}
+
+// SortByCount упорядочивает строки по убыванию числа переводов, равные — по возрастанию байт
+// адреса: порядок обхода map случаен, и без этого одинаковые входные данные давали бы разный состав топа.
+func SortByCount(counters []Metric) {
+	sort.Slice(counters, func(i, j int) bool {
+		if counters[i].Count != counters[j].Count {
+			return counters[i].Count > counters[j].Count
+		}
+		return bytes.Compare(counters[i].Address[:], counters[j].Address[:]) < 0
+	})
+}
@@ -0,0 +1,77 @@
+package metric
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSortAddressesByCountTies(t *testing.T) {
+	counts := map[common.Address]int{
+		common.HexToAddress("0x03"): 2,
+		common.HexToAddress("0x01"): 2,
+		common.HexToAddress("0x04"): 5,
+		common.HexToAddress("0x02"): 2,
+	}
+	want := []common.Address{
+		common.HexToAddress("0x04"),
+		common.HexToAddress("0x01"),
+		common.HexToAddress("0x02"),
+		common.HexToAddress("0x03"),
+	}
+	for run := 0; run < 20; run++ {
+		got, err := SortAddressesByCount(counts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got %d metrics, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Address != want[i] {
+				t.Fatalf("run %d: position %d is %s, want %s", run, i, got[i].Address.Hex(), want[i].Hex())
+			}
+		}
+	}
+}
+
+func TestSortAddressesByCountZeroAddress(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts map[common.Address]int
+		want   []Metric
+		err    error
+	}{
+		{
+			name:   "mint counterparty kept",
+			counts: map[common.Address]int{{}: 3, common.HexToAddress("0x01"): 3},
+			want:   []Metric{{Address: common.HexToAddress("0x01"), Count: 3}},
+		},
+		{
+			name:   "only the zero address",
+			counts: map[common.Address]int{{}: 2},
+			want:   []Metric{},
+		},
+		{
+			name: "no logs",
+			err:  ErrNoLogs,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SortAddressesByCount(tt.counts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i].Address != tt.want[i].Address || got[i].Count != tt.want[i].Count {
+					t.Errorf("row %d = %s %d, want %s %d", i, got[i].Address.Hex(), got[i].Count, tt.want[i].Address.Hex(), tt.want[i].Count)
+				}
+			}
+		})
+	}
+}
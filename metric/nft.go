@@ -0,0 +1,101 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const transferBatchEventABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"ids","type":"uint256[]"},{"indexed":false,"name":"values","type":"uint256[]"}],"name":"TransferBatch","type":"event"}]`
+
+// Сигнатуры событий ERC-1155; ERC-721 Transfer совпадает по topic0 с ERC-20 (TransferEventHash).
+var (
+	TransferSingleEventHash = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	TransferBatchEventHash  = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+)
+
+// ErrNotNFTTransfer возвращается для логов, которые не являются переводом NFT выбранного стандарта.
+var ErrNotNFTTransfer = errors.New("log is not an NFT transfer")
+
+var transferBatchABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(transferBatchEventABI))
+	if err != nil {
+		panic(fmt.Sprintf("failed in marshall abi contract: %v", err))
+	}
+	return parsed
+}()
+
+// NFTTransfer — перевод одного токена коллекции: для ERC-721 Amount всегда 1.
+type NFTTransfer struct {
+	From    common.Address
+	To      common.Address
+	TokenID *big.Int
+	Amount  *big.Int
+}
+
+// DecodeERC721Transfer разбирает ERC-721 Transfer: from, to и tokenId приходят в топиках, data пустая.
+func DecodeERC721Transfer(vLog types.Log) (NFTTransfer, error) {
+	if len(vLog.Topics) != 4 {
+		return NFTTransfer{}, fmt.Errorf("%w: ERC721 Transfer expects 4 topics, got %d", ErrNotNFTTransfer, len(vLog.Topics))
+	}
+	if vLog.Topics[0] != TransferEventHash {
+		return NFTTransfer{}, fmt.Errorf("%w: unexpected topic0 %s", ErrNotNFTTransfer, vLog.Topics[0].Hex())
+	}
+
+	return NFTTransfer{
+		From:    common.BytesToAddress(vLog.Topics[1].Bytes()),
+		To:      common.BytesToAddress(vLog.Topics[2].Bytes()),
+		TokenID: vLog.Topics[3].Big(),
+		Amount:  big.NewInt(1),
+	}, nil
+}
+
+// DecodeERC1155Transfer разбирает TransferSingle и TransferBatch: operator, from и to в топиках,
+// id и value (или массивы ids и values) в data. Батч возвращается по переводу на каждый id.
+func DecodeERC1155Transfer(vLog types.Log) ([]NFTTransfer, error) {
+	if len(vLog.Topics) != 4 {
+		return nil, fmt.Errorf("%w: ERC1155 transfer expects 4 topics, got %d", ErrNotNFTTransfer, len(vLog.Topics))
+	}
+	from := common.BytesToAddress(vLog.Topics[2].Bytes())
+	to := common.BytesToAddress(vLog.Topics[3].Bytes())
+
+	switch vLog.Topics[0] {
+	case TransferSingleEventHash:
+		if len(vLog.Data) != 64 {
+			return nil, fmt.Errorf("unpack TransferSingle in tx %s: expected 64 bytes of data, got %d", vLog.TxHash.Hex(), len(vLog.Data))
+		}
+		return []NFTTransfer{{
+			From:    from,
+			To:      to,
+			TokenID: new(big.Int).SetBytes(vLog.Data[:32]),
+			Amount:  new(big.Int).SetBytes(vLog.Data[32:]),
+		}}, nil
+
+	case TransferBatchEventHash:
+		var batch struct {
+			Ids    []*big.Int
+			Values []*big.Int
+		}
+		if err := transferBatchABI.UnpackIntoInterface(&batch, "TransferBatch", vLog.Data); err != nil {
+			return nil, fmt.Errorf("unpack TransferBatch in tx %s: %w", vLog.TxHash.Hex(), err)
+		}
+		if len(batch.Ids) != len(batch.Values) {
+			return nil, fmt.Errorf("unpack TransferBatch in tx %s: %d ids but %d values", vLog.TxHash.Hex(), len(batch.Ids), len(batch.Values))
+		}
+
+		transfers := make([]NFTTransfer, len(batch.Ids))
+		for i := range batch.Ids {
+			transfers[i] = NFTTransfer{From: from, To: to, TokenID: batch.Ids[i], Amount: batch.Values[i]}
+		}
+		return transfers, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unexpected topic0 %s", ErrNotNFTTransfer, vLog.Topics[0].Hex())
+	}
+}
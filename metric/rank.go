@@ -0,0 +1,19 @@
+package metric
+
+import "github.com/ethereum/go-ethereum/common"
+
+// RankOf возвращает место адреса (с 1) в отсортированном рейтинге. Адреса с равным числом
+// переводов (и равным score -decay) делят место — 1, 2, 2, 4, — поэтому ответ не зависит от того,
+// в каком порядке стоят равные строки.
+func RankOf(metrics []Metric, addr common.Address) (rank int, m Metric, found bool) {
+	for i, metric := range metrics {
+		if metric.Group != "" || metric.Address != addr {
+			continue
+		}
+		for i > 0 && metrics[i-1].Count == metric.Count && metrics[i-1].Score == metric.Score {
+			i--
+		}
+		return i + 1, metric, true
+	}
+	return 0, Metric{}, false
+}
@@ -0,0 +1,50 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRankOf(t *testing.T) {
+	metrics := []Metric{
+		{Address: common.HexToAddress("0x01"), Count: 9},
+		{Address: common.HexToAddress("0x02"), Count: 4},
+		{Address: common.HexToAddress("0x03"), Count: 4},
+		{Address: common.HexToAddress("0x04"), Count: 4},
+		{Address: common.HexToAddress("0x05"), Count: 1},
+		{Group: "0x06", Count: 1},
+	}
+	tests := []struct {
+		address string
+		rank    int
+		found   bool
+	}{
+		{"0x01", 1, true},
+		{"0x02", 2, true},
+		{"0x03", 2, true},
+		{"0x04", 2, true},
+		{"0x05", 5, true},
+		{"0x06", 0, false},
+		{"0x07", 0, false},
+	}
+	for _, tt := range tests {
+		rank, m, found := RankOf(metrics, common.HexToAddress(tt.address))
+		if rank != tt.rank || found != tt.found {
+			t.Errorf("RankOf(%s) = %d, %v; want %d, %v", tt.address, rank, found, tt.rank, tt.found)
+		}
+		if found && m.Address != common.HexToAddress(tt.address) {
+			t.Errorf("RankOf(%s) returned the row of %s", tt.address, m.Address.Hex())
+		}
+	}
+}
+
+func TestRankOfScoreTies(t *testing.T) {
+	metrics := []Metric{
+		{Address: common.HexToAddress("0x01"), Count: 3, Score: 2.5},
+		{Address: common.HexToAddress("0x02"), Count: 3, Score: 1.5},
+	}
+	if rank, _, _ := RankOf(metrics, common.HexToAddress("0x02")); rank != 2 {
+		t.Errorf("rank of a lower score with the same count is %d, want 2", rank)
+	}
+}
@@ -0,0 +1,116 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Client — часть ethclient.Client, которая нужна Scanner; *ethclient.Client ей
+// удовлетворяет, а в тестах и своих сервисах можно подставить любую реализацию.
+type Client interface {
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// Counter — движок, которым Scanner считает диапазон. Без WithCounter Scanner считает сам
+// одним FilterLogs; CLI подставляет свой счётчик с окнами -chunk-size, фильтрами и обогащением.
+// Counter накапливает счётчики одного скана: для нового скана нужен новый Counter.
+type Counter interface {
+	CountRange(ctx context.Context, client Client, from, to *big.Int) error
+	// Counts — несортированные счётчики переводов по адресам, включая нулевой адрес.
+	Counts() map[common.Address]int
+	Stats() ScanStats
+}
+
+// Scanner считает Transfer логи диапазонов блоков через Client.
+type Scanner struct {
+	client  Client
+	counter Counter
+}
+
+// Option настраивает Scanner в NewScanner.
+type Option func(*Scanner)
+
+// WithCounter подставляет свой движок подсчёта вместо встроенного.
+func WithCounter(counter Counter) Option {
+	return func(s *Scanner) { s.counter = counter }
+}
+
+func NewScanner(client Client, opts ...Option) *Scanner {
+	s := &Scanner{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CountTransfers считает Transfer в блоках [from, to] (отправитель и получатель,
+// по одному разу за перевод) и возвращает несортированную карту счётчиков.
+//
+// Порядок операций встроенного счётчика: FilterLogs по topic0 Transfer → разбор каждого лога →
+// подсчёт. Логи, которые не разбираются как ERC20 Transfer (ERC721, битые data), пропускаются.
+// Нулевой адрес (минты и сжигания) в карте остаётся; его отбрасывает уже сортировка
+// SortAddressesByCount, которую вызывает TopAddresses. ScanStats — диапазон и число логов
+// и переводов, а также всё, что посчитал подставленный Counter.
+func (s *Scanner) CountTransfers(ctx context.Context, from, to *big.Int) (map[common.Address]int, ScanStats, error) {
+	counter := s.counter
+	if counter == nil {
+		counter = &logCounter{counts: make(map[common.Address]int)}
+	}
+	if err := counter.CountRange(ctx, s.client, from, to); err != nil {
+		return nil, ScanStats{}, err
+	}
+	return counter.Counts(), counter.Stats(), nil
+}
+
+// TopAddresses — CountTransfers и сортировка по убыванию числа переводов.
+func (s *Scanner) TopAddresses(ctx context.Context, from, to *big.Int) ([]Metric, error) {
+	counts, _, err := s.CountTransfers(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return SortAddressesByCount(counts)
+}
+
+// logCounter — встроенный Counter: один FilterLogs на диапазон.
+type logCounter struct {
+	counts map[common.Address]int
+	stats  ScanStats
+}
+
+func (c *logCounter) CountRange(ctx context.Context, client Client, from, to *big.Int) error {
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: from,
+		ToBlock:   to,
+		Topics:    [][]common.Hash{{TransferEventHash}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to filter logs: %w", err)
+	}
+
+	c.stats.FromBlock, c.stats.ToBlock = from.Uint64(), to.Uint64()
+	c.stats.Logs += len(logs)
+	for _, vLog := range logs {
+		transferEvent, err := DecodeTransfer(vLog)
+		if err != nil {
+			c.stats.UnpackFailures++
+			continue
+		}
+		c.stats.Transfers++
+		c.counts[transferEvent.From]++
+		c.counts[transferEvent.To]++
+	}
+	return nil
+}
+
+func (c *logCounter) Counts() map[common.Address]int {
+	return c.counts
+}
+
+func (c *logCounter) Stats() ScanStats {
+	return c.stats
+}
@@ -0,0 +1,94 @@
+package metric
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type logsClient []types.Log
+
+func (c logsClient) FilterLogs(context.Context, ethereum.FilterQuery) ([]types.Log, error) {
+	return c, nil
+}
+
+func transferLog(from, to common.Address, value int64) types.Log {
+	return types.Log{
+		Topics: []common.Hash{TransferEventHash, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:   common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+	}
+}
+
+// recordingCounter — Counter, который только запоминает, что его вызвали.
+type recordingCounter struct {
+	from, to *big.Int
+}
+
+func (c *recordingCounter) CountRange(_ context.Context, _ Client, from, to *big.Int) error {
+	c.from, c.to = from, to
+	return nil
+}
+
+func (c *recordingCounter) Counts() map[common.Address]int {
+	return map[common.Address]int{common.HexToAddress("0x09"): 7}
+}
+
+func (c *recordingCounter) Stats() ScanStats {
+	return ScanStats{Duplicates: 3}
+}
+
+func TestScannerCountTransfers(t *testing.T) {
+	a, b := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	client := logsClient{
+		transferLog(a, b, 1),
+		transferLog(common.Address{}, a, 5),
+		{Topics: []common.Hash{TransferEventHash}},
+	}
+	counts, stats, err := NewScanner(client).CountTransfers(context.Background(), big.NewInt(1), big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStats := ScanStats{FromBlock: 1, ToBlock: 2, Logs: 3, Transfers: 2, UnpackFailures: 1}
+	if stats.FromBlock != wantStats.FromBlock || stats.ToBlock != wantStats.ToBlock || stats.Logs != wantStats.Logs ||
+		stats.Transfers != wantStats.Transfers || stats.UnpackFailures != wantStats.UnpackFailures {
+		t.Errorf("stats = %+v, want %+v", stats, wantStats)
+	}
+	want := map[common.Address]int{a: 2, b: 1, {}: 1}
+	if len(counts) != len(want) {
+		t.Fatalf("counts = %v, want %v", counts, want)
+	}
+	for address, n := range want {
+		if counts[address] != n {
+			t.Errorf("counts[%s] = %d, want %d", address.Hex(), counts[address], n)
+		}
+	}
+
+	top, err := NewScanner(client).TopAddresses(context.Background(), big.NewInt(1), big.NewInt(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(top) != 2 || top[0].Address != a {
+		t.Errorf("TopAddresses = %+v, want %s first and no zero address", top, a.Hex())
+	}
+}
+
+func TestScannerWithCounter(t *testing.T) {
+	counter := &recordingCounter{}
+	counts, stats, err := NewScanner(logsClient{}, WithCounter(counter)).CountTransfers(context.Background(), big.NewInt(3), big.NewInt(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counter.from.Int64() != 3 || counter.to.Int64() != 4 {
+		t.Errorf("counter got blocks %v-%v, want 3-4", counter.from, counter.to)
+	}
+	if counts[common.HexToAddress("0x09")] != 7 {
+		t.Errorf("counts = %v, want the counter's own map", counts)
+	}
+	if stats.Duplicates != 3 {
+		t.Errorf("stats = %+v, want the counter's own stats", stats)
+	}
+}
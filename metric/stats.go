@@ -0,0 +1,33 @@
+package metric
+
+import "math/big"
+
+// ScanStats описывает просканированный диапазон и объём обработанных логов.
+type ScanStats struct {
+	FromBlock uint64
+	ToBlock   uint64
+	Logs      int
+	Transfers int
+	RPCErrors int
+	CacheHits int
+	// UnpackFailures — логи с topic0 Transfer, которые не удалось разобрать.
+	UnpackFailures int
+	// Duplicates — логи, отброшенные -dedupe-logs как повтор (TxHash, Index).
+	Duplicates int
+
+	// Минты и сжигания: сырые суммы по всем токенам и, с -decimals, суммы с учётом decimals.
+	Mints, Burns             int
+	Minted, Burned           *big.Int
+	MintedValue, BurnedValue string
+	NetValue                 string
+
+	// FailedRanges — окна, которые -partial-ok пропустил после ошибок.
+	FailedRanges []FailedRange
+}
+
+// FailedRange — окно блоков, которое не удалось получить даже после повторов; с -partial-ok
+// скан продолжается без него, и рейтинг не учитывает его переводы.
+type FailedRange struct {
+	From, To uint64
+	Err      error
+}
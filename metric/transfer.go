@@ -0,0 +1,60 @@
+package metric
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const transferEventABI = `[{"anonymous":false,"inputs":[{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Transfer","type":"event"}]`
+
+// TransferEventHash — topic0 события Transfer(address,address,uint256).
+var TransferEventHash = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// ErrNotTransfer возвращается для логов, которые не являются ERC20 Transfer.
+var ErrNotTransfer = errors.New("log is not an ERC20 Transfer")
+
+var transferABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(transferEventABI))
+	if err != nil {
+		panic(fmt.Sprintf("failed in marshall abi contract: %v", err))
+	}
+	return parsed
+}()
+
+// TransferEvents — разобранный ERC20 Transfer.
+type TransferEvents struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+}
+
+// DecodeTransfer разбирает ERC20 Transfer: from/to из топиков, value из data.
+func DecodeTransfer(vLog types.Log) (TransferEvents, error) {
+	var transferEvent TransferEvents
+
+	if len(vLog.Topics) != 3 {
+		return transferEvent, fmt.Errorf("%w: expected 3 topics, got %d", ErrNotTransfer, len(vLog.Topics))
+	}
+	if vLog.Topics[0] != TransferEventHash {
+		return transferEvent, fmt.Errorf("%w: unexpected topic0 %s", ErrNotTransfer, vLog.Topics[0].Hex())
+	}
+	if len(vLog.Data) == 0 {
+		return transferEvent, fmt.Errorf("%w: empty data", ErrNotTransfer)
+	}
+
+	transferEvent.From = common.HexToAddress(vLog.Topics[1].Hex())
+	transferEvent.To = common.HexToAddress(vLog.Topics[2].Hex())
+
+	if err := transferABI.UnpackIntoInterface(&transferEvent, "Transfer", vLog.Data); err != nil {
+		return transferEvent, fmt.Errorf("unpack transfer value in tx %s: %w", vLog.TxHash.Hex(), err)
+	}
+
+	return transferEvent, nil
+}
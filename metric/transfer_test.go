@@ -0,0 +1,66 @@
+package metric
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecodeTransfer(t *testing.T) {
+	from := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	to := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	value := common.LeftPadBytes(big.NewInt(1234).Bytes(), 32)
+	topics := []common.Hash{TransferEventHash, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}
+
+	tests := []struct {
+		name    string
+		log     types.Log
+		wantErr error
+	}{
+		{"valid", types.Log{Topics: topics, Data: value}, nil},
+		{"erc721 with tokenId topic", types.Log{Topics: append(topics[:3:3], common.Hash{}), Data: nil}, ErrNotTransfer},
+		{"missing to topic", types.Log{Topics: topics[:2], Data: value}, ErrNotTransfer},
+		{"other event", types.Log{Topics: []common.Hash{{1}, topics[1], topics[2]}, Data: value}, ErrNotTransfer},
+		{"empty data", types.Log{Topics: topics}, ErrNotTransfer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer, err := DecodeTransfer(tt.log)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if transfer.From != from || transfer.To != to || transfer.Value.Cmp(big.NewInt(1234)) != 0 {
+				t.Errorf("decoded %s -> %s %v", transfer.From.Hex(), transfer.To.Hex(), transfer.Value)
+			}
+		})
+	}
+
+	t.Run("short data", func(t *testing.T) {
+		if _, err := DecodeTransfer(types.Log{Topics: topics, Data: value[:16]}); err == nil {
+			t.Fatal("decoded a value of 16 bytes")
+		}
+	})
+}
+
+func BenchmarkDecodeTransfer(b *testing.B) {
+	vLog := types.Log{
+		Topics: []common.Hash{
+			TransferEventHash,
+			common.BytesToHash(common.HexToAddress("0x1111111111111111111111111111111111111111").Bytes()),
+			common.BytesToHash(common.HexToAddress("0x2222222222222222222222222222222222222222").Bytes()),
+		},
+		Data: common.LeftPadBytes(big.NewInt(1234567890).Bytes(), 32),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := DecodeTransfer(vLog); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+const (
+	// natsDialTimeout — ожидание TCP-соединения и ответа на первый PING.
+	natsDialTimeout = 10 * time.Second
+	// natsTokenPlaceholder в -nats-subject заменяется адресом токена перевода.
+	natsTokenPlaceholder = "{token}"
+)
+
+// natsPublisher публикует каждый разобранный перевод сообщением JSON (как строка -follow-logs)
+// в NATS по текстовому протоколу ядра NATS: CONNECT, затем PUB на каждый перевод.
+// На PING сервера отвечает PONG; если соединение оборвалось, публикация переподключается один раз.
+type natsPublisher struct {
+	address string
+	connect []byte
+	subject string
+
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+	// failed закрывается читателем соединения, когда сервер ответил -ERR или закрыл его.
+	failed chan struct{}
+	err    error
+	// pongs получает ответы на PING из Close.
+	pongs chan struct{}
+}
+
+// openNATSPublisher подключается к rawURL вида nats://[user:pass@|token@]host[:port].
+func openNATSPublisher(rawURL, subject string) (*natsPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid -nats-url %q: expected nats://host[:port]", rawURL)
+	}
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return nil, fmt.Errorf("invalid -nats-subject %q", subject)
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "getBlock metric"}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"], options["pass"] = u.User.Username(), password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "4222"
+	}
+	p := &natsPublisher{
+		address: net.JoinHostPort(u.Hostname(), port),
+		connect: connect,
+		subject: subject,
+	}
+	if err := p.dial(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// dial открывает соединение и дожидается PONG: так ошибка авторизации видна сразу, а не при первом переводе.
+func (p *natsPublisher) dial() error {
+	conn, err := net.DialTimeout("tcp", p.address, natsDialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(natsDialTimeout))
+	r := bufio.NewReader(conn)
+	info, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("failed to connect to NATS: unexpected greeting %q", strings.TrimSpace(info))
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", p.connect); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to connect to NATS: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return fmt.Errorf("NATS rejected connection: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	p.conn, p.w = conn, bufio.NewWriter(conn)
+	p.failed, p.err, p.pongs = make(chan struct{}), nil, make(chan struct{}, 1)
+	go p.read(conn, r, p.failed, p.pongs)
+	return nil
+}
+
+// read отвечает на PING сервера и запоминает -ERR; после ошибки соединение считается потерянным.
+func (p *natsPublisher) read(conn net.Conn, r *bufio.Reader, failed, pongs chan struct{}) {
+	defer close(failed)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			p.setErr(fmt.Errorf("NATS connection lost: %w", err))
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			select {
+			case pongs <- struct{}{}:
+			default:
+			}
+		case line == "PING":
+			p.mu.Lock()
+			_, err := conn.Write([]byte("PONG\r\n"))
+			p.mu.Unlock()
+			if err != nil {
+				p.setErr(fmt.Errorf("NATS connection lost: %w", err))
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			p.setErr(fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+			return
+		}
+	}
+}
+
+func (p *natsPublisher) setErr(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *natsPublisher) lost() bool {
+	select {
+	case <-p.failed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *natsPublisher) Write(vLog types.Log, transferEvent metric.TransferEvents) error {
+	message, err := json.Marshal(newFeedRecord(vLog, transferEvent))
+	if err != nil {
+		return err
+	}
+	subject := strings.ReplaceAll(p.subject, natsTokenPlaceholder, strings.ToLower(vLog.Address.Hex()))
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.lost() {
+		logger.Warn("reconnecting to NATS", "err", p.err)
+		p.conn.Close()
+		if err := p.dial(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(p.w, "PUB %s %d\r\n", subject, len(message))
+	p.w.Write(message)
+	p.w.WriteString("\r\n")
+	// Сообщения уходят сразу, как строки -follow-logs: подписчик видит перевод, пока идёт скан.
+	if err := p.w.Flush(); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %w", err)
+	}
+	return nil
+}
+
+// Close отправляет PING и ждёт PONG, чтобы сервер успел принять все уже отправленные сообщения.
+func (p *natsPublisher) Close() error {
+	p.mu.Lock()
+	_, err := fmt.Fprint(p.w, "PING\r\n")
+	if err == nil {
+		err = p.w.Flush()
+	}
+	failed, pongs := p.failed, p.pongs
+	p.mu.Unlock()
+	defer p.conn.Close()
+	if err != nil {
+		return fmt.Errorf("failed to flush NATS connection: %w", err)
+	}
+
+	select {
+	case <-pongs:
+		return nil
+	case <-failed:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return p.err
+	case <-time.After(natsDialTimeout):
+		return fmt.Errorf("failed to flush NATS connection: no PONG within %s", natsDialTimeout)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"getBlock/metric"
+)
+
+// natsMessage — PUB, принятый тестовым сервером.
+type natsMessage struct {
+	Subject string
+	Payload []byte
+}
+
+// startTestNATS поднимает сервер текстового протокола NATS: отвечает на PING, читает PUB и
+// отдаёт CONNECT и сообщения в connects и messages; reject, если задан, уходит в ответ на CONNECT как -ERR.
+func startTestNATS(t *testing.T, reject string) (string, chan string, chan natsMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	connects, messages := make(chan string, 4), make(chan natsMessage, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestNATS(conn, reject, connects, messages)
+		}
+	}()
+	return "nats://" + listener.Addr().String(), connects, messages
+}
+
+func serveTestNATS(conn net.Conn, reject string, connects chan string, messages chan natsMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			connects <- strings.TrimPrefix(line, "CONNECT ")
+			if reject != "" {
+				fmt.Fprintf(conn, "-ERR '%s'\r\n", reject)
+				return
+			}
+		case line == "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case strings.HasPrefix(line, "PUB "):
+			fields := strings.Fields(line)
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			messages <- natsMessage{Subject: fields[1], Payload: payload[:size]}
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	url, connects, messages := startTestNATS(t, "")
+	publisher, err := openNATSPublisher(strings.Replace(url, "nats://", "nats://alice:secret@", 1), "erc20.{token}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var options map[string]any
+	if err := json.Unmarshal([]byte(<-connects), &options); err != nil {
+		t.Fatal(err)
+	}
+	if options["user"] != "alice" || options["pass"] != "secret" {
+		t.Errorf("CONNECT = %v, want user alice with password", options)
+	}
+
+	vLog := transferLog(testAddress(1), testAddress(2), 7, 10, 3)
+	event := metric.TransferEvents{From: testAddress(1), To: testAddress(2), Value: big.NewInt(7)}
+	if err := publisher.Write(vLog, event); err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	message := <-messages
+	if want := "erc20." + strings.ToLower(testToken.Hex()); message.Subject != want {
+		t.Errorf("subject = %s, want %s", message.Subject, want)
+	}
+	var record feedRecord
+	if err := json.Unmarshal(message.Payload, &record); err != nil {
+		t.Fatal(err)
+	}
+	if want := newFeedRecord(vLog, event); record != want {
+		t.Errorf("message = %+v, want %+v", record, want)
+	}
+}
+
+func TestNATSPublisherRejected(t *testing.T) {
+	url, _, _ := startTestNATS(t, "Authorization Violation")
+	_, err := openNATSPublisher(url, "metric.transfers")
+	if err == nil || !strings.Contains(err.Error(), "Authorization Violation") {
+		t.Errorf("got %v, want the server's -ERR", err)
+	}
+}
+
+func TestNATSPublisherInvalid(t *testing.T) {
+	for _, tc := range []struct{ url, subject string }{
+		{"http://localhost:4222", "metric.transfers"},
+		{"nats://", "metric.transfers"},
+		{"nats://localhost:4222", "metric transfers"},
+	} {
+		if _, err := openNATSPublisher(tc.url, tc.subject); err == nil {
+			t.Errorf("openNATSPublisher(%q, %q) succeeded", tc.url, tc.subject)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+const (
+	standardERC20   = "erc20"
+	standardERC721  = "erc721"
+	standardERC1155 = "erc1155"
+)
+
+func validateStandard(standard string) error {
+	switch standard {
+	case standardERC20, standardERC721, standardERC1155:
+		return nil
+	}
+	return fmt.Errorf("invalid -standard %q: expected erc20, erc721 or erc1155", standard)
+}
+
+// nftTopics — фильтр топиков для -standard erc721/erc1155. ERC-721 Transfer отличается от ERC-20
+// только числом топиков, поэтому фильтр тот же, а лишние логи отсеивает декодер.
+// У ERC-1155 topic1 — operator, from и to сдвинуты в topic2 и topic3.
+func (opts scanOptions) nftTopics() [][]common.Hash {
+	if opts.Standard == standardERC721 {
+		topics := [][]common.Hash{{metric.TransferEventHash}}
+		if len(opts.FromAny) == 0 && len(opts.ToAny) == 0 {
+			return topics
+		}
+		return append(topics, addressTopics(opts.FromAny), addressTopics(opts.ToAny))
+	}
+
+	topics := [][]common.Hash{{metric.TransferSingleEventHash, metric.TransferBatchEventHash}}
+	if len(opts.FromAny) == 0 && len(opts.ToAny) == 0 {
+		return topics
+	}
+	return append(topics, nil, addressTopics(opts.FromAny), addressTopics(opts.ToAny))
+}
+
+// decodeNFT разбирает лог выбранного -standard; ok=false — лог другого стандарта.
+func (c *transferCounter) decodeNFT(vLog types.Log) ([]metric.NFTTransfer, bool) {
+	if c.opts.Standard == standardERC721 {
+		transfer, err := metric.DecodeERC721Transfer(vLog)
+		if err != nil {
+			return nil, false
+		}
+		return []metric.NFTTransfer{transfer}, true
+	}
+
+	transfers, err := metric.DecodeERC1155Transfer(vLog)
+	if err != nil {
+		if len(vLog.Topics) > 0 && (vLog.Topics[0] == metric.TransferSingleEventHash || vLog.Topics[0] == metric.TransferBatchEventHash) {
+			c.stats.IncUnpackFailure()
+		}
+		return nil, false
+	}
+	return transfers, true
+}
+
+// addNFT засчитывает каждый переданный токен отправителю и получателю по -direction.
+// Объём без decimals не сравним между коллекциями, поэтому значение не суммируется.
+func (c *transferCounter) addNFT(vLog types.Log) {
+	transfers, ok := c.decodeNFT(vLog)
+	if !ok {
+		return
+	}
+
+	weight := 1.0
+	if c.opts.Decay != "" {
+		weight = decayWeight(c.opts.Decay, c.opts.DecayHalfLife, c.fromBlock, c.toBlock, vLog.BlockNumber)
+	}
+
+	c.countTokenEvent(vLog.Address)
+	for _, transfer := range transfers {
+		c.countTransfer(vLog.BlockNumber)
+		if c.opts.Sides {
+			c.sent[transfer.From]++
+			c.received[transfer.To]++
+		}
+		if c.opts.Direction != directionIn {
+			c.count(transfer.From, vLog, nil, nil, weight)
+		}
+		if c.opts.Direction != directionOut {
+			c.count(transfer.To, vLog, nil, nil, weight)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+const (
+	telegramAPI = "https://api.telegram.org"
+	// notifyQueueSize — сколько уведомлений может ждать отправки; при переполнении новые отбрасываются.
+	notifyQueueSize = 256
+)
+
+// transferNotification — перевод с участием адреса из -watchlist-file; в таком виде его получает -notify-webhook.
+type transferNotification struct {
+	Address      string `json:"address"`
+	Label        string `json:"label,omitempty"`
+	Direction    string `json:"direction"`
+	Token        string `json:"token"`
+	Counterparty string `json:"counterparty"`
+	RawValue     string `json:"raw_value"`
+	Value        string `json:"value,omitempty"`
+	TxHash       string `json:"tx_hash"`
+	Block        uint64 `json:"block"`
+}
+
+func (n transferNotification) text() string {
+	subject := n.Address
+	if n.Label != "" {
+		subject += " (" + n.Label + ")"
+	}
+	value := n.RawValue + " raw"
+	if n.Value != "" {
+		value = n.Value
+	}
+	verb, preposition := "sent", "to"
+	if n.Direction == "in" {
+		verb, preposition = "received", "from"
+	}
+	return fmt.Sprintf("watched address %s %s %s of token %s %s %s in tx %s (block %d)",
+		subject, verb, value, n.Token, preposition, n.Counterparty, n.TxHash, n.Block)
+}
+
+// loadWatchlistFile читает адреса для уведомлений: по одному в строке, после адреса — необязательная подпись.
+// Пустые строки и строки с # пропускаются.
+func loadWatchlistFile(path string) (map[common.Address]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watchlist: %w", err)
+	}
+	defer f.Close()
+
+	watched := make(map[common.Address]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rawAddress, label, _ := strings.Cut(text, " ")
+		if !common.IsHexAddress(rawAddress) {
+			return nil, fmt.Errorf("%s:%d: invalid address %q", path, line, rawAddress)
+		}
+		watched[common.HexToAddress(rawAddress)] = strings.TrimSpace(label)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read watchlist: %w", err)
+	}
+	if len(watched) == 0 {
+		return nil, fmt.Errorf("watchlist %s has no addresses", path)
+	}
+	return watched, nil
+}
+
+// transferNotifier в режиме -watch отправляет каждый перевод адреса из списка в webhook и/или Telegram.
+// Отправка идёт в отдельной горутине, чтобы медленный получатель не задерживал подписку.
+type transferNotifier struct {
+	watched map[common.Address]string
+
+	webhook        string
+	telegramToken  string
+	telegramChatID string
+	client         *http.Client
+
+	queue chan transferNotification
+}
+
+func newTransferNotifier(watched map[common.Address]string, webhook, telegramToken, telegramChatID string) *transferNotifier {
+	return &transferNotifier{
+		watched:        watched,
+		webhook:        webhook,
+		telegramToken:  telegramToken,
+		telegramChatID: telegramChatID,
+		client:         &http.Client{Timeout: 10 * time.Second},
+		queue:          make(chan transferNotification, notifyQueueSize),
+	}
+}
+
+// Notify ставит в очередь уведомление для каждой стороны перевода, которая есть в списке.
+func (n *transferNotifier) Notify(vLog types.Log, transferEvent metric.TransferEvents, scaled *big.Rat) {
+	for _, side := range []struct {
+		address, counterparty common.Address
+		direction             string
+	}{
+		{transferEvent.From, transferEvent.To, "out"},
+		{transferEvent.To, transferEvent.From, "in"},
+	} {
+		label, ok := n.watched[side.address]
+		if !ok {
+			continue
+		}
+
+		notification := transferNotification{
+			Address:      side.address.Hex(),
+			Label:        label,
+			Direction:    side.direction,
+			Token:        vLog.Address.Hex(),
+			Counterparty: side.counterparty.Hex(),
+			RawValue:     transferEvent.Value.String(),
+			TxHash:       vLog.TxHash.Hex(),
+			Block:        vLog.BlockNumber,
+		}
+		if scaled != nil {
+			notification.Value = formatDecimal(scaled)
+		}
+
+		select {
+		case n.queue <- notification:
+		default:
+			logger.Warn("notification queue is full, dropping alert", "address", notification.Address, "tx", notification.TxHash)
+		}
+	}
+}
+
+// Run отправляет уведомления из очереди, пока не отменён ctx.
+func (n *transferNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-n.queue:
+			if n.webhook != "" {
+				if err := n.postWebhook(ctx, notification); err != nil {
+					logger.Warn("webhook notification failed", "err", err)
+				}
+			}
+			if n.telegramToken != "" {
+				if err := n.sendTelegram(ctx, notification); err != nil {
+					logger.Warn("Telegram notification failed", "err", redactTelegram(err, n.telegramToken))
+				}
+			}
+		}
+	}
+}
+
+func (n *transferNotifier) postWebhook(ctx context.Context, notification transferNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return n.send(req)
+}
+
+func (n *transferNotifier) sendTelegram(ctx context.Context, notification transferNotification) error {
+	form := url.Values{"chat_id": {n.telegramChatID}, "text": {notification.text()}}
+	endpoint := telegramAPI + "/bot" + n.telegramToken + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return n.send(req)
+}
+
+func (n *transferNotifier) send(req *http.Request) error {
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+// redactTelegram убирает токен бота из ошибки: он входит в URL запроса.
+func redactTelegram(err error, token string) string {
+	return strings.ReplaceAll(err.Error(), token, "<token>")
+}
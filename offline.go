@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// loadLogs читает JSON-массив логов в формате eth_getLogs.
+func loadLogs(path string) ([]types.Log, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs file: %w", err)
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse logs file %s: expected a JSON array of logs: %w", path, err)
+	}
+
+	logs := make([]types.Log, len(raw))
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &logs[i]); err != nil {
+			return nil, fmt.Errorf("invalid log #%d in %s: %w", i, path, err)
+		}
+	}
+
+	return logs, nil
+}
+
+// filterContracts оставляет логи указанных контрактов: для -logs-file фильтр -contracts
+// применяется локально, так как запроса к ноде нет.
+func filterContracts(logs []types.Log, contracts []common.Address) []types.Log {
+	allowed := make(map[common.Address]bool, len(contracts))
+	for _, contract := range contracts {
+		allowed[contract] = true
+	}
+
+	filtered := logs[:0]
+	for _, vLog := range logs {
+		if allowed[vLog.Address] {
+			filtered = append(filtered, vLog)
+		}
+	}
+	return filtered
+}
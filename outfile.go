@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// openOutput открывает файл для -out; при compress или расширении .gz запись идёт через gzip.
+// Возвращённый close сбрасывает буферы и закрывает файл.
+func openOutput(path string, compress bool) (io.Writer, func() error, error) {
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output file: %w", err)
+	}
+
+	if !compress && !strings.HasSuffix(path, ".gz") {
+		buffered := bufio.NewWriter(f)
+		return buffered, func() error {
+			if err := buffered.Flush(); err != nil {
+				f.Close()
+				return err
+			}
+			return f.Close()
+		}, nil
+	}
+
+	gz := gzip.NewWriter(f)
+	return gz, func() error {
+		if err := gz.Close(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
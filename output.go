@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+const topN = 5
+
+const (
+	formatText     = "text"
+	formatMarkdown = "markdown"
+	formatBars     = "bars"
+)
+
+type outputOptions struct {
+	Format        string
+	AddressesOnly bool
+	Decimals      bool
+	ENS           bool
+	Fields        []string
+	Decay         bool
+	Approvals     bool
+	Events        bool
+	Sort          string
+	SortSecondary string
+	TopShare      float64
+	BarWidth      int
+	ShortAddr     bool
+	Category      bool
+	Inflow        bool
+	Rate          bool
+	TxCounts      bool
+	Gas           bool
+	Volume        bool
+	USD           bool
+	Sides         bool
+	Chain         chainInfo
+	Standard      string
+	// Tokens — метаданные -token-metadata для подписей токенов; nil — только адреса.
+	Tokens *tokenMetadataResolver
+	Order  string
+	// ValueStats — распределения сумм -value-stats для -format report; задаются перед выводом.
+	ValueStats []valueDistribution
+}
+
+// Formatter выводит рейтинг в одном из форматов -format.
+type Formatter interface {
+	Write(w io.Writer, metrics []Metric, stats ScanStats) error
+}
+
+func newFormatter(opts outputOptions) (Formatter, error) {
+	if opts.AddressesOnly {
+		return addressesFormatter{}, nil
+	}
+
+	switch opts.Format {
+	case formatText:
+		return textFormatter{opts: opts}, nil
+	case formatMarkdown:
+		return markdownFormatter{opts: opts}, nil
+	case formatBars:
+		return barsFormatter{opts: opts}, nil
+	case formatJSON:
+		return jsonFormatter{opts: opts}, nil
+	case formatCSV:
+		return csvFormatter{opts: opts}, nil
+	case formatReport:
+		return reportFormatter{chain: opts.Chain, tokens: opts.Tokens, distributions: opts.ValueStats}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", opts.Format)
+	}
+}
+
+func writeMetrics(w io.Writer, metrics []Metric, stats ScanStats, opts outputOptions) error {
+	formatter, err := newFormatter(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.TopShare > 0 {
+		return writeTopShare(w, formatter, metrics, stats, opts)
+	}
+	return formatter.Write(w, opts.top(metrics), stats)
+}
+
+// top — строки, которые попадают в вывод: весь рейтинг упорядочивается по -sort и -order, и от него
+// берутся первые topN или срез по -top-share. Обрезать до сортировки нельзя: -sort value переставил
+// бы только первые по числу переводов.
+func (opts outputOptions) top(metrics []Metric) []Metric {
+	metrics = opts.sorted(metrics)
+	if opts.TopShare > 0 {
+		n, _, _ := topShareCut(metrics, opts.TopShare)
+		return metrics[:n]
+	}
+	if len(metrics) > topN {
+		return metrics[:topN]
+	}
+	return metrics
+}
+
+// sorted упорядочивает весь рейтинг по -sort, -sort-secondary и -order. С -decay рейтинг по умолчанию
+// построен по score, поэтому -sort count сортирует по нему же.
+func (opts outputOptions) sorted(metrics []Metric) []Metric {
+	by, secondary := opts.Sort, opts.SortSecondary
+	if opts.Decay && by == sortCount {
+		by = sortScore
+		if secondary == "" {
+			secondary = sortCount
+		}
+	}
+	return sortMetrics(metrics, by, secondary, opts.Order)
+}
+
+// topShareCut возвращает размер наименьшего топа, на который приходится доля share всех переводов.
+func topShareCut(metrics []Metric, share float64) (n, covered, total int) {
+	for _, m := range metrics {
+		total += m.Count
+	}
+
+	for n < len(metrics) && float64(covered) < share*float64(total) {
+		covered += metrics[n].Count
+		n++
+	}
+	return n, covered, total
+}
+
+func writeTopShare(w io.Writer, formatter Formatter, metrics []Metric, stats ScanStats, opts outputOptions) error {
+	metrics = opts.sorted(metrics)
+	n, covered, total := topShareCut(metrics, opts.TopShare)
+	if err := formatter.Write(w, metrics[:n], stats); err != nil {
+		return err
+	}
+	if opts.AddressesOnly || opts.machineReadable() || total == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "%d of %d addresses account for %.1f%% of %d counted transfers\n",
+		n, len(metrics), 100*float64(covered)/float64(total), total)
+	return err
+}
+
+type addressesFormatter struct{}
+
+func (addressesFormatter) Write(w io.Writer, metrics []Metric, _ ScanStats) error {
+	for _, m := range metrics {
+		if _, err := fmt.Fprintln(w, m.Label()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type textFormatter struct {
+	opts outputOptions
+}
+
+func (f textFormatter) Write(w io.Writer, metrics []Metric, _ ScanStats) error {
+	for _, m := range metrics {
+		subject := "address " + f.opts.label(m)
+		if m.Name != "" {
+			subject += " (" + m.Name + ")"
+		}
+		if m.Group != "" {
+			subject = "addresses " + m.Group + "*"
+		}
+		if m.Group != "" && f.opts.Category {
+			subject = "category " + m.Group
+		}
+
+		line := fmt.Sprintf("%v used %v %v times", subject, f.opts.standardName(), m.Count)
+		if f.opts.Sides {
+			line += fmt.Sprintf(" (sent %v, received %v)", m.SentCount, m.ReceivedCount)
+		}
+		if f.opts.Approvals {
+			line = fmt.Sprintf("%v granted %v approvals to watched spenders", subject, m.Count)
+		}
+		if f.opts.Events {
+			line = fmt.Sprintf("%v appeared in %v events", subject, m.Count)
+		}
+		if f.opts.Decay {
+			line += fmt.Sprintf(", score %.3f", m.Score)
+		}
+		if f.opts.Decimals {
+			line += rawValueText(m.RawValue, m.Value)
+		}
+		if f.opts.Inflow {
+			line += fmt.Sprintf(", received %v raw", m.InflowValue)
+		}
+		if f.opts.Volume {
+			line += fmt.Sprintf(", sent %v, received %v", formatDecimal(m.Sent), formatDecimal(m.Received))
+		}
+		if f.opts.USD {
+			line += fmt.Sprintf(", $%v", formatUSD(m.USD))
+		}
+		if f.opts.Rate {
+			line += fmt.Sprintf(", %.3f per block", m.Rate)
+		}
+		if f.opts.TxCounts {
+			line += fmt.Sprintf(", in %v txs (%.2f per tx)", m.Txs, m.PerTx)
+		}
+		if f.opts.Gas {
+			line += fmt.Sprintf(", gas used %v, fees %v %v", m.GasUsed, formatDecimal(m.Fee), nativeSymbol(f.opts.Chain))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type markdownFormatter struct {
+	opts outputOptions
+}
+
+func (f markdownFormatter) Write(w io.Writer, metrics []Metric, _ ScanStats) error {
+	fields := f.opts.fields()
+
+	header := make([]string, len(fields))
+	separator := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.Header
+		if field.Name == "address" && len(metrics) > 0 && metrics[0].Group != "" {
+			header[i] = "Prefix"
+			if f.opts.Category {
+				header[i] = "Category"
+			}
+		}
+		separator[i] = "---"
+	}
+	if err := writeMarkdownRow(w, header); err != nil {
+		return err
+	}
+	if err := writeMarkdownRow(w, separator); err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		row := make([]string, len(fields))
+		for i, field := range fields {
+			row[i] = field.Value(m)
+			if field.Name == "address" {
+				row[i] = f.opts.label(m)
+			}
+		}
+		if err := writeMarkdownRow(w, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeMarkdownRow(w io.Writer, cells []string) error {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+	}
+	_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(escaped, " | "))
+	return err
+}
+
+// standardName — название стандарта для текстового вывода: ERC20, ERC721 или ERC1155.
+func (opts outputOptions) standardName() string {
+	if opts.Standard == "" {
+		return "ERC20"
+	}
+	return strings.ToUpper(opts.Standard)
+}
+
+// machineReadable — форматы для программ, в которые нельзя дописывать текстовые сводки.
+func (opts outputOptions) machineReadable() bool {
+	switch opts.Format {
+	case formatJSON, formatCSV, formatReport:
+		return true
+	}
+	return false
+}
+
+// rawValueText — ", value N raw (V)" текстового вывода -decimals; если decimals токенов неизвестны,
+// суммы в токенах нет, и скобки опускаются вместо ложного (0).
+func rawValueText(raw *big.Int, value string) string {
+	if value == "" {
+		return fmt.Sprintf(", value %v raw", raw)
+	}
+	return fmt.Sprintf(", value %v raw (%v)", raw, value)
+}
+
+// label — подпись строки для человекочитаемых форматов с учётом -short-addr.
+func (opts outputOptions) label(m Metric) string {
+	if opts.ShortAddr && m.Group == "" {
+		hex := m.Address.Hex()
+		return hex[:6] + "…" + hex[len(hex)-4:]
+	}
+	return m.Label()
+}
+
+// barsFormatter рисует полосу из # длиной, пропорциональной count относительно максимума в выводе.
+type barsFormatter struct {
+	opts outputOptions
+}
+
+func (f barsFormatter) Write(w io.Writer, metrics []Metric, _ ScanStats) error {
+	maxCount, labelWidth := 0, 0
+	for _, m := range metrics {
+		if m.Count > maxCount {
+			maxCount = m.Count
+		}
+		if n := utf8.RuneCountInString(f.opts.label(m)); n > labelWidth {
+			labelWidth = n
+		}
+	}
+
+	for _, m := range metrics {
+		width := 0
+		if maxCount > 0 {
+			width = m.Count * f.opts.BarWidth / maxCount
+		}
+		if width == 0 && m.Count > 0 {
+			width = 1
+		}
+
+		label := f.opts.label(m)
+		padding := strings.Repeat(" ", labelWidth-utf8.RuneCountInString(label))
+		if _, err := fmt.Fprintf(w, "%s%s %s %d\n", label, padding, strings.Repeat("#", width), m.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+// formatterMetrics — три строки рейтинга с суммами для проверки форматов вывода.
+func formatterMetrics() []Metric {
+	return []Metric{
+		{Address: testAddress(1), Name: "alice.eth", Count: 8, RawValue: big.NewInt(150000), Value: "1.5"},
+		{Address: testAddress(2), Count: 4, RawValue: big.NewInt(25), Value: "0.00025"},
+		{Address: testAddress(3), Count: 1, RawValue: big.NewInt(0), Value: "0"},
+	}
+}
+
+func TestFormatters(t *testing.T) {
+	tests := []struct {
+		name string
+		opts outputOptions
+		want string
+	}{
+		{
+			name: "text",
+			opts: outputOptions{Format: formatText, Decimals: true},
+			want: `address 0x0000000000000000000000000000000000000001 (alice.eth) used ERC20 8 times, value 150000 raw (1.5)
+address 0x0000000000000000000000000000000000000002 used ERC20 4 times, value 25 raw (0.00025)
+address 0x0000000000000000000000000000000000000003 used ERC20 1 times, value 0 raw (0)
+`,
+		},
+		{
+			name: "markdown",
+			opts: outputOptions{Format: formatMarkdown, Decimals: true},
+			want: `| Address | Count | Raw value | Value |
+| --- | --- | --- | --- |
+| 0x0000000000000000000000000000000000000001 | 8 | 150000 | 1.5 |
+| 0x0000000000000000000000000000000000000002 | 4 | 25 | 0.00025 |
+| 0x0000000000000000000000000000000000000003 | 1 | 0 | 0 |
+`,
+		},
+		{
+			name: "markdown -short-addr",
+			opts: outputOptions{Format: formatMarkdown, ShortAddr: true},
+			want: `| Address | Count |
+| --- | --- |
+| 0x0000…0001 | 8 |
+| 0x0000…0002 | 4 |
+| 0x0000…0003 | 1 |
+`,
+		},
+		{
+			name: "bars",
+			opts: outputOptions{Format: formatBars, BarWidth: 10},
+			want: `0x0000000000000000000000000000000000000001 ########## 8
+0x0000000000000000000000000000000000000002 ##### 4
+0x0000000000000000000000000000000000000003 # 1
+`,
+		},
+		{
+			name: "json",
+			opts: outputOptions{Format: formatJSON},
+			want: `[{"address":"0x0000000000000000000000000000000000000001","name":"alice.eth","count":8,"raw_value":150000,"value":"1.5"}
+,{"address":"0x0000000000000000000000000000000000000002","count":4,"raw_value":25,"value":"0.00025"}
+,{"address":"0x0000000000000000000000000000000000000003","count":1,"raw_value":0,"value":"0"}
+]
+`,
+		},
+		{
+			name: "csv",
+			opts: outputOptions{Format: formatCSV, Decimals: true},
+			want: `address,count,raw_value,value
+0x0000000000000000000000000000000000000001,8,150000,1.5
+0x0000000000000000000000000000000000000002,4,25,0.00025
+0x0000000000000000000000000000000000000003,1,0,0
+`,
+		},
+		{
+			name: "addresses only",
+			opts: outputOptions{AddressesOnly: true},
+			want: `0x0000000000000000000000000000000000000001
+0x0000000000000000000000000000000000000002
+0x0000000000000000000000000000000000000003
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatter, err := newFormatter(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := formatter.Write(&buf, formatterMetrics(), ScanStats{}); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	stats := ScanStats{FromBlock: 10, ToBlock: 20}
+	stats.Logs, stats.Transfers = 13, 12
+	if err := (reportFormatter{chain: chainInfo{Name: "ethereum", ID: 1}}).Write(&buf, formatterMetrics(), stats); err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, buf.String())
+	}
+	if report.Chain != "ethereum" || report.ChainID != 1 || report.FromBlock != 10 || report.ToBlock != 20 || report.Logs != 13 || report.Transfers != 12 {
+		t.Errorf("report header = %+v", report)
+	}
+	if report.GeneratedAt.IsZero() {
+		t.Error("report has no generated_at")
+	}
+	if len(report.Metrics) != 3 || report.Metrics[0].Name != "alice.eth" || report.Metrics[2].Count != 1 {
+		t.Errorf("report metrics = %+v", report.Metrics)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := newFormatter(outputOptions{Format: "yaml"}); err == nil {
+		t.Error("newFormatter accepted an unknown format")
+	}
+}
+
+func TestTopShareCut(t *testing.T) {
+	counts := func(values ...int) []Metric {
+		metrics := make([]Metric, len(values))
+		for i, v := range values {
+			metrics[i] = Metric{Address: testAddress(byte(i + 1)), Count: v}
+		}
+		return metrics
+	}
+	tests := []struct {
+		name              string
+		metrics           []Metric
+		share             float64
+		n, covered, total int
+	}{
+		{"pareto", counts(50, 30, 10, 5, 5), 0.8, 2, 80, 100},
+		{"just above the share", counts(50, 30, 10, 5, 5), 0.81, 3, 90, 100},
+		{"everything", counts(50, 30, 10, 5, 5), 1, 5, 100, 100},
+		{"uniform", counts(1, 1, 1, 1), 0.5, 2, 2, 4},
+		{"single address", counts(7), 0.1, 1, 7, 7},
+		{"empty", nil, 0.8, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, covered, total := topShareCut(tt.metrics, tt.share)
+			if n != tt.n || covered != tt.covered || total != tt.total {
+				t.Errorf("topShareCut = %d, %d, %d; want %d, %d, %d", n, covered, total, tt.n, tt.covered, tt.total)
+			}
+		})
+	}
+}
+
+func TestWriteTopShareSummary(t *testing.T) {
+	metrics := []Metric{
+		{Address: testAddress(1), Count: 6},
+		{Address: testAddress(2), Count: 3},
+		{Address: testAddress(3), Count: 1},
+	}
+	got := renderTestMetrics(t, metrics, outputOptions{Format: formatBars, Sort: sortCount, BarWidth: 4, TopShare: 0.6})
+	want := `0x0000000000000000000000000000000000000001 #### 6
+1 of 3 addresses account for 60.0% of 10 counted transfers
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"getBlock/metric"
+)
+
+type pairKey struct {
+	Address common.Address
+	Token   common.Address
+}
+
+// PairMetric — активность адреса по одному конкретному токену.
+type PairMetric struct {
+	Address  common.Address
+	Token    common.Address
+	Count    int
+	RawValue *big.Int
+	Value    string
+}
+
+func (c *transferCounter) countPair(address, token common.Address, raw *big.Int, scaled *big.Rat) {
+	key := pairKey{Address: address, Token: token}
+	c.pairCounts[key]++
+	if !c.opts.Decimals {
+		return
+	}
+
+	if c.pairRawValues[key] == nil {
+		c.pairRawValues[key] = new(big.Int)
+	}
+	c.pairRawValues[key].Add(c.pairRawValues[key], raw)
+	// Без decimals токена пара остаётся без суммы в токенах.
+	if scaled != nil {
+		if c.pairValues[key] == nil {
+			c.pairValues[key] = new(big.Rat)
+		}
+		c.pairValues[key].Add(c.pairValues[key], scaled)
+	}
+}
+
+func (c *transferCounter) PairMetrics() ([]PairMetric, error) {
+	pairs := make([]PairMetric, 0, len(c.pairCounts))
+	for key, count := range c.pairCounts {
+		if key.Address == (common.Address{}) && !c.opts.CountZero {
+			continue
+		}
+
+		pair := PairMetric{Address: key.Address, Token: key.Token, Count: count}
+		if c.opts.Decimals {
+			pair.RawValue = c.pairRawValues[key]
+			if c.pairValues[key] != nil || pair.RawValue == nil {
+				pair.Value = formatDecimal(c.pairValues[key])
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+
+	if len(pairs) == 0 {
+		return nil, metric.ErrNoLogs
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Count != pairs[j].Count {
+			return pairs[i].Count > pairs[j].Count
+		}
+		if pairs[i].Address != pairs[j].Address {
+			return bytes.Compare(pairs[i].Address[:], pairs[j].Address[:]) < 0
+		}
+		return bytes.Compare(pairs[i].Token[:], pairs[j].Token[:]) < 0
+	})
+
+	return pairs, nil
+}
+
+func writePairMetrics(w io.Writer, pairs []PairMetric, opts outputOptions) error {
+	if len(pairs) > topN {
+		pairs = pairs[:topN]
+	}
+
+	if opts.AddressesOnly {
+		for _, p := range pairs {
+			if _, err := fmt.Fprintln(w, p.Address.Hex(), p.Token.Hex()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if opts.Format == formatMarkdown {
+		header := []string{"Address", "Token", "Count"}
+		separator := []string{"---", "---", "---"}
+		if opts.Decimals {
+			header = append(header, "Raw value", "Value")
+			separator = append(separator, "---", "---")
+		}
+		if err := writeMarkdownRow(w, header); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, separator); err != nil {
+			return err
+		}
+		for _, p := range pairs {
+			row := []string{p.Address.Hex(), opts.Tokens.Label(p.Token), fmt.Sprint(p.Count)}
+			if opts.Decimals {
+				row = append(row, fmt.Sprint(p.RawValue), p.Value)
+			}
+			if err := writeMarkdownRow(w, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, p := range pairs {
+		var err error
+		if opts.Decimals {
+			_, err = fmt.Fprintf(w, "address %v moved token %v %v times%v\n", p.Address, opts.Tokens.Label(p.Token), p.Count, rawValueText(p.RawValue, p.Value))
+		} else {
+			_, err = fmt.Fprintf(w, "address %v moved token %v %v times\n", p.Address, opts.Tokens.Label(p.Token), p.Count)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
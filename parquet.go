@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+// Типы и константы формата Parquet (parquet.thrift), которые использует -parquet-dir.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8      = 0
+	parquetConvertedTimestamp = 9 // TIMESTAMP_MILLIS
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetDataPage      = 0
+
+	parquetMagic = "PAR1"
+	// defaultParquetRows — строк в одном файле -parquet-dir, если -parquet-rows не задан.
+	defaultParquetRows = 100000
+)
+
+// transferRow — строка выгрузки -parquet-dir: один декодированный Transfer.
+type transferRow struct {
+	Block     uint64
+	Timestamp time.Time
+	TxHash    string
+	LogIndex  uint
+	Token     string
+	From      string
+	To        string
+	Value     string
+}
+
+// parquetColumn — колонка схемы: тип Parquet, converted type (-1 — нет) и значения строк в PLAIN.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	encode    func(buf *bytes.Buffer, row transferRow)
+}
+
+func plainInt64(buf *bytes.Buffer, v int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(v))
+	buf.Write(b[:])
+}
+
+func plainString(buf *bytes.Buffer, s string) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(s)))
+	buf.Write(b[:])
+	buf.WriteString(s)
+}
+
+var transferColumns = []parquetColumn{
+	{"block", parquetInt64, -1, func(buf *bytes.Buffer, r transferRow) { plainInt64(buf, int64(r.Block)) }},
+	{"timestamp", parquetInt64, parquetConvertedTimestamp, func(buf *bytes.Buffer, r transferRow) { plainInt64(buf, r.Timestamp.UnixMilli()) }},
+	{"tx_hash", parquetByteArray, parquetConvertedUTF8, func(buf *bytes.Buffer, r transferRow) { plainString(buf, r.TxHash) }},
+	{"log_index", parquetInt64, -1, func(buf *bytes.Buffer, r transferRow) { plainInt64(buf, int64(r.LogIndex)) }},
+	{"token", parquetByteArray, parquetConvertedUTF8, func(buf *bytes.Buffer, r transferRow) { plainString(buf, r.Token) }},
+	{"from", parquetByteArray, parquetConvertedUTF8, func(buf *bytes.Buffer, r transferRow) { plainString(buf, r.From) }},
+	{"to", parquetByteArray, parquetConvertedUTF8, func(buf *bytes.Buffer, r transferRow) { plainString(buf, r.To) }},
+	// uint256 не помещается в числовые типы Parquet: значение — десятичная строка, её приводят к DECIMAL или HUGEINT при загрузке.
+	{"value", parquetByteArray, parquetConvertedUTF8, func(buf *bytes.Buffer, r transferRow) { plainString(buf, r.Value) }},
+}
+
+// writeParquet пишет строки одним файлом Parquet: одна группа строк, по одной странице данных
+// на колонку, кодирование PLAIN без сжатия. Такой файл читают DuckDB, Spark и pyarrow.
+func writeParquet(w io.Writer, rows []transferRow) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(transferColumns))
+	for i, column := range transferColumns {
+		var data bytes.Buffer
+		for _, row := range rows {
+			column.encode(&data, row)
+		}
+
+		header := newThriftWriter()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(data.Len()))
+		header.i32(3, int32(data.Len()))
+		header.structBegin(5)
+		header.i32(1, int32(len(rows)))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.structEnd()
+		header.stop()
+
+		chunks[i].offset = int64(file.Len())
+		file.Write(header.bytes())
+		file.Write(data.Bytes())
+		chunks[i].size = int64(file.Len()) - chunks[i].offset
+	}
+
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+	meta.listBegin(2, thriftStruct, len(transferColumns)+1)
+	meta.elemBegin()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(transferColumns)))
+	meta.elemEnd()
+	for _, column := range transferColumns {
+		meta.elemBegin()
+		meta.i32(1, column.typ)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, column.name)
+		if column.converted >= 0 {
+			meta.i32(6, column.converted)
+		}
+		meta.elemEnd()
+	}
+	meta.i64(3, int64(len(rows)))
+
+	var total int64
+	for _, c := range chunks {
+		total += c.size
+	}
+	meta.listBegin(4, thriftStruct, 1)
+	meta.elemBegin()
+	meta.listBegin(1, thriftStruct, len(transferColumns))
+	for i, column := range transferColumns {
+		meta.elemBegin()
+		meta.i64(2, chunks[i].offset)
+		meta.structBegin(3)
+		meta.i32(1, column.typ)
+		meta.listBegin(2, thriftI32, 2)
+		meta.varint(zigzag(parquetEncodingPlain))
+		meta.varint(zigzag(parquetEncodingRLE))
+		meta.listBegin(3, thriftBinary, 1)
+		meta.rawBinary(column.name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(len(rows)))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.structEnd()
+		meta.elemEnd()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(len(rows)))
+	meta.elemEnd()
+	meta.binary(6, "getBlock metric")
+	meta.stop()
+
+	footer := meta.bytes()
+	file.Write(footer)
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	file.Write(length[:])
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Типы полей компактного протокола Thrift.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter — запись компактного протокола Thrift в объёме, нужном метаданным Parquet.
+// last — номер предыдущего поля каждой открытой структуры: заголовок поля хранит разницу номеров.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	top := len(t.last) - 1
+	if delta := id - t.last[top]; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.last[top] = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawBinary(s)
+}
+
+func (t *thriftWriter) rawBinary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elemType)
+	t.varint(uint64(size))
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+// elemBegin и elemEnd открывают и закрывают структуру — элемент списка, у которой нет заголовка поля.
+func (t *thriftWriter) elemBegin() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) structEnd() {
+	t.elemEnd()
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
+
+func (t *thriftWriter) bytes() []byte {
+	return t.buf.Bytes()
+}
+
+// parquetSink копит переводы и выгружает их в dir файлами transfers-NNNNNN.parquet по rows строк;
+// остаток пишется при Close. Файл появляется атомарно, поэтому читатель не увидит недописанный.
+type parquetSink struct {
+	dir  string
+	rows int
+
+	mu       sync.Mutex
+	buffered []transferRow
+	next     int
+}
+
+func openParquetSink(dir string, rows int) (*parquetSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create -parquet-dir: %w", err)
+	}
+	if rows <= 0 {
+		rows = defaultParquetRows
+	}
+
+	// Нумерация продолжается после файлов прошлых прогонов, чтобы не перезаписать их.
+	existing, err := filepath.Glob(filepath.Join(dir, "transfers-*.parquet"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(existing)
+	sink := &parquetSink{dir: dir, rows: rows, next: 1}
+	if len(existing) > 0 {
+		last := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(existing[len(existing)-1]), "transfers-"), ".parquet")
+		if n, err := fmt.Sscanf(last, "%d", &sink.next); n == 1 && err == nil {
+			sink.next++
+		}
+	}
+	return sink, nil
+}
+
+func (s *parquetSink) Write(vLog types.Log, transferEvent metric.TransferEvents, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buffered = append(s.buffered, transferRow{
+		Block:     vLog.BlockNumber,
+		Timestamp: at,
+		TxHash:    vLog.TxHash.Hex(),
+		LogIndex:  vLog.Index,
+		Token:     vLog.Address.Hex(),
+		From:      transferEvent.From.Hex(),
+		To:        transferEvent.To.Hex(),
+		Value:     transferEvent.Value.String(),
+	})
+	if len(s.buffered) >= s.rows {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *parquetSink) flush() error {
+	if len(s.buffered) == 0 {
+		return nil
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("transfers-%06d.parquet", s.next))
+	tmp, err := os.CreateTemp(s.dir, ".transfers-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write -parquet-dir file: %w", err)
+	}
+	// CreateTemp создаёт файл с правами 0600; выгрузку читают другие процессы.
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := writeParquet(tmp, s.buffered); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	s.buffered = s.buffered[:0]
+	s.next++
+	return nil
+}
+
+func (s *parquetSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"getBlock/metric"
+)
+
+// pendingMetrics считает Transfer логи, которые нода предсказывает для ожидающих транзакций
+// (eth_getLogs с fromBlock/toBlock = "pending"). Они считаются отдельным счётчиком, чтобы
+// не смешиваться с рейтингом по смайненным блокам. Часть провайдеров pending не поддерживает
+// или отдаёт вместо него последний блок — такие логи (не новее minedTo) отбрасываются.
+func pendingMetrics(ctx context.Context, client *ethclient.Client, opts scanOptions, minedTo uint64) ([]Metric, error) {
+	pending := big.NewInt(int64(rpc.PendingBlockNumber))
+	requestCtx, cancel := withRequestTimeout(ctx, opts.RequestTimeout)
+	defer cancel()
+	logs, err := client.FilterLogs(requestCtx, ethereum.FilterQuery{
+		FromBlock: pending,
+		ToBlock:   pending,
+		Addresses: opts.Contracts,
+		Topics:    opts.transferTopics(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("provider does not serve pending logs: %w", err)
+	}
+
+	fresh := make([]types.Log, 0, len(logs))
+	for _, vLog := range logs {
+		if vLog.BlockNumber > minedTo {
+			fresh = append(fresh, vLog)
+		}
+	}
+
+	opts.Feed = nil
+	opts.Window = 0
+	return countLogs(ctx, fresh, newTransferCounter(client, opts))
+}
+
+func reportPending(ctx context.Context, client *ethclient.Client, w io.Writer, counter *transferCounter, opts outputOptions) {
+	metrics, err := pendingMetrics(ctx, client, counter.opts, counter.toBlock)
+	if errors.Is(err, metric.ErrNoLogs) {
+		fmt.Fprintln(w, "pending (tentative): no transfers predicted from pending transactions")
+		return
+	}
+	if err != nil {
+		logger.Warn("-include-pending skipped", "err", err)
+		return
+	}
+
+	fmt.Fprintln(w, "pending (tentative, may change or never be mined):")
+	if err := writeMetrics(w, metrics, ScanStats{}, opts); err != nil {
+		logger.Warn("failed to write pending ranking", "err", err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricsExporter отдаёт последний рейтинг на /metrics в текстовом формате Prometheus.
+// Снимок обновляется из report в той же горутине, что и подсчёт, поэтому обработчик
+// HTTP не трогает счётчик напрямую.
+type metricsExporter struct {
+	mu        sync.Mutex
+	top       []Metric
+	addresses int
+	stats     ScanStats
+	updated   time.Time
+}
+
+func (e *metricsExporter) Update(metrics []Metric, stats ScanStats) {
+	top := metrics
+	if len(top) > topN {
+		top = top[:topN]
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.top = append([]Metric(nil), top...)
+	e.addresses = len(metrics)
+	e.stats = stats
+	e.updated = time.Now()
+}
+
+func (e *metricsExporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := e.write(w); err != nil {
+		logger.Error("failed to write /metrics", "err", err)
+	}
+}
+
+func (e *metricsExporter) write(w io.Writer) error {
+	series := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"erc20_logs_processed_total", "counter", "Logs processed since start.", float64(e.stats.Logs)},
+		{"erc20_transfers_processed_total", "counter", "ERC20 Transfer events counted since start.", float64(e.stats.Transfers)},
+		{"erc20_rpc_errors_total", "counter", "Failed RPC lookups besides FilterLogs (headers, decimals, receipts, senders, code).", float64(e.stats.RPCErrors)},
+		{"erc20_active_addresses", "gauge", "Addresses with at least one counted transfer.", float64(e.addresses)},
+		{"erc20_last_processed_block", "gauge", "Last block of the counted range.", float64(e.stats.ToBlock)},
+	}
+	if !e.updated.IsZero() {
+		series = append(series, struct {
+			name, kind, help string
+			value            float64
+		}{"erc20_last_update_timestamp_seconds", "gauge", "Unix time of the last ranking update.", float64(e.updated.Unix())})
+	}
+
+	for _, s := range series {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", s.name, s.help, s.name, s.kind, s.name, strconv.FormatFloat(s.value, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprint(w, "# HELP erc20_top_address_transfers Transfers of the top addresses by rank.\n# TYPE erc20_top_address_transfers gauge\n"); err != nil {
+		return err
+	}
+	for i, m := range e.top {
+		if _, err := fmt.Fprintf(w, "erc20_top_address_transfers{rank=\"%d\",address=\"%s\"} %d\n", i+1, m.Label(), m.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveMetrics слушает addr в фоне; ошибка bind возвращается сразу, а не из горутины.
+func serveMetrics(ctx context.Context, addr string, exporter *metricsExporter) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on -serve-metrics %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownServer(server)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server stopped", "err", err)
+		}
+	}()
+	logger.Info("serving Prometheus metrics", "url", "http://"+listener.Addr().String()+"/metrics")
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Слот реализации EIP-1967: bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1).
+var eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+type proxyResolver struct {
+	client *ethclient.Client
+
+	mu              sync.Mutex
+	implementations map[common.Address]common.Address
+}
+
+func newProxyResolver(client *ethclient.Client) *proxyResolver {
+	return &proxyResolver{
+		client:          client,
+		implementations: make(map[common.Address]common.Address),
+	}
+}
+
+// Implementation возвращает адрес реализации, если контракт — прокси EIP-1967.
+func (r *proxyResolver) Implementation(ctx context.Context, contract common.Address) (common.Address, bool, error) {
+	r.mu.Lock()
+	implementation, ok := r.implementations[contract]
+	r.mu.Unlock()
+	if ok {
+		return implementation, implementation != (common.Address{}), nil
+	}
+
+	value, err := r.client.StorageAt(ctx, contract, eip1967ImplementationSlot, nil)
+	if err != nil {
+		return common.Address{}, false, fmt.Errorf("failed to read EIP-1967 slot of %s: %w", contract.Hex(), err)
+	}
+
+	implementation = common.BytesToAddress(value)
+	r.mu.Lock()
+	r.implementations[contract] = implementation
+	r.mu.Unlock()
+	return implementation, implementation != (common.Address{}), nil
+}
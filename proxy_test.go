@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestProxyResolverImplementation(t *testing.T) {
+	proxy := common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	plain := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	broken := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	implementation := common.HexToAddress("0x43506849d7c04f9138d1a2050bbf3a0c054402dd")
+
+	calls := 0
+	client := dialTestRPC(t, func(method string, params []json.RawMessage) (any, error) {
+		if method != "eth_getStorageAt" {
+			return nil, errors.New("unexpected method " + method)
+		}
+		calls++
+		var contract common.Address
+		var slot common.Hash
+		json.Unmarshal(params[0], &contract)
+		json.Unmarshal(params[1], &slot)
+		if slot != eip1967ImplementationSlot {
+			return nil, errors.New("unexpected slot " + slot.Hex())
+		}
+		switch contract {
+		case proxy:
+			return common.BytesToHash(implementation.Bytes()).Hex(), nil
+		case broken:
+			return nil, errors.New("storage unavailable")
+		}
+		return common.Hash{}.Hex(), nil
+	})
+	resolver := newProxyResolver(client)
+
+	tests := []struct {
+		name    string
+		token   common.Address
+		want    common.Address
+		isProxy bool
+		wantErr bool
+	}{
+		{"proxy", proxy, implementation, true, false},
+		{"empty slot", plain, common.Address{}, false, false},
+		{"rpc error", broken, common.Address{}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, isProxy, err := resolver.Implementation(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want || isProxy != tt.isProxy {
+				t.Errorf("Implementation = %s, %v; want %s, %v", got.Hex(), isProxy, tt.want.Hex(), tt.isProxy)
+			}
+		})
+	}
+
+	before := calls
+	if _, _, err := resolver.Implementation(context.Background(), proxy); err != nil {
+		t.Fatal(err)
+	}
+	if calls != before {
+		t.Errorf("a resolved proxy was read again")
+	}
+}
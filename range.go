@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const defaultLookback = 100
+
+// resolveRange возвращает окно из lookback последних блоков, заканчивающееся latest.
+// Если цепочка короче окна (свежий devnet/testnet), начало обрезается до генезиса.
+func resolveRange(latest *big.Int, lookback uint64) (from, to *big.Int, truncated bool) {
+	to = new(big.Int).Set(latest)
+	from = new(big.Int).Sub(latest, new(big.Int).SetUint64(lookback-1))
+	if from.Sign() < 0 {
+		return new(big.Int), to, true
+	}
+	return from, to, false
+}
+
+const blockLatest = "latest"
+
+// parseBlockFlag разбирает -from-block / -to-block: десятичный номер блока;
+// пустая строка и latest возвращают nil.
+func parseBlockFlag(raw string) (*big.Int, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.EqualFold(raw, blockLatest) {
+		return nil, nil
+	}
+	number, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed block number %q: expected a decimal number or latest", raw)
+	}
+	return new(big.Int).SetUint64(number), nil
+}
+
+// blockLabel — подпись блока в ошибках: номер или latest.
+func blockLabel(number *big.Int) string {
+	if number == nil {
+		return blockLatest
+	}
+	return number.String()
+}
+
+// lookbackBlocks переводит -lookback-duration в число блоков. Точный способ — бинарный поиск
+// первого блока с timestamp >= head.Time - duration (около log2(head) запросов заголовков);
+// если он не удался, число блоков оценивается как duration / -block-time.
+func (c *transferCounter) lookbackBlocks(ctx context.Context, head *types.Header) uint64 {
+	duration := c.opts.LookbackDuration
+	if duration <= 0 {
+		return c.opts.Lookback
+	}
+
+	seconds := uint64(duration / time.Second)
+	var target uint64
+	if head.Time > seconds {
+		target = head.Time - seconds
+	}
+
+	from, err := c.firstBlockAtOrAfter(ctx, head, target)
+	if err == nil {
+		return head.Number.Uint64() - from + 1
+	}
+
+	estimate := uint64(math.Ceil(float64(duration) / float64(c.opts.BlockTime)))
+	if estimate == 0 {
+		estimate = 1
+	}
+	logger.Warn("timestamp search for -lookback-duration failed, estimating blocks from -block-time", "err", err, "blocks", estimate, "block_time", c.opts.BlockTime.String())
+	return estimate
+}
+
+// firstBlockAtOrAfter ищет наименьший номер блока в [0, head] с timestamp >= target.
+func (c *transferCounter) firstBlockAtOrAfter(ctx context.Context, head *types.Header, target uint64) (uint64, error) {
+	lo, hi := uint64(0), head.Number.Uint64()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		header, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, err
+		}
+		if header.Time >= target {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, nil
+}
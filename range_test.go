@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestResolveRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		latest    int64
+		lookback  uint64
+		from      int64
+		truncated bool
+	}{
+		{"full window", 1000, 100, 901, false},
+		{"window ends at genesis", 99, 100, 0, false},
+		{"chain shorter than window", 10, 100, 0, true},
+		{"genesis only", 0, 5, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, truncated := resolveRange(big.NewInt(tt.latest), tt.lookback)
+			if from.Int64() != tt.from || to.Int64() != tt.latest || truncated != tt.truncated {
+				t.Errorf("resolveRange(%d, %d) = %v, %v, %v; want %d, %d, %v", tt.latest, tt.lookback, from, to, truncated, tt.from, tt.latest, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestCurrentBlockShortChain(t *testing.T) {
+	logs := []types.Log{
+		transferLog(testAddress(1), testAddress(2), 1, 0, 0),
+		transferLog(testAddress(1), testAddress(3), 1, 2, 0),
+		transferLog(testAddress(2), testAddress(3), 1, 3, 0),
+	}
+	chain := newFixtureChain(logs, 3)
+	opts := testScanOptions()
+	opts.Lookback = 100
+	counter := newTransferCounter(nil, opts)
+	counter.headers = newHeaderCache(chain, counter.stats, 0)
+
+	metrics, err := currentBlock(context.Background(), chain, counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := counter.Stats()
+	if stats.FromBlock != 0 || stats.ToBlock != 3 {
+		t.Errorf("scanned %d-%d, want the whole chain 0-3", stats.FromBlock, stats.ToBlock)
+	}
+	if stats.Transfers != len(logs) || len(metrics) != 3 {
+		t.Errorf("counted %d transfers of %d addresses, want %d of 3", stats.Transfers, len(metrics), len(logs))
+	}
+}
+
+// nilNumberChain отдаёт заголовок без номера, как некоторые провайдеры на pending-блоках.
+type nilNumberChain struct{ *fixtureChain }
+
+func (nilNumberChain) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return &types.Header{}, nil
+}
+
+func TestCurrentBlockNilHeaderNumber(t *testing.T) {
+	chain := nilNumberChain{newFixtureChain(fixtureLogs(), 0)}
+	counter := newTransferCounter(nil, testScanOptions())
+	counter.headers = newHeaderCache(chain, counter.stats, 0)
+
+	_, err := currentBlock(context.Background(), chain, counter)
+	if err == nil || !strings.Contains(err.Error(), "has no number") {
+		t.Fatalf("err = %v, want a header without number error", err)
+	}
+}
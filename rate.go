@@ -0,0 +1,39 @@
+package main
+
+import "github.com/ethereum/go-ethereum/common"
+
+const sortRate = "rate"
+
+// blockSpan — первый и последний блок, в которых адрес встречался.
+type blockSpan struct {
+	First, Last uint64
+}
+
+func (c *transferCounter) trackSpan(address common.Address, block uint64) {
+	span, ok := c.spans[address]
+	if !ok {
+		c.spans[address] = blockSpan{First: block, Last: block}
+		return
+	}
+	if block < span.First {
+		span.First = block
+	}
+	if block > span.Last {
+		span.Last = block
+	}
+	c.spans[address] = span
+}
+
+// activityRate — событий на блок за активный промежуток адреса; адрес из одного блока
+// имеет промежуток 1, так что его rate равен count.
+func activityRate(count int, span blockSpan) float64 {
+	return float64(count) / float64(span.Last-span.First+1)
+}
+
+func (c *transferCounter) fillRates(metrics []Metric) {
+	for i := range metrics {
+		if span, ok := c.spans[metrics[i].Address]; ok {
+			metrics[i].Rate = activityRate(metrics[i].Count, span)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// receiptCache запоминает статус транзакций, чтобы запрашивать квитанцию один раз на хэш.
+type receiptCache struct {
+	client *ethclient.Client
+	stats  *Stats
+
+	mu       sync.Mutex
+	statuses map[common.Hash]uint64
+}
+
+func newReceiptCache(client *ethclient.Client, stats *Stats) *receiptCache {
+	return &receiptCache{
+		client:   client,
+		stats:    stats,
+		statuses: make(map[common.Hash]uint64),
+	}
+}
+
+func (c *receiptCache) Successful(ctx context.Context, txHash common.Hash) (bool, error) {
+	c.mu.Lock()
+	status, ok := c.statuses[txHash]
+	c.mu.Unlock()
+	if ok {
+		c.stats.IncCacheHit()
+	} else {
+		receipt, err := c.client.TransactionReceipt(ctx, txHash)
+		if err != nil {
+			c.stats.IncRPCError()
+			return false, fmt.Errorf("failed to fetch receipt of %s: %w", txHash.Hex(), err)
+		}
+		status = receipt.Status
+
+		c.mu.Lock()
+		c.statuses[txHash] = status
+		c.mu.Unlock()
+	}
+	return status == types.ReceiptStatusSuccessful, nil
+}
+
+// Prefetch запрашивает статусы транзакций hashes батчами по prefetchBatchSize eth_getTransactionReceipt.
+// Из квитанции разбирается только status: логи квитанций -successful-only не нужны.
+func (c *receiptCache) Prefetch(ctx context.Context, pool *enrichPool, hashes []common.Hash) error {
+	var missing []common.Hash
+	c.mu.Lock()
+	for _, hash := range hashes {
+		if _, ok := c.statuses[hash]; ok {
+			c.stats.IncCacheHit()
+		} else {
+			missing = append(missing, hash)
+		}
+	}
+	c.mu.Unlock()
+
+	type receiptStatus struct {
+		Status hexutil.Uint64 `json:"status"`
+	}
+	batches := (len(missing) + prefetchBatchSize - 1) / prefetchBatchSize
+	return pool.Run(ctx, batches, func(ctx context.Context, i int) error {
+		start := i * prefetchBatchSize
+		end := start + prefetchBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+
+		receipts := make([]*receiptStatus, end-start)
+		elems := make([]rpc.BatchElem, end-start)
+		for j, hash := range missing[start:end] {
+			elems[j] = rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &receipts[j]}
+		}
+		if err := c.client.Client().BatchCallContext(ctx, elems); err != nil {
+			c.stats.IncRPCError()
+			return fmt.Errorf("failed to send receipts batch: %w", err)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for j, elem := range elems {
+			hash := missing[start+j]
+			if elem.Error != nil {
+				c.stats.IncRPCError()
+				return fmt.Errorf("failed to fetch receipt of %s: %w", hash.Hex(), elem.Error)
+			}
+			if receipts[j] == nil {
+				return fmt.Errorf("failed to fetch receipt of %s: %w", hash.Hex(), ethereum.NotFound)
+			}
+			c.statuses[hash] = uint64(receipts[j].Status)
+		}
+		return nil
+	})
+}
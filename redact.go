@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"strings"
+)
+
+// redactURL скрывает секреты в URL: ключ getblock передаётся в пути, поэтому путь,
+// пароль и query заменяются на ***, а схема и хост остаются для диагностики.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "***"
+	}
+
+	redacted := u.Scheme + "://"
+	if u.User != nil {
+		redacted += "***@"
+	}
+	redacted += u.Host
+	if strings.Trim(u.Path, "/") != "" {
+		redacted += "/***"
+	}
+	if u.RawQuery != "" {
+		redacted += "?***"
+	}
+	return redacted
+}
+
+func redactURLs(s string, urls []string) string {
+	for _, raw := range urls {
+		if raw != "" {
+			s = strings.ReplaceAll(s, raw, redactURL(raw))
+		}
+	}
+	return s
+}
+
+// redactedError подменяет в тексте ошибки исходные URL на их redactURL.
+type redactedError struct {
+	err  error
+	urls []string
+}
+
+func (e redactedError) Error() string {
+	return redactURLs(e.err.Error(), e.urls)
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+func redactErr(err error, urls ...string) error {
+	if err == nil {
+		return nil
+	}
+	return redactedError{err: err, urls: urls}
+}
+
+// redactingWriter применяет ту же замену ко всему, что пишется в стандартный log.
+type redactingWriter struct {
+	w    io.Writer
+	urls []string
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, redactURLs(string(p), r.urls)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
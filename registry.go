@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type tokenInfo struct {
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+}
+
+// loadTokenRegistry читает JSON вида {"0xcontract": {"symbol": "USDT", "decimals": 6}}.
+func loadTokenRegistry(path string) (map[common.Address]tokenInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token registry: %w", err)
+	}
+
+	var raw map[string]tokenInfo
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse token registry %s: %w", path, err)
+	}
+
+	registry := make(map[common.Address]tokenInfo, len(raw))
+	seen := make(map[string]string, len(raw))
+	for key, info := range raw {
+		normalized := strings.ToLower(strings.TrimSpace(key))
+		if !common.IsHexAddress(normalized) {
+			return nil, fmt.Errorf("invalid token address %q in registry", key)
+		}
+		if previous, ok := seen[normalized]; ok {
+			return nil, fmt.Errorf("token %s is listed twice in registry (%q and %q)", normalized, previous, key)
+		}
+		if info.Decimals > 77 {
+			return nil, fmt.Errorf("invalid decimals %d for token %s in registry", info.Decimals, key)
+		}
+		seen[normalized] = key
+		registry[common.HexToAddress(normalized)] = info
+	}
+
+	return registry, nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+// reorgDepth — сколько последних блоков -store хранит хеши для проверки реорганизации.
+const reorgDepth = 128
+
+// checkpoint сохраняет счётчики в -store вместе с хешами блоков: блоков с переводами
+// (из логов) и последнего обработанного (из заголовка).
+func (c *transferCounter) checkpoint(ctx context.Context, lastBlock uint64) error {
+	header, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(lastBlock))
+	if err != nil {
+		return fmt.Errorf("failed to retrieve header of block %d: %w", lastBlock, err)
+	}
+	c.blockHashes[lastBlock] = header.Hash()
+
+	if err := c.opts.Store.Merge(c.blockCounts, c.blockHashes, lastBlock); err != nil {
+		return err
+	}
+	c.blockCounts = make(map[uint64]map[common.Address]*storedCount)
+	c.blockHashes = make(map[uint64]common.Hash)
+	return nil
+}
+
+// rewindStore сверяет сохранённые хеши последних блоков с цепочкой. Если последний блок
+// заменён реорганизацией, счётчики всех блоков после последнего совпавшего удаляются,
+// и следующий прогон пересчитывает их заново.
+func (c *transferCounter) rewindStore(ctx context.Context) error {
+	store := c.opts.Store
+	if store.LastBlock == nil || len(store.Hashes) == 0 {
+		return nil
+	}
+
+	blocks := make([]uint64, 0, len(store.Hashes))
+	for block := range store.Hashes {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] > blocks[j] })
+
+	for i, block := range blocks {
+		header, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+		if err != nil {
+			return fmt.Errorf("failed to retrieve header of block %d: %w", block, err)
+		}
+		if header.Hash() != store.Hashes[block] {
+			continue
+		}
+		if i == 0 {
+			return nil
+		}
+		logger.Warn("chain reorganization replaced stored blocks, recounting them", "after", block)
+		return store.Rewind(block)
+	}
+
+	// Не совпал ни один хеш из окна: реорганизация глубже reorgDepth, откатываем всё окно.
+	oldest := blocks[len(blocks)-1]
+	if oldest == 0 {
+		return errors.New("chain reorganization replaced every stored block; remove the -store file and rescan")
+	}
+	logger.Warn("chain reorganization is deeper than the stored block hashes, recounting", "hashes", len(blocks), "from", oldest)
+	return store.Rewind(oldest - 1)
+}
+
+// trackCounted запоминает учтённый перевод для Remove. Записи старше reorgDepth блоков
+// удаляются: так глубоко реорганизации не откатываются, а память -watch не растёт.
+func (c *transferCounter) trackCounted(vLog types.Log) {
+	c.counted[logKey{TxHash: vLog.TxHash, Index: vLog.Index}] = vLog.BlockNumber
+	if vLog.BlockNumber < c.countedPrunedAt+reorgDepth {
+		return
+	}
+	for key, block := range c.counted {
+		if block+reorgDepth < vLog.BlockNumber {
+			delete(c.counted, key)
+		}
+	}
+	c.countedPrunedAt = vLog.BlockNumber
+}
+
+// Remove отменяет учёт лога, который подписка -watch пометила removed после реорганизации.
+// Откатываются счётчики рейтинга: count, сырой и decimal объём, отправленные и полученные.
+// Лог, который не был учтён (отфильтрован, повтор, неуспешная транзакция), пропускается;
+// флаги со сводками, которые не откатываются, с -watch недоступны.
+func (c *transferCounter) Remove(ctx context.Context, vLog types.Log) error {
+	key := logKey{TxHash: vLog.TxHash, Index: vLog.Index}
+	if _, ok := c.counted[key]; !ok {
+		return nil
+	}
+	delete(c.counted, key)
+	logger.Warn("chain reorganization removed a log, uncounting it", "tx", vLog.TxHash.Hex(), "block", vLog.BlockNumber)
+	// Тот же лог может вернуться в новой ветке и должен учитываться снова.
+	delete(c.seenLogs, key)
+
+	transferEvent, err := metric.DecodeTransfer(vLog)
+	if err != nil {
+		return nil
+	}
+
+	// Как в Add: вне выборки -value-sample значение не суммировалось.
+	raw := transferEvent.Value
+	if c.opts.ValueSample > 0 && !sampledTx(vLog.TxHash, c.opts.ValueSample) {
+		raw = nil
+	}
+
+	var scaled *big.Rat
+	if c.opts.Decimals && raw != nil {
+		if tokenDecimals, err := c.decimals.Decimals(ctx, vLog.Address); err == nil {
+			scaled = scaleValue(transferEvent.Value, tokenDecimals)
+		}
+	}
+
+	c.stats.DecTransfers()
+	if c.opts.ByTxSender {
+		sender, err := c.senders.Sender(ctx, vLog)
+		if err != nil {
+			return err
+		}
+		c.uncount(sender, raw, scaled)
+		return nil
+	}
+
+	if c.opts.CountMode == countModeMax || c.opts.Sides {
+		c.sent[transferEvent.From]--
+		c.received[transferEvent.To]--
+	}
+	if c.opts.Direction != directionIn {
+		c.uncount(transferEvent.From, raw, scaled)
+	}
+	if c.opts.Direction != directionOut {
+		c.uncount(transferEvent.To, raw, scaled)
+	}
+	return nil
+}
+
+func (c *transferCounter) uncount(address common.Address, raw *big.Int, scaled *big.Rat) {
+	if c.counts[address]--; c.counts[address] <= 0 {
+		delete(c.counts, address)
+	}
+	if raw != nil && c.rawValues[address] != nil {
+		c.rawValues[address].Sub(c.rawValues[address], raw)
+		if scaled != nil {
+			c.values[address].Sub(c.values[address], scaled)
+		}
+	}
+}
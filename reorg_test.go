@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRemoveCountedLogs(t *testing.T) {
+	ctx := context.Background()
+	opts := testScanOptions()
+	opts.Watch, opts.DedupeLogs = true, true
+	counter := newTransferCounter(nil, opts)
+
+	kept := transferLog(testAddress(1), testAddress(2), 5, 10, 0)
+	reorged := transferLog(testAddress(1), testAddress(3), 5, 11, 0)
+	for _, vLog := range []types.Log{kept, reorged, reorged} {
+		if err := counter.Add(ctx, vLog); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	never := transferLog(testAddress(4), testAddress(1), 5, 11, 1)
+	reorged.Removed, never.Removed = true, true
+	for _, vLog := range []types.Log{reorged, never, reorged} {
+		if err := counter.Remove(ctx, vLog); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Лог, которого не было в рейтинге, и повторное снятие не трогают счётчики.
+	if counter.counts[testAddress(1)] != 1 || counter.counts[testAddress(3)] != 0 || counter.counts[testAddress(4)] != 0 {
+		t.Errorf("counts after the reorg = %v, want only the kept transfer", counter.counts)
+	}
+
+	// Новая ветка приносит тот же лог снова: -dedupe-logs не должен считать его повтором.
+	reorged.Removed = false
+	if err := counter.Add(ctx, reorged); err != nil {
+		t.Fatal(err)
+	}
+	if counter.counts[testAddress(3)] != 1 {
+		t.Errorf("re-included log counted %d times, want 1", counter.counts[testAddress(3)])
+	}
+}
+
+func TestCountedLogsPruned(t *testing.T) {
+	opts := testScanOptions()
+	opts.Watch = true
+	counter := newTransferCounter(nil, opts)
+	for block := uint64(1); block <= 3*reorgDepth; block++ {
+		if err := counter.Add(context.Background(), transferLog(testAddress(1), testAddress(2), 1, block, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(counter.counted); n > 2*reorgDepth+1 {
+		t.Errorf("%d counted logs kept, want at most the last %d blocks", n, 2*reorgDepth+1)
+	}
+	for key, block := range counter.counted {
+		if block+reorgDepth < counter.countedPrunedAt {
+			t.Errorf("log %v of block %d kept after pruning at %d", key, block, counter.countedPrunedAt)
+		}
+	}
+}
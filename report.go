@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const formatReport = "report"
+
+// Report — результат прогона одним JSON-объектом: диапазон, время и строки рейтинга
+// в том же виде, что и -format json.
+type Report struct {
+	Chain       string       `json:"chain,omitempty"`
+	ChainID     uint64       `json:"chain_id,omitempty"`
+	FromBlock   uint64       `json:"from_block"`
+	ToBlock     uint64       `json:"to_block"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Logs        int          `json:"logs"`
+	Transfers   int          `json:"transfers"`
+	Metrics     []metricJSON `json:"metrics"`
+	// Tokens — symbol, name и decimals встреченных токенов при -token-metadata.
+	Tokens map[string]tokenMetadata `json:"tokens,omitempty"`
+	// ValueDistributions — распределения сумм переводов по токенам при -value-stats.
+	ValueDistributions []valueDistribution `json:"value_distributions,omitempty"`
+	// FailedRanges — окна, пропущенные -partial-ok: рейтинг не учитывает их переводы.
+	FailedRanges []failedRangeJSON `json:"failed_ranges,omitempty"`
+}
+
+type failedRangeJSON struct {
+	FromBlock uint64 `json:"from_block"`
+	ToBlock   uint64 `json:"to_block"`
+	Error     string `json:"error"`
+}
+
+type reportFormatter struct {
+	chain         chainInfo
+	tokens        *tokenMetadataResolver
+	distributions []valueDistribution
+}
+
+func (f reportFormatter) Write(w io.Writer, metrics []Metric, stats ScanStats) error {
+	report := Report{
+		Chain:       f.chain.Name,
+		ChainID:     f.chain.ID,
+		FromBlock:   stats.FromBlock,
+		ToBlock:     stats.ToBlock,
+		GeneratedAt: time.Now().UTC(),
+		Logs:        stats.Logs,
+		Transfers:   stats.Transfers,
+		Metrics:     make([]metricJSON, len(metrics)),
+		Tokens:      f.tokens.Used(),
+
+		ValueDistributions: f.distributions,
+	}
+	for i, m := range metrics {
+		report.Metrics[i] = toMetricJSON(m)
+	}
+	for _, r := range stats.FailedRanges {
+		report.FailedRanges = append(report.FailedRanges, failedRangeJSON{FromBlock: r.From, ToBlock: r.To, Error: r.Err.Error()})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// retryTransport — http.RoundTripper, который повторяет RPC-запрос при сетевой ошибке, 429 или 5xx
+// с экспоненциальной задержкой и полным jitter, а перед каждой попыткой выдерживает общий лимит
+// запросов в секунду. Заголовок Retry-After ответа важнее расчётной задержки.
+type retryTransport struct {
+	next     http.RoundTripper
+	retries  int
+	base     time.Duration
+	maxDelay time.Duration
+	interval time.Duration
+
+	mu       sync.Mutex
+	nextSlot time.Time
+}
+
+func newRetryTransport(next http.RoundTripper, retries int, base, maxDelay time.Duration, requestsPerSecond float64) *retryTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	t := &retryTransport{next: next, retries: retries, base: base, maxDelay: maxDelay}
+	if requestsPerSecond > 0 {
+		t.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return t
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		try := req.Clone(req.Context())
+		try.Body = io.NopCloser(bytes.NewReader(body))
+		try.ContentLength = int64(len(body))
+
+		resp, err := t.next.RoundTrip(try)
+		var retryAfter time.Duration
+		switch {
+		case err != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+			return nil, err
+		case err != nil:
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		default:
+			return resp, nil
+		}
+
+		if attempt >= t.retries {
+			return resp, err
+		}
+		if resp != nil {
+			err = fmt.Errorf("%s", resp.Status)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := t.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		logger.Warn("RPC request failed, retrying", "err", err, "retry", fmt.Sprintf("%d/%d", attempt+1, t.retries), "delay", delay.Round(time.Millisecond).String())
+		if err := sleepContext(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff — случайная задержка от 0 до base·2^attempt, не больше maxDelay.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	ceiling := t.maxDelay
+	if attempt < 32 {
+		if d := t.base << attempt; d > 0 && d < ceiling {
+			ceiling = d
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// wait выдерживает общий для всех запросов интервал -rpc-rps.
+func (t *retryTransport) wait(ctx context.Context) error {
+	if t.interval == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	if t.nextSlot.Before(now) {
+		t.nextSlot = now
+	}
+	delay := t.nextSlot.Sub(now)
+	t.nextSlot = t.nextSlot.Add(t.interval)
+	t.mu.Unlock()
+
+	return sleepContext(ctx, delay)
+}
+
+// parseRetryAfter понимает Retry-After в секундах; дату HTTP провайдеры RPC не присылают.
+func parseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// sampledTx детерминированно решает, входит ли транзакция в выборку -value-sample:
+// первые 8 байт хэша равномерно распределены, поэтому доля попавших ≈ rate,
+// а все логи одной транзакции попадают или не попадают в выборку вместе.
+func sampledTx(txHash common.Hash, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	return float64(binary.BigEndian.Uint64(txHash[:8])) < rate*math.MaxUint64
+}
+
+// countSample учитывает перевод из выборки в общей сумме значений.
+func (c *transferCounter) countSample(raw *big.Int, scaled *big.Rat) {
+	c.sampled++
+	c.sampledRaw.Add(c.sampledRaw, raw)
+	if scaled != nil {
+		c.sampledValue.Add(c.sampledValue, scaled)
+	}
+}
+
+// sampleFactor — во сколько раз экстраполировать суммы по выборке: переводы / переводы в выборке.
+func (c *transferCounter) sampleFactor() *big.Rat {
+	transfers := c.stats.Snapshot().Transfers
+	if c.sampled == 0 {
+		return new(big.Rat)
+	}
+	return big.NewRat(int64(transfers), int64(c.sampled))
+}
+
+func extrapolateRaw(raw *big.Int, factor *big.Rat) *big.Int {
+	if raw == nil {
+		return new(big.Int)
+	}
+	estimate := new(big.Rat).Mul(new(big.Rat).SetInt(raw), factor)
+	return new(big.Int).Quo(estimate.Num(), estimate.Denom())
+}
+
+func extrapolateValue(value *big.Rat, factor *big.Rat) *big.Rat {
+	if value == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Mul(value, factor)
+}
+
+// writeValueSample печатает размер выборки и экстраполированную общую сумму.
+func (c *transferCounter) writeValueSample(w io.Writer) error {
+	transfers := c.stats.Snapshot().Transfers
+	share := 0.0
+	if transfers > 0 {
+		share = 100 * float64(c.sampled) / float64(transfers)
+	}
+
+	factor := c.sampleFactor()
+	_, err := fmt.Fprintf(w, "values sampled from %d of %d transfers (%.1f%%, requested %.1f%%), estimated total %v raw (%v); per-address values are estimates too\n",
+		c.sampled, transfers, share, 100*c.opts.ValueSample,
+		extrapolateRaw(c.sampledRaw, factor), formatDecimal(extrapolateValue(c.sampledValue, factor)))
+	return err
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// sampleLogs — n переводов хаба 0x01 в отдельных транзакциях с реалистичными хэшами и суммами 1..100.
+func sampleLogs(n int) []types.Log {
+	logs := make([]types.Log, n)
+	for i := range logs {
+		logs[i] = transferLog(testAddress(1), testAddress(byte(2+i%50)), int64(1+i*37%100), uint64(1+i/10), uint(i%10))
+		logs[i].TxHash = crypto.Keccak256Hash(big.NewInt(int64(i)).Bytes())
+	}
+	return logs
+}
+
+func TestValueSampleEstimate(t *testing.T) {
+	logs := sampleLogs(2000)
+	scan := func(rate float64) (*transferCounter, Metric) {
+		opts := testScanOptions()
+		opts.Decimals = true
+		opts.ValueSample = rate
+		opts.TokenRegistry = map[common.Address]tokenInfo{testToken: {Decimals: 0}}
+		counter := newTransferCounter(nil, opts)
+		metrics, err := countLogs(context.Background(), logs, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return counter, metrics[0]
+	}
+
+	_, full := scan(0)
+	for _, rate := range []float64{0.1, 0.25, 0.5} {
+		counter, sampled := scan(rate)
+		if sampled.Address != full.Address || sampled.Count != full.Count {
+			t.Fatalf("rate %v: top %s with %d transfers, want %s with %d: sampling must not change counts",
+				rate, sampled.Address.Hex(), sampled.Count, full.Address.Hex(), full.Count)
+		}
+		share := float64(counter.sampled) / float64(len(logs))
+		if share < rate*0.8 || share > rate*1.2 {
+			t.Errorf("rate %v: sampled %.3f of the transfers", rate, share)
+		}
+		estimate, _ := new(big.Rat).SetFrac(sampled.RawValue, full.RawValue).Float64()
+		if estimate < 0.9 || estimate > 1.1 {
+			t.Errorf("rate %v: estimated %v raw, full sum %v", rate, sampled.RawValue, full.RawValue)
+		}
+
+		var out bytes.Buffer
+		if err := counter.writeValueSample(&out); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(out.String(), "values sampled from ") {
+			t.Errorf("rate %v: unexpected summary %q", rate, out.String())
+		}
+	}
+
+	_, first := scan(0.25)
+	if _, again := scan(0.25); again.RawValue.Cmp(first.RawValue) != 0 {
+		t.Error("the sample differs between runs over the same logs")
+	}
+}
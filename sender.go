@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// senderCache хранит отправителя транзакции по её хэшу, чтобы не запрашивать его для каждого лога.
+type senderCache struct {
+	client *ethclient.Client
+	stats  *Stats
+
+	mu      sync.Mutex
+	senders map[common.Hash]common.Address
+}
+
+func newSenderCache(client *ethclient.Client, stats *Stats) *senderCache {
+	return &senderCache{
+		client:  client,
+		stats:   stats,
+		senders: make(map[common.Hash]common.Address),
+	}
+}
+
+func (c *senderCache) Sender(ctx context.Context, vLog types.Log) (common.Address, error) {
+	c.mu.Lock()
+	sender, ok := c.senders[vLog.TxHash]
+	c.mu.Unlock()
+	if ok {
+		c.stats.IncCacheHit()
+		return sender, nil
+	}
+
+	tx, _, err := c.client.TransactionByHash(ctx, vLog.TxHash)
+	if err != nil {
+		c.stats.IncRPCError()
+		return common.Address{}, fmt.Errorf("failed to fetch transaction %s: %w", vLog.TxHash.Hex(), err)
+	}
+
+	sender, err = c.client.TransactionSender(ctx, tx, vLog.BlockHash, vLog.TxIndex)
+	if err != nil {
+		c.stats.IncRPCError()
+		return common.Address{}, fmt.Errorf("failed to get sender of transaction %s: %w", vLog.TxHash.Hex(), err)
+	}
+
+	c.mu.Lock()
+	c.senders[vLog.TxHash] = sender
+	c.mu.Unlock()
+	return sender, nil
+}
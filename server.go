@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"getBlock/metric"
+)
+
+// maxAPITop ограничивает n в /metrics/top.
+const maxAPITop = 1000
+
+// apiServer отвечает на запросы -serve-api: каждый запрос сканирует свой диапазон
+// отдельным transferCounter с опциями командной строки, поэтому запросы независимы.
+type apiServer struct {
+	client *ethclient.Client
+	opts   scanOptions
+	chain  chainInfo
+}
+
+// addressStats — ответ /address/{addr}.
+type addressStats struct {
+	Address   common.Address `json:"address"`
+	Found     bool           `json:"found"`
+	Rank      int            `json:"rank,omitempty"`
+	Addresses int            `json:"addresses"`
+	FromBlock uint64         `json:"from_block"`
+	ToBlock   uint64         `json:"to_block"`
+	Metric    *metricJSON    `json:"metric,omitempty"`
+}
+
+func (s *apiServer) handleTop(w http.ResponseWriter, r *http.Request) {
+	n := topN
+	if raw := r.URL.Query().Get("n"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxAPITop {
+			writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid n %q: expected 1-%d", raw, maxAPITop))
+			return
+		}
+		n = parsed
+	}
+
+	metrics, counter, status, err := s.scan(r)
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+	if len(metrics) > n {
+		metrics = metrics[:n]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := (reportFormatter{chain: s.chain}).Write(w, metrics, counter.Stats()); err != nil {
+		logger.Error("failed to write API response", "err", err)
+	}
+}
+
+func (s *apiServer) handleAddress(w http.ResponseWriter, r *http.Request) {
+	raw := strings.TrimPrefix(r.URL.Path, "/address/")
+	if !common.IsHexAddress(raw) {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid address %q", raw))
+		return
+	}
+	address := common.HexToAddress(raw)
+
+	metrics, counter, status, err := s.scan(r)
+	if err != nil {
+		writeAPIError(w, status, err)
+		return
+	}
+
+	stats := counter.Stats()
+	response := addressStats{Address: address, Addresses: len(metrics), FromBlock: stats.FromBlock, ToBlock: stats.ToBlock}
+	if rank, m, found := metric.RankOf(metrics, address); found {
+		row := toMetricJSON(m)
+		response.Found, response.Rank, response.Metric = true, rank, &row
+	}
+	writeAPIJSON(w, http.StatusOK, response)
+}
+
+func (s *apiServer) scan(r *http.Request) ([]Metric, *transferCounter, int, error) {
+	return s.scanRange(r.Context(), r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+}
+
+// scanRange разбирает from/to (номера блоков или latest) и сканирует диапазон;
+// без from берётся -lookback блоков до to. Пустой диапазон — пустой рейтинг, а не ошибка.
+// Статус ошибки — HTTP, gRPC переводит его в свой код.
+func (s *apiServer) scanRange(ctx context.Context, from, to string) ([]Metric, *transferCounter, int, error) {
+	opts := s.opts
+	var err error
+	if opts.FromBlock, err = parseBlockFlag(from); err != nil {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("invalid from: %w", err)
+	}
+	if opts.ToBlock, err = parseBlockFlag(to); err != nil {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("invalid to: %w", err)
+	}
+	if opts.FromBlock != nil && opts.ToBlock != nil && opts.FromBlock.Cmp(opts.ToBlock) > 0 {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("from %v is after to %v", opts.FromBlock, opts.ToBlock)
+	}
+
+	counter := newTransferCounter(s.client, opts)
+	metrics, err := currentBlock(ctx, s.client, counter)
+	if errors.Is(err, metric.ErrNoLogs) {
+		return nil, counter, http.StatusOK, nil
+	}
+	if errors.Is(err, ErrTooManyLogs) {
+		return nil, nil, http.StatusRequestEntityTooLarge, err
+	}
+	if err != nil {
+		return nil, nil, http.StatusBadGateway, err
+	}
+	s.tag(metrics)
+	return metrics, counter, http.StatusOK, nil
+}
+
+// tag проставляет строкам сеть и подписи -labels, как в обычном выводе.
+func (s *apiServer) tag(metrics []Metric) {
+	if s.chain.Name != "" {
+		tagChain(metrics, s.chain.Name)
+	}
+	if s.opts.Labels != nil {
+		applyLabels(s.opts.Labels, metrics)
+	}
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("failed to write API response", "err", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// serveAPI обслуживает -serve-api (и -grpc, если grpc.Addr задан) до отмены ctx, после чего
+// даёт текущим запросам завершиться.
+func serveAPI(ctx context.Context, addr string, grpc grpcConfig, client *ethclient.Client, opts scanOptions, chain chainInfo) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on -serve-api %s: %w", addr, err)
+	}
+
+	server := &apiServer{client: client, opts: opts, chain: chain}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics/top", server.handleTop)
+	mux.HandleFunc("/address/", server.handleAddress)
+	httpServer := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	var grpcServer *http.Server
+	grpcErr := make(chan error, 1)
+	if grpc.Addr != "" {
+		grpcListener, err := net.Listen("tcp", grpc.Addr)
+		if err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to listen on -grpc %s: %w", grpc.Addr, err)
+		}
+		grpcServer = &http.Server{Handler: &grpcHandler{api: server, ctx: ctx}, ReadHeaderTimeout: 10 * time.Second}
+		logger.Info("serving the gRPC API", "addr", grpcListener.Addr().String())
+		go func() {
+			err := grpcServer.ServeTLS(grpcListener, grpc.Cert, grpc.Key)
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			} else {
+				// Без gRPC продолжать нельзя: останавливаем и REST API.
+				httpServer.Close()
+			}
+			grpcErr <- err
+		}()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		if grpcServer != nil {
+			shutdownServer(grpcServer)
+		}
+		shutdownServer(httpServer)
+		close(stopped)
+	}()
+	logger.Info("serving the REST API", "url", "http://"+listener.Addr().String())
+	if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	if grpcServer != nil {
+		select {
+		case err := <-grpcErr:
+			if err != nil {
+				return fmt.Errorf("-grpc: %w", err)
+			}
+		case <-stopped:
+		}
+	}
+	// Serve возвращается сразу после Shutdown; ждём, пока текущие запросы допишут ответы.
+	<-stopped
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const (
+	// exitFailed — код выхода при неверных флагах, конфиге или ошибке вывода.
+	exitFailed = 1
+	// exitScanFailed — код выхода, если скан, -watch или -interval остановились из-за ошибки RPC,
+	// -max-logs, -max-total-logs или -timeout, а не сигнала.
+	exitScanFailed = 3
+	// exitAlert — код выхода -alert-exit после сработавшей тревоги -watchlist.
+	exitAlert = 2
+	// exitInterrupted — код выхода, если скан прерван сигналом, как у shell для SIGINT.
+	exitInterrupted = 130
+	// shutdownTimeout — сколько HTTP-серверы ждут завершения текущих запросов после сигнала.
+	shutdownTimeout = 10 * time.Second
+)
+
+// withShutdown отменяет контекст по SIGINT/SIGTERM. После первого сигнала обработчик снимается,
+// и повторный Ctrl+C завершает процесс сразу, не дожидаясь остановки.
+func withShutdown(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Info("shutting down (repeat to exit immediately)", "signal", sig.String())
+			signal.Stop(signals)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// interrupted сообщает, что err вызван остановкой по сигналу, а не сбоем или -timeout.
+func interrupted(ctx context.Context, err error) bool {
+	return err != nil && errors.Is(ctx.Err(), context.Canceled)
+}
+
+// exitError задаёт код выхода run; err == nil — причина уже записана в журнал.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// scanFailed помечает ошибку скана кодом exitScanFailed; уже заданный код остаётся.
+func scanFailed(err error) error {
+	var exit *exitError
+	if errors.As(err, &exit) {
+		return err
+	}
+	return &exitError{code: exitScanFailed, err: err}
+}
+
+// exitStatus записывает ошибку run в журнал и возвращает код выхода: 0 без ошибки, код
+// exitError или exitFailed для остальных.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	code := exitFailed
+	var exit *exitError
+	if errors.As(err, &exit) {
+		code, err = exit.code, exit.err
+	}
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	return code
+}
+
+// shutdownServer даёт текущим запросам shutdownTimeout на завершение и закрывает остальные.
+func shutdownServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+	}
+}
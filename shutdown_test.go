@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	defer setLogOutput(setLogOutput(io.Discard))
+
+	failed := errors.New("boom")
+	for _, tc := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, 0},
+		{"invalid flag", failed, exitFailed},
+		{"scan failed", scanFailed(failed), exitScanFailed},
+		{"timeout", scanFailed(fmt.Errorf("failed to rank transfers: %w", context.DeadlineExceeded)), exitScanFailed},
+		{"interrupted", &exitError{code: exitInterrupted}, exitInterrupted},
+		{"code kept by scanFailed", scanFailed(fmt.Errorf("watch: %w", &exitError{code: exitInterrupted})), exitInterrupted},
+	} {
+		if got := exitStatus(tc.err); got != tc.want {
+			t.Errorf("%s: exit status %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import "sort"
+
+const (
+	sortSent     = "sent"
+	sortReceived = "received"
+	// sortTotal — синоним count: отправленные и полученные вместе.
+	sortTotal = "total"
+)
+
+// fillSides проставляет число отправленных и полученных переводов каждого адреса.
+// Для -sort sent|received весь рейтинг переупорядочивается по этой стороне, при равенстве — по count.
+func (c *transferCounter) fillSides(metrics []Metric) {
+	for i := range metrics {
+		metrics[i].SentCount = c.sent[metrics[i].Address]
+		metrics[i].ReceivedCount = c.received[metrics[i].Address]
+	}
+
+	side := func(m Metric) int { return m.SentCount }
+	switch c.opts.RankBy {
+	case sortSent:
+	case sortReceived:
+		side = func(m Metric) int { return m.ReceivedCount }
+	default:
+		return
+	}
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if a, b := side(metrics[i]), side(metrics[j]); a != b {
+			return a > b
+		}
+		return metrics[i].Count > metrics[j].Count
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// defaultMulticall — Multicall3, развёрнутый по одному адресу почти во всех EVM-сетях.
+const defaultMulticall = "0xcA11bde05977b3631167028862bE2a173976CA11"
+
+// defaultSnapshotBatch — сколько balanceOf уходит в одном вызове aggregate3.
+const defaultSnapshotBatch = 500
+
+const multicallABI = `[
+{"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"}
+]`
+
+var multicallContractABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(multicallABI))
+	if err != nil {
+		panic(fmt.Sprintf("failed in marshall multicall abi: %v", err))
+	}
+	return parsed
+}()
+
+// balanceOfSelector — balanceOf(address).
+var balanceOfSelector = []byte{0x70, 0xa0, 0x82, 0x31}
+
+// multicallCall и multicallResult повторяют Call3 и Result из Multicall3; имена полей — для abi.
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// holderBalance — баланс адреса из скана в одном токене на блоке снимка.
+type holderBalance struct {
+	Holder  common.Address
+	Balance *big.Int
+	// Value — баланс с учётом decimals; nil без -decimals или если decimals неизвестны.
+	Value *big.Rat
+}
+
+// tokenHolders — держатели токена по убыванию баланса; Failed — адреса, чей balanceOf откатился.
+type tokenHolders struct {
+	Token   common.Address
+	Holders []holderBalance
+	Failed  int
+}
+
+// Snapshot запрашивает текущие балансы всех адресов, которые встретились в переводах каждого
+// токена, на блоке конца скана: вызовы balanceOf упаковываются в aggregate3 по batch штук и
+// отправляются в -enrich-concurrency горутин. Адреса с нулевым балансом в снимок не попадают.
+func (c *transferCounter) Snapshot(ctx context.Context, client *ethclient.Client, multicall common.Address, batch int) ([]tokenHolders, error) {
+	type holding struct {
+		token, holder common.Address
+	}
+	var calls []holding
+	for token, participants := range c.tokenParticipants {
+		for holder := range participants {
+			calls = append(calls, holding{token, holder})
+		}
+	}
+
+	balances := make([]*big.Int, len(calls))
+	block := new(big.Int).SetUint64(c.toBlock)
+	batches := (len(calls) + batch - 1) / batch
+	var mu sync.Mutex
+	err := c.pool.Run(ctx, batches, func(ctx context.Context, i int) error {
+		start := i * batch
+		end := start + batch
+		if end > len(calls) {
+			end = len(calls)
+		}
+
+		packed := make([]multicallCall, 0, end-start)
+		for _, call := range calls[start:end] {
+			data := append(append([]byte{}, balanceOfSelector...), common.LeftPadBytes(call.holder.Bytes(), 32)...)
+			packed = append(packed, multicallCall{Target: call.token, AllowFailure: true, CallData: data})
+		}
+		input, err := multicallContractABI.Pack("aggregate3", packed)
+		if err != nil {
+			return err
+		}
+		output, err := client.CallContract(ctx, ethereum.CallMsg{To: &multicall, Data: input}, block)
+		if err != nil {
+			c.stats.IncRPCError()
+			return fmt.Errorf("failed to call multicall %s at block %v: %w", multicall.Hex(), block, err)
+		}
+		var results []multicallResult
+		if err := multicallContractABI.UnpackIntoInterface(&results, "aggregate3", output); err != nil {
+			return fmt.Errorf("failed to decode multicall %s response (is it deployed on this chain?): %w", multicall.Hex(), err)
+		}
+		if len(results) != end-start {
+			return fmt.Errorf("multicall %s returned %d results for %d calls", multicall.Hex(), len(results), end-start)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for j, result := range results {
+			// Откат или ответ не в 32 байта — контракт не отдаёт баланс: адрес считается неудачным.
+			if result.Success && len(result.ReturnData) == 32 {
+				balances[start+j] = new(big.Int).SetBytes(result.ReturnData)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byToken := make(map[common.Address]*tokenHolders)
+	for i, call := range calls {
+		holders, ok := byToken[call.token]
+		if !ok {
+			holders = &tokenHolders{Token: call.token}
+			byToken[call.token] = holders
+		}
+		switch {
+		case balances[i] == nil:
+			holders.Failed++
+		case balances[i].Sign() > 0:
+			row := holderBalance{Holder: call.holder, Balance: balances[i]}
+			if c.opts.Decimals {
+				if decimals, err := c.decimals.Decimals(ctx, call.token); err == nil {
+					row.Value = scaleValue(balances[i], decimals)
+				}
+			}
+			holders.Holders = append(holders.Holders, row)
+		}
+	}
+
+	out := make([]tokenHolders, 0, len(byToken))
+	for _, holders := range byToken {
+		sort.Slice(holders.Holders, func(i, j int) bool {
+			if cmp := holders.Holders[i].Balance.Cmp(holders.Holders[j].Balance); cmp != 0 {
+				return cmp > 0
+			}
+			return holders.Holders[i].Holder.Hex() < holders.Holders[j].Holder.Hex()
+		})
+		out = append(out, *holders)
+	}
+	// Токены с большим числом держателей — первыми, как в -top-tokens.
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].Holders) != len(out[j].Holders) {
+			return len(out[i].Holders) > len(out[j].Holders)
+		}
+		return out[i].Token.Hex() < out[j].Token.Hex()
+	})
+	return out, nil
+}
+
+// writeSnapshot печатает топ держателей каждого токена после рейтинга активности.
+func writeSnapshot(w io.Writer, tokens []tokenHolders, block uint64, opts outputOptions) error {
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Token", "Rank", "Holder", "Balance"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			for i, h := range topHolders(t.Holders) {
+				if err := writeMarkdownRow(w, []string{opts.Tokens.Label(t.Token), fmt.Sprint(i + 1), h.Holder.Hex(), holderBalanceText(h)}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "holder balances at block %d:\n", block); err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		line := fmt.Sprintf("token %v: %d holders with a balance among scanned addresses", opts.Tokens.Label(t.Token), len(t.Holders))
+		if t.Failed > 0 {
+			line += fmt.Sprintf(", balanceOf failed for %d", t.Failed)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+		for i, h := range topHolders(t.Holders) {
+			if _, err := fmt.Fprintf(w, "  #%d %v holds %s\n", i+1, h.Holder.Hex(), holderBalanceText(h)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func topHolders(holders []holderBalance) []holderBalance {
+	if len(holders) > topN {
+		return holders[:topN]
+	}
+	return holders
+}
+
+func holderBalanceText(h holderBalance) string {
+	if h.Value != nil {
+		return formatDecimal(h.Value)
+	}
+	return h.Balance.String() + " raw"
+}
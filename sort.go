@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+const (
+	sortCount   = "count"
+	sortAddress = "address"
+	sortValue   = "value"
+	sortScore   = "score"
+	sortInflow  = "inflow"
+
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+func isSortKey(key string) bool {
+	switch key {
+	case sortCount, sortAddress, sortValue, sortVolume, sortUSD, sortScore, sortInflow, sortRate, sortTxs, sortFee, sortSent, sortReceived:
+		return true
+	}
+	return false
+}
+
+func validateSort(by, secondary, order string) error {
+	if !isSortKey(by) {
+		return fmt.Errorf("invalid -sort %q: expected count, total, sent, received, address, value, volume, usd, score, rate, txs, fee or inflow", by)
+	}
+	if secondary != "" && !isSortKey(secondary) {
+		return fmt.Errorf("invalid -sort-secondary %q: expected count, total, sent, received, address, value, volume, usd, score, rate, txs, fee or inflow", secondary)
+	}
+	switch order {
+	case "", orderAsc, orderDesc:
+	default:
+		return fmt.Errorf("invalid -order %q: expected asc or desc", order)
+	}
+	return nil
+}
+
+// compareBy сравнивает строки по одному ключу в его естественном порядке:
+// count, sent, received, value, volume, usd, score, rate, txs и fee — по убыванию, address — по возрастанию 20 байт адреса
+// (группы -group-prefix сравниваются по префиксу).
+func compareBy(key string, a, b Metric) int {
+	switch key {
+	case sortCount:
+		return b.Count - a.Count
+	case sortSent:
+		return b.SentCount - a.SentCount
+	case sortReceived:
+		return b.ReceivedCount - a.ReceivedCount
+	case sortValue:
+		return compareValue(b.RawValue, a.RawValue)
+	case sortInflow:
+		return compareValue(b.InflowValue, a.InflowValue)
+	case sortScore:
+		return compareFloat(b.Score, a.Score)
+	case sortRate:
+		return compareFloat(b.Rate, a.Rate)
+	case sortTxs:
+		return b.Txs - a.Txs
+	case sortFee:
+		return ratOrZero(b.Fee).Cmp(ratOrZero(a.Fee))
+	case sortVolume:
+		return volume(b).Cmp(volume(a))
+	case sortUSD:
+		return ratOrZero(b.USD).Cmp(ratOrZero(a.USD))
+	default:
+		return compareMetricAddress(a, b)
+	}
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareValue(a, b *big.Int) int {
+	if a == nil {
+		a = new(big.Int)
+	}
+	if b == nil {
+		b = new(big.Int)
+	}
+	return a.Cmp(b)
+}
+
+// sortMetrics упорядочивает весь рейтинг по ключам by, затем secondary, затем по адресу; топ
+// вывода отрезается уже от результата. order меняет направление только основного ключа.
+func sortMetrics(metrics []Metric, by, secondary, order string) []Metric {
+	ranked := by == sortCount || by == sortInflow || by == sortSent || by == sortReceived
+	if ranked && secondary == "" && order != orderAsc {
+		return metrics
+	}
+
+	if ranked && secondary == "" && by != sortCount {
+		// Рейтинг по стороне или inflow разрешает равенства общим числом переводов, и asc сохраняет это.
+		// Просто развернуть рейтинг нельзя: равные строки пошли бы в обратном порядке адресов.
+		secondary = sortCount
+	}
+
+	sorted := append([]Metric(nil), metrics...)
+
+	natural := orderDesc
+	if by == sortAddress {
+		natural = orderAsc
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if cmp := compareBy(by, sorted[i], sorted[j]); cmp != 0 {
+			if order != "" && order != natural {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		if secondary != "" {
+			if cmp := compareBy(secondary, sorted[i], sorted[j]); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return compareMetricAddress(sorted[i], sorted[j]) < 0
+	})
+	return sorted
+}
+
+func compareMetricAddress(a, b Metric) int {
+	if a.Group != "" || b.Group != "" {
+		return bytes.Compare([]byte(a.Group), []byte(b.Group))
+	}
+	return bytes.Compare(a.Address[:], b.Address[:])
+}
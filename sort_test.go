@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// whaleLogs — шесть адресов, переводящих друг другу по мелочи (по 6 переводов у каждого), и один
+// крупный перевод 0x20 → 0x21: по числу переводов кит не попадает в топ, по сумме — первый.
+func whaleLogs() []types.Log {
+	var logs []types.Log
+	var index uint
+	for round := 0; round < 3; round++ {
+		for i := byte(1); i <= 6; i++ {
+			logs = append(logs, transferLog(testAddress(i), testAddress(i%6+1), 1, 1, index))
+			index++
+		}
+	}
+	return append(logs, transferLog(testAddress(0x20), testAddress(0x21), 1000, 2, 0))
+}
+
+func TestSortBeforeTop(t *testing.T) {
+	opts := testScanOptions()
+	opts.Decimals = true
+	opts.TokenRegistry = map[common.Address]tokenInfo{testToken: {Decimals: 0}}
+	metrics := countTestLogs(t, opts, whaleLogs())
+
+	tests := []struct {
+		name  string
+		sort  string
+		order string
+		want  []string
+	}{
+		{"count", sortCount, "", []string{"0x01", "0x02", "0x03", "0x04", "0x05"}},
+		{"count asc", sortCount, orderAsc, []string{"0x20", "0x21", "0x01", "0x02", "0x03"}},
+		{"value", sortValue, "", []string{"0x20", "0x21", "0x01", "0x02", "0x03"}},
+		{"value asc", sortValue, orderAsc, []string{"0x01", "0x02", "0x03", "0x04", "0x05"}},
+		{"address desc", sortAddress, orderDesc, []string{"0x21", "0x20", "0x06", "0x05", "0x04"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := renderTestMetrics(t, metrics, outputOptions{AddressesOnly: true, Sort: tt.sort, Order: tt.order})
+			got := strings.Fields(out)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d:\n%s", len(got), len(tt.want), out)
+			}
+			for i, want := range tt.want {
+				if got[i] != common.HexToAddress(want).Hex() {
+					t.Errorf("row %d is %s, want %s", i+1, got[i], common.HexToAddress(want).Hex())
+				}
+			}
+		})
+	}
+}
+
+func TestSortSecondary(t *testing.T) {
+	row := func(n byte, count int, value int64) Metric {
+		return Metric{Address: testAddress(n), Count: count, RawValue: big.NewInt(value)}
+	}
+	tests := []struct {
+		name          string
+		metrics       []Metric
+		by, secondary string
+		order         string
+		want          []byte
+	}{
+		{"tie on count broken by value", []Metric{row(1, 5, 1), row(2, 7, 0), row(3, 5, 9)}, sortCount, sortValue, "", []byte{2, 3, 1}},
+		{"asc reverses only the primary key", []Metric{row(1, 5, 1), row(2, 7, 0), row(3, 5, 9)}, sortCount, sortValue, orderAsc, []byte{3, 1, 2}},
+		{"tie on value broken by count", []Metric{row(1, 2, 4), row(2, 8, 4), row(3, 5, 6)}, sortValue, sortCount, "", []byte{3, 2, 1}},
+		{"tie on both falls through to the address", []Metric{row(3, 5, 9), row(1, 5, 9), row(2, 5, 9)}, sortCount, sortValue, "", []byte{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sortMetrics(tt.metrics, tt.by, tt.secondary, tt.order)
+			for i, n := range tt.want {
+				if got[i].Address != testAddress(n) {
+					t.Fatalf("row %d is %s, want %s", i+1, got[i].Address.Hex(), testAddress(n).Hex())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync/atomic"
+
+	"getBlock/metric"
+)
+
+// ScanStats и FailedRange объявлены в metric: их возвращает и metric.Scanner.
+type (
+	ScanStats   = metric.ScanStats
+	FailedRange = metric.FailedRange
+)
+
+// Stats — счётчики прогона, которые могут обновляться из воркеров обогащения
+// и других горутин; Snapshot отдаёт их как обычный ScanStats.
+type Stats struct {
+	logs      atomic.Int64
+	transfers atomic.Int64
+	rpcErrors atomic.Int64
+	cacheHits atomic.Int64
+	unpacks   atomic.Int64
+	dups      atomic.Int64
+}
+
+// IncLogs возвращает число логов с учётом текущего.
+func (s *Stats) IncLogs() int64 {
+	return s.logs.Add(1)
+}
+
+func (s *Stats) IncTransfers() {
+	s.transfers.Add(1)
+}
+
+// DecTransfers отменяет перевод, снятый реорганизацией.
+func (s *Stats) DecTransfers() {
+	s.transfers.Add(-1)
+}
+
+func (s *Stats) IncRPCError() {
+	s.rpcErrors.Add(1)
+}
+
+func (s *Stats) IncCacheHit() {
+	s.cacheHits.Add(1)
+}
+
+func (s *Stats) IncUnpackFailure() {
+	s.unpacks.Add(1)
+}
+
+func (s *Stats) IncDuplicate() {
+	s.dups.Add(1)
+}
+
+func (s *Stats) Snapshot() ScanStats {
+	return ScanStats{
+		Logs:      int(s.logs.Load()),
+		Transfers: int(s.transfers.Load()),
+		RPCErrors: int(s.rpcErrors.Load()),
+		CacheHits: int(s.cacheHits.Load()),
+
+		UnpackFailures: int(s.unpacks.Load()),
+		Duplicates:     int(s.dups.Load()),
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestStatsConcurrent обновляет счётчики из многих горутин; вместе с go test -race проверяет,
+// что Stats безопасен для воркеров.
+func TestStatsConcurrent(t *testing.T) {
+	const workers, each = 16, 1000
+	stats := new(Stats)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				stats.IncLogs()
+				stats.IncTransfers()
+				stats.IncRPCError()
+				stats.IncCacheHit()
+				stats.IncUnpackFailure()
+				stats.IncDuplicate()
+				if j%2 == 0 {
+					stats.DecTransfers()
+				}
+				stats.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := stats.Snapshot()
+	want := ScanStats{
+		Logs:           workers * each,
+		Transfers:      workers * each / 2,
+		RPCErrors:      workers * each,
+		CacheHits:      workers * each,
+		UnpackFailures: workers * each,
+		Duplicates:     workers * each,
+	}
+	if got.Logs != want.Logs || got.Transfers != want.Transfers || got.RPCErrors != want.RPCErrors ||
+		got.CacheHits != want.CacheHits || got.UnpackFailures != want.UnpackFailures || got.Duplicates != want.Duplicates {
+		t.Errorf("Snapshot = %+v, want %+v", got, want)
+	}
+}
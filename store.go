@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"getBlock/metric"
+)
+
+// storedCount — переводы и сырой объём адреса в одном блоке.
+type storedCount struct {
+	Count    int      `json:"count"`
+	RawValue *big.Int `json:"raw_value"`
+}
+
+// metricStore — файл -store: счётчики по блокам и адресам и последний обработанный блок.
+// Direction и Contracts запоминаются, чтобы не смешать в одном файле разные подсчёты.
+type metricStore struct {
+	path string
+	// db — база SQLite; nil у JSON-файла, который хранится в памяти целиком и переписывается.
+	db *sql.DB
+
+	Direction string                                     `json:"direction"`
+	Contracts []common.Address                           `json:"contracts,omitempty"`
+	LastBlock *uint64                                    `json:"last_block,omitempty"`
+	Blocks    map[uint64]map[common.Address]*storedCount `json:"blocks"`
+	// Hashes — хеши последних reorgDepth блоков, известных прогону, для проверки реорганизации.
+	Hashes map[uint64]common.Hash `json:"hashes,omitempty"`
+}
+
+// openStore читает файл -store или создаёт пустое хранилище, если файла ещё нет: базу SQLite
+// или, для файлов .json, JSON-файл.
+func openStore(path, direction string, contracts []common.Address) (*metricStore, error) {
+	if strings.HasPrefix(path, "postgres://") || strings.HasPrefix(path, "postgresql://") {
+		return nil, errors.New("-store has no Postgres backend, the build includes only SQLite: pass a database file (or a .json file)")
+	}
+	if isSQLiteStore(path) {
+		return openSQLiteStore(path, direction, contracts)
+	}
+	store := &metricStore{
+		path:      path,
+		Direction: direction,
+		Contracts: contracts,
+		Blocks:    make(map[uint64]map[common.Address]*storedCount),
+		Hashes:    make(map[uint64]common.Hash),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+
+	var saved metricStore
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse store %s: %w", path, err)
+	}
+	if saved.Direction != direction || contractsKey(saved.Contracts) != contractsKey(contracts) {
+		return nil, fmt.Errorf("store %s was built with -direction %s -contracts %q; use the same options or another file",
+			path, saved.Direction, contractsKey(saved.Contracts))
+	}
+
+	store.LastBlock = saved.LastBlock
+	if saved.Blocks != nil {
+		store.Blocks = saved.Blocks
+	}
+	if saved.Hashes != nil {
+		store.Hashes = saved.Hashes
+	}
+	return store, nil
+}
+
+func contractsKey(contracts []common.Address) string {
+	hexes := make([]string, len(contracts))
+	for i, contract := range contracts {
+		hexes[i] = contract.Hex()
+	}
+	sort.Strings(hexes)
+	return strings.Join(hexes, ",")
+}
+
+// ResumeFrom — первый ещё не обработанный блок; ok == false, если хранилище пустое.
+func (s *metricStore) ResumeFrom() (*big.Int, bool) {
+	if s.LastBlock == nil {
+		return nil, false
+	}
+	return new(big.Int).SetUint64(*s.LastBlock + 1), true
+}
+
+// Totals — переводы адресов за все сохранённые блоки.
+func (s *metricStore) Totals() (map[common.Address]int, error) {
+	if s.db != nil {
+		return s.totalsSQL()
+	}
+	totals := make(map[common.Address]int)
+	for _, addresses := range s.Blocks {
+		for address, stored := range addresses {
+			totals[address] += stored.Count
+		}
+	}
+	return totals, nil
+}
+
+// Close закрывает базу SQLite; JSON-файлу закрывать нечего.
+func (s *metricStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Merge добавляет счётчики и хеши нового диапазона, сдвигает последний блок и сохраняет файл.
+// Хеши старше reorgDepth блоков от последнего отбрасываются.
+func (s *metricStore) Merge(blocks map[uint64]map[common.Address]*storedCount, hashes map[uint64]common.Hash, lastBlock uint64) error {
+	if s.db != nil {
+		if err := s.mergeSQL(blocks, hashes, lastBlock); err != nil {
+			return err
+		}
+		// В памяти у базы только хеши и последний блок: они нужны rewindStore.
+		blocks = nil
+	}
+	for block, addresses := range blocks {
+		s.Blocks[block] = addresses
+	}
+	for block, hash := range hashes {
+		s.Hashes[block] = hash
+	}
+	for block := range s.Hashes {
+		if block+reorgDepth <= lastBlock {
+			delete(s.Hashes, block)
+		}
+	}
+	s.LastBlock = &lastBlock
+	if s.db != nil {
+		return nil
+	}
+	return s.save()
+}
+
+// Rewind удаляет счётчики и хеши блоков после lastValid и сохраняет файл.
+func (s *metricStore) Rewind(lastValid uint64) error {
+	if s.db != nil {
+		if err := s.rewindSQL(lastValid); err != nil {
+			return err
+		}
+	}
+	for block := range s.Blocks {
+		if block > lastValid {
+			delete(s.Blocks, block)
+		}
+	}
+	for block := range s.Hashes {
+		if block > lastValid {
+			delete(s.Hashes, block)
+		}
+	}
+	s.LastBlock = &lastValid
+	if s.db != nil {
+		return nil
+	}
+	return s.save()
+}
+
+func (s *metricStore) save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic пишет файл через временный, чтобы прерванный прогон не оставил его обрезанным.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// trackBlockCount учитывает перевод адреса в блоке для -store.
+func (c *transferCounter) trackBlockCount(address common.Address, block uint64, raw *big.Int) {
+	addresses := c.blockCounts[block]
+	if addresses == nil {
+		addresses = make(map[common.Address]*storedCount)
+		c.blockCounts[block] = addresses
+	}
+	stored := addresses[address]
+	if stored == nil {
+		stored = &storedCount{RawValue: new(big.Int)}
+		addresses[address] = stored
+	}
+	stored.Count++
+	if raw != nil {
+		stored.RawValue.Add(stored.RawValue, raw)
+	}
+}
+
+// seedFromStore добавляет к рейтингу счётчики прошлых прогонов из -store.
+func (c *transferCounter) seedFromStore() error {
+	totals, err := c.opts.Store.Totals()
+	if err != nil {
+		return err
+	}
+	for address, count := range totals {
+		c.counts[address] += count
+	}
+	if c.opts.Growth {
+		c.rememberKnown(totals)
+	}
+	return nil
+}
+
+// resumeFrom — начало диапазона по -store, если в нём уже есть прогоны.
+func (c *transferCounter) resumeFrom() (*big.Int, bool) {
+	if c.opts.Store == nil {
+		return nil, false
+	}
+	return c.opts.Store.ResumeFrom()
+}
+
+// countCheckpointed сканирует [from, to] партиями по -chunk-size × -workers блоков и после
+// каждой партии сохраняет -store, так что прерванный прогон продолжится с последней
+// сохранённой партии, а не с начала.
+func (c *transferCounter) countCheckpointed(ctx context.Context, client metric.Client, from, to *big.Int) error {
+	batch := new(big.Int).SetUint64(c.opts.ChunkSize * uint64(c.fetchPool.concurrency))
+	for start := new(big.Int).Set(from); start.Cmp(to) <= 0; {
+		end := new(big.Int).Sub(new(big.Int).Add(start, batch), big.NewInt(1))
+		if end.Cmp(to) > 0 {
+			end = new(big.Int).Set(to)
+		}
+
+		logs, err := c.fetchLogs(ctx, client, start, end)
+		if err != nil {
+			return err
+		}
+		c.SetRange(from.Uint64(), end.Uint64())
+		if err := c.addLogs(ctx, logs); err != nil {
+			return err
+		}
+		if err := c.checkpoint(ctx, end.Uint64()); err != nil {
+			return err
+		}
+		logger.Info("checkpoint saved to -store", "last_block", end)
+
+		start = new(big.Int).Add(end, big.NewInt(1))
+	}
+
+	c.warnAdded()
+	return nil
+}
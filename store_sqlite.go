@@ -0,0 +1,210 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema — счётчики по блокам и адресам, хеши последних блоков и параметры хранилища
+// (direction, contracts, last_block) в store_meta.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS store_meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS block_counts (
+	block     INTEGER NOT NULL,
+	address   TEXT    NOT NULL,
+	count     INTEGER NOT NULL,
+	raw_value TEXT    NOT NULL,
+	PRIMARY KEY (block, address)
+);
+CREATE TABLE IF NOT EXISTS block_hashes (
+	block INTEGER PRIMARY KEY,
+	hash  TEXT NOT NULL
+);`
+
+// isSQLiteStore — -store хранится в SQLite, кроме файлов .json, которые остаются JSON-файлом.
+func isSQLiteStore(path string) bool {
+	return !strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// openSQLiteStore открывает или создаёт базу -store. Счётчики остаются в базе: в памяти только
+// последний блок и хеши для проверки реорганизации, суммы Totals считает SQL.
+func openSQLiteStore(path, direction string, contracts []common.Address) (*metricStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store %s: %w", path, err)
+	}
+	// Одно соединение: SQLite всё равно пишет по одному, а так не бывает SQLITE_BUSY между своими же запросами.
+	db.SetMaxOpenConns(1)
+	store, err := loadSQLiteStore(db, path, direction, contracts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func loadSQLiteStore(db *sql.DB, path, direction string, contracts []common.Address) (*metricStore, error) {
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, fmt.Errorf("failed to create store %s: %w", path, err)
+	}
+
+	meta := make(map[string]string)
+	rows, err := db.Query(`SELECT key, value FROM store_meta`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+	}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+		}
+		meta[key] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+	}
+
+	if saved, ok := meta["direction"]; ok && (saved != direction || meta["contracts"] != contractsKey(contracts)) {
+		return nil, fmt.Errorf("store %s was built with -direction %s -contracts %q; use the same options or another file",
+			path, saved, meta["contracts"])
+	}
+	if _, err := db.Exec(`INSERT INTO store_meta (key, value) VALUES ('direction', ?), ('contracts', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, direction, contractsKey(contracts)); err != nil {
+		return nil, fmt.Errorf("failed to write store %s: %w", path, err)
+	}
+
+	store := &metricStore{
+		path:      path,
+		db:        db,
+		Direction: direction,
+		Contracts: contracts,
+		Blocks:    make(map[uint64]map[common.Address]*storedCount),
+		Hashes:    make(map[uint64]common.Hash),
+	}
+	if value, ok := meta["last_block"]; ok {
+		lastBlock, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("store %s has an invalid last_block %q", path, value)
+		}
+		store.LastBlock = &lastBlock
+	}
+
+	hashes, err := db.Query(`SELECT block, hash FROM block_hashes`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+	}
+	defer hashes.Close()
+	for hashes.Next() {
+		var block uint64
+		var hash string
+		if err := hashes.Scan(&block, &hash); err != nil {
+			return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+		}
+		store.Hashes[block] = common.HexToHash(hash)
+	}
+	if err := hashes.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read store %s: %w", path, err)
+	}
+	return store, nil
+}
+
+// mergeSQL записывает блоки одной транзакцией: строки (блок, адрес) вставляются или заменяются,
+// поэтому повторно сохранённый блок не удваивает счётчики.
+func (s *metricStore) mergeSQL(blocks map[uint64]map[common.Address]*storedCount, hashes map[uint64]common.Hash, lastBlock uint64) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		upsert, err := tx.Prepare(`INSERT INTO block_counts (block, address, count, raw_value) VALUES (?, ?, ?, ?)
+			ON CONFLICT (block, address) DO UPDATE SET count = excluded.count, raw_value = excluded.raw_value`)
+		if err != nil {
+			return err
+		}
+		defer upsert.Close()
+		for block, addresses := range blocks {
+			for address, stored := range addresses {
+				raw := "0"
+				if stored.RawValue != nil {
+					raw = stored.RawValue.String()
+				}
+				if _, err := upsert.Exec(int64(block), address.Hex(), stored.Count, raw); err != nil {
+					return err
+				}
+			}
+		}
+
+		for block, hash := range hashes {
+			if _, err := tx.Exec(`INSERT INTO block_hashes (block, hash) VALUES (?, ?)
+				ON CONFLICT (block) DO UPDATE SET hash = excluded.hash`, int64(block), hash.Hex()); err != nil {
+				return err
+			}
+		}
+		if lastBlock >= reorgDepth {
+			if _, err := tx.Exec(`DELETE FROM block_hashes WHERE block <= ?`, int64(lastBlock-reorgDepth)); err != nil {
+				return err
+			}
+		}
+		return setLastBlock(tx, lastBlock)
+	})
+}
+
+// rewindSQL удаляет блоки после lastValid.
+func (s *metricStore) rewindSQL(lastValid uint64) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM block_counts WHERE block > ?`, int64(lastValid)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM block_hashes WHERE block > ?`, int64(lastValid)); err != nil {
+			return err
+		}
+		return setLastBlock(tx, lastValid)
+	})
+}
+
+func setLastBlock(tx *sql.Tx, block uint64) error {
+	_, err := tx.Exec(`INSERT INTO store_meta (key, value) VALUES ('last_block', ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value`, strconv.FormatUint(block, 10))
+	return err
+}
+
+func (s *metricStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
+
+// totalsSQL — переводы адресов за все сохранённые блоки.
+func (s *metricStore) totalsSQL() (map[common.Address]int, error) {
+	rows, err := s.db.Query(`SELECT address, SUM(count) FROM block_counts GROUP BY address`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	defer rows.Close()
+	totals := make(map[common.Address]int)
+	for rows.Next() {
+		var address string
+		var count int
+		if err := rows.Scan(&address, &count); err != nil {
+			return nil, fmt.Errorf("failed to read store: %w", err)
+		}
+		totals[common.HexToAddress(address)] = count
+	}
+	return totals, rows.Err()
+}
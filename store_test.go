@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func storedBlocks(counts map[uint64]map[common.Address]int) map[uint64]map[common.Address]*storedCount {
+	blocks := make(map[uint64]map[common.Address]*storedCount)
+	for block, addresses := range counts {
+		blocks[block] = make(map[common.Address]*storedCount)
+		for address, count := range addresses {
+			blocks[block][address] = &storedCount{Count: count, RawValue: big.NewInt(int64(count) * 10)}
+		}
+	}
+	return blocks
+}
+
+func TestStoreBackends(t *testing.T) {
+	for _, name := range []string{"metrics.json", "metrics.db"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			a, b := testAddress(1), testAddress(2)
+
+			store, err := openStore(path, directionBoth, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := store.ResumeFrom(); ok {
+				t.Fatal("a new store must not resume")
+			}
+			blocks := storedBlocks(map[uint64]map[common.Address]int{10: {a: 2, b: 1}, 11: {a: 1}})
+			if err := store.Merge(blocks, map[uint64]common.Hash{11: {0x11}}, 11); err != nil {
+				t.Fatal(err)
+			}
+			// Повторно сохранённый блок заменяет свои строки, а не складывается с ними.
+			if err := store.Merge(storedBlocks(map[uint64]map[common.Address]int{11: {a: 1}, 12: {b: 4}}), map[uint64]common.Hash{12: {0x12}}, 12); err != nil {
+				t.Fatal(err)
+			}
+			store.Close()
+
+			store, err = openStore(path, directionBoth, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if from, ok := store.ResumeFrom(); !ok || from.Uint64() != 13 {
+				t.Errorf("ResumeFrom = %v, %v, want 13", from, ok)
+			}
+			if store.Hashes[12] != (common.Hash{0x12}) || store.Hashes[11] != (common.Hash{0x11}) {
+				t.Errorf("hashes = %v, want blocks 11 and 12", store.Hashes)
+			}
+			totals, err := store.Totals()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if totals[a] != 3 || totals[b] != 5 {
+				t.Errorf("totals = %v, want %s: 3, %s: 5", totals, a.Hex(), b.Hex())
+			}
+
+			if err := store.Rewind(10); err != nil {
+				t.Fatal(err)
+			}
+			totals, err = store.Totals()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if totals[a] != 2 || totals[b] != 1 || len(store.Hashes) != 0 {
+				t.Errorf("after Rewind(10) totals = %v, hashes = %v, want block 10 only", totals, store.Hashes)
+			}
+			store.Close()
+
+			if _, err := openStore(path, directionIn, nil); err == nil {
+				t.Error("a store built with -direction both must refuse -direction in")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"getBlock/metric"
+)
+
+// TokenSupply — минты и сжигания одного токена за просканированный диапазон.
+type TokenSupply struct {
+	Token                    common.Address
+	Mints, Burns             int
+	Minted, Burned           *big.Int
+	MintedValue, BurnedValue *big.Rat
+}
+
+// countSupply учитывает минты (from == 0x0) и сжигания (to == 0x0) отдельно от обычных переводов:
+// в сумме по всем токенам и по каждому токену.
+func (c *transferCounter) countSupply(token common.Address, transferEvent metric.TransferEvents, scaled *big.Rat) {
+	mint := transferEvent.From == (common.Address{})
+	burn := transferEvent.To == (common.Address{})
+	if !mint && !burn {
+		return
+	}
+
+	supply, ok := c.supplies[token]
+	if !ok {
+		supply = &TokenSupply{Token: token, Minted: new(big.Int), Burned: new(big.Int), MintedValue: new(big.Rat), BurnedValue: new(big.Rat)}
+		c.supplies[token] = supply
+	}
+
+	if mint {
+		c.mints++
+		supply.Mints++
+		c.minted.Add(c.minted, transferEvent.Value)
+		supply.Minted.Add(supply.Minted, transferEvent.Value)
+		if scaled != nil {
+			c.mintedValue.Add(c.mintedValue, scaled)
+			supply.MintedValue.Add(supply.MintedValue, scaled)
+		}
+	}
+	if burn {
+		c.burns++
+		supply.Burns++
+		c.burned.Add(c.burned, transferEvent.Value)
+		supply.Burned.Add(supply.Burned, transferEvent.Value)
+		if scaled != nil {
+			c.burnedValue.Add(c.burnedValue, scaled)
+			supply.BurnedValue.Add(supply.BurnedValue, scaled)
+		}
+	}
+}
+
+// SupplyByToken — токены с минтами или сжиганиями, по убыванию числа таких переводов.
+func (c *transferCounter) SupplyByToken() []TokenSupply {
+	supplies := make([]TokenSupply, 0, len(c.supplies))
+	for _, supply := range c.supplies {
+		supplies = append(supplies, *supply)
+	}
+	sort.Slice(supplies, func(i, j int) bool {
+		a, b := supplies[i].Mints+supplies[i].Burns, supplies[j].Mints+supplies[j].Burns
+		if a != b {
+			return a > b
+		}
+		return bytes.Compare(supplies[i].Token.Bytes(), supplies[j].Token.Bytes()) < 0
+	})
+	return supplies
+}
+
+// writeSupply печатает сводку эмиссии: итог и строку на каждый токен с минтами или сжиганиями.
+// Сырой итог складывается по всем токенам без учёта decimals, поэтому осмыслен для одного
+// токена или вместе с -decimals; построчные суммы относятся к одному токену.
+func writeSupply(w io.Writer, stats ScanStats, tokens []TokenSupply, opts outputOptions) error {
+	net := new(big.Int).Sub(stats.Minted, stats.Burned)
+	line := fmt.Sprintf("minted %v raw in %d transfers, burned %v raw in %d transfers, net issuance %v raw",
+		stats.Minted, stats.Mints, stats.Burned, stats.Burns, net)
+	if stats.MintedValue != "" {
+		line += fmt.Sprintf(" (minted %v, burned %v, net %v)", stats.MintedValue, stats.BurnedValue, stats.NetValue)
+	}
+	if _, err := fmt.Fprintln(w, line); err != nil {
+		return err
+	}
+	if len(tokens) < 2 {
+		return nil
+	}
+
+	for _, t := range tokens {
+		line := fmt.Sprintf("  token %v: minted %v raw in %d transfers, burned %v raw in %d transfers, net %v raw",
+			opts.Tokens.Label(t.Token), t.Minted, t.Mints, t.Burned, t.Burns, new(big.Int).Sub(t.Minted, t.Burned))
+		if opts.Decimals {
+			netValue := new(big.Rat).Sub(t.MintedValue, t.BurnedValue)
+			line += fmt.Sprintf(" (minted %v, burned %v, net %v)", formatDecimal(t.MintedValue), formatDecimal(t.BurnedValue), formatDecimal(netValue))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
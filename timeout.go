@@ -0,0 +1,15 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// withRequestTimeout ограничивает один RPC-запрос -request-timeout. Контекст производный,
+// поэтому общий -timeout прогона, если он истекает раньше, срабатывает и для запроса.
+func withRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	tokensByAddresses = "addresses"
+	tokensByTransfers = "transfers"
+)
+
+// TokenMetric — активность контракта токена: сколько событий он выпустил
+// и сколько различных адресов в них участвовало.
+type TokenMetric struct {
+	Token     common.Address
+	Transfers int
+	Addresses int
+}
+
+// countTokenEvent засчитывает событие контракту, который его выпустил (vLog.Address).
+func (c *transferCounter) countTokenEvent(token common.Address) {
+	if c.opts.TopTokens {
+		c.tokenEvents[token]++
+	}
+}
+
+func (c *transferCounter) trackParticipant(token, address common.Address) {
+	if address == (common.Address{}) && !c.opts.CountZero {
+		return
+	}
+
+	participants, ok := c.tokenParticipants[token]
+	if !ok {
+		participants = make(map[common.Address]struct{})
+		c.tokenParticipants[token] = participants
+	}
+	participants[address] = struct{}{}
+}
+
+func (c *transferCounter) TokenMetrics() []TokenMetric {
+	tokens := make([]TokenMetric, 0, len(c.tokenParticipants))
+	for token, participants := range c.tokenParticipants {
+		tokens = append(tokens, TokenMetric{Token: token, Transfers: c.tokenEvents[token], Addresses: len(participants)})
+	}
+
+	// -top-tokens-by выбирает основной ключ, второй разрешает равенство, затем адрес.
+	primary := func(t TokenMetric) (int, int) { return t.Addresses, t.Transfers }
+	if c.opts.TopTokensBy == tokensByTransfers {
+		primary = func(t TokenMetric) (int, int) { return t.Transfers, t.Addresses }
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		a1, a2 := primary(tokens[i])
+		b1, b2 := primary(tokens[j])
+		if a1 != b1 {
+			return a1 > b1
+		}
+		if a2 != b2 {
+			return a2 > b2
+		}
+		return tokens[i].Token.Hex() < tokens[j].Token.Hex()
+	})
+
+	return tokens
+}
+
+func writeTokenMetrics(w io.Writer, tokens []TokenMetric, opts outputOptions) error {
+	if len(tokens) > topN {
+		tokens = tokens[:topN]
+	}
+
+	if opts.AddressesOnly {
+		for _, t := range tokens {
+			if _, err := fmt.Fprintln(w, t.Token.Hex()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Token", "Transfers", "Unique addresses"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if err := writeMarkdownRow(w, []string{opts.Tokens.Label(t.Token), fmt.Sprint(t.Transfers), fmt.Sprint(t.Addresses)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, t := range tokens {
+		if _, err := fmt.Fprintf(w, "token %v emitted %v transfers between %v unique addresses\n", opts.Tokens.Label(t.Token), t.Transfers, t.Addresses); err != nil {
+			return err
+		}
+	}
+	return nil
+}
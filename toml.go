@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML разбирает подмножество TOML, которого хватает конфигу: пары key = value, таблицы
+// вроде [chains.optimism], строки в двойных и одинарных кавычках, целые и дробные числа, true/false
+// и однострочные массивы. Даты, встроенные таблицы и многострочные значения не поддерживаются.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	table := root
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripTOMLComment(line))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") || !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unsupported table header %q", i+1, line)
+			}
+			var err error
+			table, err = tomlTable(root, line[1:len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			continue
+		}
+
+		rawKey, rawValue, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		path := strings.Split(rawKey, ".")
+		parent, err := tomlTable(table, strings.Join(path[:len(path)-1], "."))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		key := tomlKey(path[len(path)-1])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: empty key", i+1)
+		}
+		if _, exists := parent[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", i+1, key)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(rawValue))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", i+1, key, err)
+		}
+		parent[key] = value
+	}
+	return root, nil
+}
+
+// tomlTable возвращает (создавая при необходимости) вложенную таблицу по пути через точку.
+func tomlTable(root map[string]interface{}, path string) (map[string]interface{}, error) {
+	table := root
+	if strings.TrimSpace(path) == "" {
+		return table, nil
+	}
+	for _, part := range strings.Split(path, ".") {
+		key := tomlKey(part)
+		if key == "" {
+			return nil, fmt.Errorf("empty key in %q", path)
+		}
+		switch next := table[key].(type) {
+		case map[string]interface{}:
+			table = next
+		case nil:
+			created := make(map[string]interface{})
+			table[key] = created
+			table = created
+		default:
+			return nil, fmt.Errorf("%q is already a value, not a table", key)
+		}
+	}
+	return table, nil
+}
+
+func tomlKey(raw string) string {
+	return strings.Trim(strings.TrimSpace(raw), `"'`)
+}
+
+func parseTOMLValue(raw string) (interface{}, error) {
+	switch {
+	case raw == "":
+		return nil, errors.New("missing value")
+	case strings.HasPrefix(raw, `"""`) || strings.HasPrefix(raw, "'''"):
+		return nil, errors.New("multi-line strings are not supported")
+	case raw[0] == '"':
+		return strconv.Unquote(raw)
+	case raw[0] == '\'':
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return nil, fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw[0] == '[':
+		if !strings.HasSuffix(raw, "]") {
+			return nil, errors.New("arrays must fit on one line")
+		}
+		var values []interface{}
+		for _, element := range splitTOMLArray(raw[1 : len(raw)-1]) {
+			value, err := parseTOMLValue(element)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+	}
+
+	number := strings.ReplaceAll(raw, "_", "")
+	if value, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return value, nil
+	}
+	if value, err := strconv.ParseFloat(number, 64); err == nil {
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported value %s", raw)
+}
+
+// splitTOMLArray делит содержимое массива по запятым вне кавычек; висячая запятая допустима.
+func splitTOMLArray(raw string) []string {
+	var elements []string
+	start := 0
+	var quote byte
+	for i := 0; i < len(raw); i++ {
+		switch c := raw[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			elements = append(elements, strings.TrimSpace(raw[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(raw[start:]); last != "" {
+		elements = append(elements, last)
+	}
+	return elements
+}
+
+// stripTOMLComment отрезает комментарий с # вне кавычек.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,27 @@
+package main
+
+import "github.com/ethereum/go-ethereum/common"
+
+const sortTxs = "txs"
+
+// trackTx запоминает транзакцию перевода: своп через DEX даёт несколько Transfer в одной
+// транзакции, и count считает их все, а txs — одну.
+func (c *transferCounter) trackTx(address common.Address, txHash common.Hash) {
+	txs, ok := c.txs[address]
+	if !ok {
+		txs = make(map[common.Hash]struct{})
+		c.txs[address] = txs
+	}
+	txs[txHash] = struct{}{}
+}
+
+// fillTxCounts проставляет число различных транзакций и среднее число переводов на транзакцию.
+func (c *transferCounter) fillTxCounts(metrics []Metric) {
+	for i := range metrics {
+		txs := len(c.txs[metrics[i].Address])
+		metrics[i].Txs = txs
+		if txs > 0 {
+			metrics[i].PerTx = float64(metrics[i].Count) / float64(txs)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	sortUSD = "usd"
+
+	priceSourceChainlink = "chainlink"
+	priceSourceCoinGecko = "coingecko"
+
+	defaultCoinGeckoURL = "https://api.coingecko.com/api/v3"
+)
+
+// latestRoundData()
+var latestRoundDataSelector = []byte{0xfe, 0xaf, 0x96, 0x8c}
+
+// coinGeckoPlatforms — идентификаторы сетей -chain в API CoinGecko.
+var coinGeckoPlatforms = map[string]string{
+	"ethereum": "ethereum",
+	"polygon":  "polygon-pos",
+	"bsc":      "binance-smart-chain",
+	"arbitrum": "arbitrum-one",
+	"base":     "base",
+}
+
+// priceSource — источник цены токена в USD. Реализации: on-chain фиды Chainlink и API CoinGecko;
+// новый источник достаточно подключить в newPriceSource.
+type priceSource interface {
+	// Price — цена одной единицы токена (с учётом decimals) на момент блока block со временем at;
+	// nil без ошибки — источник не знает этот токен.
+	Price(ctx context.Context, token common.Address, block uint64, at time.Time) (*big.Rat, error)
+}
+
+func validatePriceSource(source string) error {
+	switch source {
+	case "", priceSourceChainlink, priceSourceCoinGecko:
+		return nil
+	}
+	return fmt.Errorf("invalid -price-source %q: expected chainlink or coingecko", source)
+}
+
+// newPriceSource строит источник -price-source: для chainlink feedsPath — JSON "токен: фид",
+// для coingecko platform — сеть в терминах CoinGecko.
+func newPriceSource(source string, client *ethclient.Client, feedsPath, coinGeckoURL, platform string) (priceSource, error) {
+	switch source {
+	case priceSourceChainlink:
+		if client == nil {
+			return nil, errors.New("-price-source chainlink reads feeds over RPC and cannot be combined with -logs-file")
+		}
+		if feedsPath == "" {
+			return nil, errors.New("-price-source chainlink needs -price-feeds")
+		}
+		feeds, err := loadPriceFeeds(feedsPath)
+		if err != nil {
+			return nil, err
+		}
+		return &chainlinkSource{client: client, feeds: feeds, decimals: make(map[common.Address]uint8)}, nil
+
+	case priceSourceCoinGecko:
+		if platform == "" {
+			return nil, errors.New("-price-source coingecko needs -coingecko-platform for this chain")
+		}
+		return &coinGeckoSource{
+			baseURL:  strings.TrimRight(coinGeckoURL, "/"),
+			platform: platform,
+			apiKey:   os.Getenv("COINGECKO_API_KEY"),
+			client:   &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	}
+	return nil, nil
+}
+
+// loadPriceFeeds читает JSON-объект {"адрес токена": "адрес фида Chainlink TOKEN/USD"}.
+func loadPriceFeeds(path string) (map[common.Address]common.Address, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price feeds: %w", err)
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse price feeds %s: %w", path, err)
+	}
+
+	feeds := make(map[common.Address]common.Address, len(raw))
+	for token, feed := range raw {
+		if !common.IsHexAddress(token) || !common.IsHexAddress(feed) {
+			return nil, fmt.Errorf("invalid price feed entry %q: %q in %s", token, feed, path)
+		}
+		feeds[common.HexToAddress(token)] = common.HexToAddress(feed)
+	}
+	return feeds, nil
+}
+
+// chainlinkSource читает latestRoundData() фида на нужном блоке; для старых блоков нужен архивный узел.
+type chainlinkSource struct {
+	client *ethclient.Client
+	feeds  map[common.Address]common.Address
+
+	mu       sync.Mutex
+	decimals map[common.Address]uint8
+}
+
+func (s *chainlinkSource) Price(ctx context.Context, token common.Address, block uint64, _ time.Time) (*big.Rat, error) {
+	feed, ok := s.feeds[token]
+	if !ok {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	decimals, known := s.decimals[feed]
+	s.mu.Unlock()
+	if !known {
+		var err error
+		decimals, err = fetchDecimals(ctx, s.client, feed)
+		if err != nil {
+			return nil, fmt.Errorf("price feed %s: %w", feed.Hex(), err)
+		}
+		s.mu.Lock()
+		s.decimals[feed] = decimals
+		s.mu.Unlock()
+	}
+
+	output, err := s.client.CallContract(ctx, ethereum.CallMsg{To: &feed, Data: latestRoundDataSelector}, new(big.Int).SetUint64(block))
+	if err != nil {
+		return nil, fmt.Errorf("failed to call latestRoundData() on %s: %w", feed.Hex(), err)
+	}
+	// (roundId, answer, startedAt, updatedAt, answeredInRound); answer — int256 во втором слове.
+	if len(output) != 5*32 {
+		return nil, fmt.Errorf("unexpected latestRoundData() response from %s: %d bytes", feed.Hex(), len(output))
+	}
+	answer := math.S256(new(big.Int).SetBytes(output[32:64]))
+	if answer.Sign() <= 0 {
+		return nil, fmt.Errorf("price feed %s returned non-positive answer %v", feed.Hex(), answer)
+	}
+	return scaleValue(answer, decimals), nil
+}
+
+// coinGeckoSource берёт цену из market_chart/range: ближайшую к времени блока точку в окне ±1 час.
+type coinGeckoSource struct {
+	baseURL  string
+	platform string
+	apiKey   string
+	client   *http.Client
+}
+
+func (s *coinGeckoSource) Price(ctx context.Context, token common.Address, _ uint64, at time.Time) (*big.Rat, error) {
+	query := url.Values{
+		"vs_currency": {"usd"},
+		"from":        {fmt.Sprint(at.Add(-time.Hour).Unix())},
+		"to":          {fmt.Sprint(at.Add(time.Hour).Unix())},
+	}
+	endpoint := fmt.Sprintf("%s/coins/%s/contract/%s/market_chart/range?%s", s.baseURL, s.platform, strings.ToLower(token.Hex()), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.apiKey != "" {
+		req.Header.Set("x-cg-demo-api-key", s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CoinGecko price of %s: %w", token.Hex(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch CoinGecko price of %s: %s", token.Hex(), resp.Status)
+	}
+
+	var chart struct {
+		Prices [][2]float64 `json:"prices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&chart); err != nil {
+		return nil, fmt.Errorf("failed to decode CoinGecko price of %s: %w", token.Hex(), err)
+	}
+	if len(chart.Prices) == 0 {
+		return nil, nil
+	}
+
+	target := float64(at.UnixMilli())
+	distance := func(point [2]float64) float64 {
+		if point[0] > target {
+			return point[0] - target
+		}
+		return target - point[0]
+	}
+	best := chart.Prices[0]
+	for _, point := range chart.Prices[1:] {
+		if distance(point) < distance(best) {
+			best = point
+		}
+	}
+	return new(big.Rat).SetFloat64(best[1]), nil
+}
+
+type priceKey struct {
+	token common.Address
+	slot  uint64
+}
+
+// priceCache запоминает цену токена на интервал в interval блоков: все переводы интервала
+// пересчитываются по цене его первого блока. Неизвестная цена тоже кэшируется.
+type priceCache struct {
+	source   priceSource
+	interval uint64
+	headers  *headerCache
+
+	mu     sync.Mutex
+	prices map[priceKey]*big.Rat
+	warned map[common.Address]bool
+}
+
+func newPriceCache(source priceSource, interval uint64, headers *headerCache) *priceCache {
+	if interval == 0 {
+		interval = 1
+	}
+	return &priceCache{
+		source:   source,
+		interval: interval,
+		headers:  headers,
+		prices:   make(map[priceKey]*big.Rat),
+		warned:   make(map[common.Address]bool),
+	}
+}
+
+func (c *priceCache) Price(ctx context.Context, token common.Address, block uint64) *big.Rat {
+	key := priceKey{token: token, slot: block / c.interval * c.interval}
+	c.mu.Lock()
+	price, ok := c.prices[key]
+	c.mu.Unlock()
+	if ok {
+		return price
+	}
+
+	price, err := c.fetch(ctx, token, key.slot)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		// Сбой не кэшируется, но предупреждение печатается один раз на токен.
+		if !c.warned[token] {
+			logger.Warn("USD price is unavailable", "token", token.Hex(), "err", err)
+			c.warned[token] = true
+		}
+		return nil
+	}
+	c.prices[key] = price
+	return price
+}
+
+func (c *priceCache) fetch(ctx context.Context, token common.Address, block uint64) (*big.Rat, error) {
+	header, err := c.headers.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve header of block %d: %w", block, err)
+	}
+	return c.source.Price(ctx, token, block, time.Unix(int64(header.Time), 0).UTC())
+}
+
+// countUSD переводит значение перевода в USD по цене токена на его блоке; переводы токенов
+// без цены в USD-сумму не попадают.
+func (c *transferCounter) countUSD(ctx context.Context, token, from, to common.Address, block uint64, scaled *big.Rat) {
+	price := c.prices.Price(ctx, token, block)
+	if price == nil {
+		return
+	}
+
+	usd := new(big.Rat).Mul(scaled, price)
+	if c.opts.Direction != directionIn {
+		addRat(c.usdValues, from, usd)
+	}
+	if c.opts.Direction != directionOut {
+		addRat(c.usdValues, to, usd)
+	}
+}
+
+func (c *transferCounter) fillUSD(metrics []Metric) {
+	for i := range metrics {
+		metrics[i].USD = ratOrZero(c.usdValues[metrics[i].Address])
+	}
+}
+
+func formatUSD(value *big.Rat) string {
+	return ratOrZero(value).FloatString(2)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// decimals()
+var decimalsSelector = []byte{0x31, 0x3c, 0xe5, 0x67}
+
+type decimalsCache struct {
+	client *ethclient.Client
+	stats  *Stats
+
+	mu     sync.Mutex
+	known  map[common.Address]uint8
+	failed map[common.Address]error
+}
+
+func newDecimalsCache(client *ethclient.Client, registry map[common.Address]tokenInfo, stats *Stats) *decimalsCache {
+	c := &decimalsCache{
+		client: client,
+		stats:  stats,
+		known:  make(map[common.Address]uint8, len(registry)),
+		failed: make(map[common.Address]error),
+	}
+	for token, info := range registry {
+		c.known[token] = info.Decimals
+	}
+	return c
+}
+
+func (c *decimalsCache) Decimals(ctx context.Context, token common.Address) (uint8, error) {
+	c.mu.Lock()
+	decimals, known := c.known[token]
+	err, failed := c.failed[token]
+	c.mu.Unlock()
+	if known || failed {
+		c.stats.IncCacheHit()
+	}
+	if known {
+		return decimals, nil
+	}
+	if failed {
+		return 0, err
+	}
+
+	if c.client == nil {
+		err = fmt.Errorf("decimals of %s are not in the token registry and there is no RPC client", token.Hex())
+	} else {
+		decimals, err = fetchDecimals(ctx, c.client, token)
+		if err != nil {
+			c.stats.IncRPCError()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.failed[token] = err
+		return 0, err
+	}
+	c.known[token] = decimals
+	return decimals, nil
+}
+
+func fetchDecimals(ctx context.Context, client *ethclient.Client, token common.Address) (uint8, error) {
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: decimalsSelector}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call decimals() on %s: %w", token.Hex(), err)
+	}
+	if len(output) != 32 {
+		return 0, fmt.Errorf("unexpected decimals() response from %s: %d bytes", token.Hex(), len(output))
+	}
+
+	decimals := new(big.Int).SetBytes(output)
+	if !decimals.IsUint64() || decimals.Uint64() > 77 {
+		return 0, fmt.Errorf("unexpected decimals() value from %s: %s", token.Hex(), decimals)
+	}
+	return uint8(decimals.Uint64()), nil
+}
+
+// scaleValue переводит сырое значение в единицы токена: value / 10^decimals.
+func scaleValue(value *big.Int, decimals uint8) *big.Rat {
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Rat).SetFrac(value, denominator)
+}
+
+func formatDecimal(value *big.Rat) string {
+	if value == nil {
+		return "0"
+	}
+
+	formatted := value.FloatString(18)
+	formatted = strings.TrimRight(formatted, "0")
+	return strings.TrimSuffix(formatted, ".")
+}
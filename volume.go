@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"getBlock/metric"
+)
+
+const sortVolume = "volume"
+
+// countVolume суммирует нормализованные по decimals значения отдельно для отправленного
+// и полученного; роли учитываются с оглядкой на -direction.
+func (c *transferCounter) countVolume(transferEvent metric.TransferEvents, scaled *big.Rat) {
+	if c.opts.Direction != directionIn {
+		addRat(c.sentVolume, transferEvent.From, scaled)
+	}
+	if c.opts.Direction != directionOut {
+		addRat(c.receivedVolume, transferEvent.To, scaled)
+	}
+}
+
+func addRat(sums map[common.Address]*big.Rat, address common.Address, value *big.Rat) {
+	if sums[address] == nil {
+		sums[address] = new(big.Rat)
+	}
+	sums[address].Add(sums[address], value)
+}
+
+func (c *transferCounter) fillVolumes(metrics []Metric) {
+	for i := range metrics {
+		metrics[i].Sent = ratOrZero(c.sentVolume[metrics[i].Address])
+		metrics[i].Received = ratOrZero(c.receivedVolume[metrics[i].Address])
+	}
+}
+
+func ratOrZero(value *big.Rat) *big.Rat {
+	if value == nil {
+		return new(big.Rat)
+	}
+	return value
+}
+
+// volume — отправленное и полученное вместе, в единицах токенов.
+func volume(m Metric) *big.Rat {
+	return new(big.Rat).Add(ratOrZero(m.Sent), ratOrZero(m.Received))
+}
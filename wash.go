@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"getBlock/metric"
+)
+
+const (
+	// defaultWashThreshold — доля переводов в петлях, начиная с которой адрес попадает в -wash.
+	defaultWashThreshold = 0.5
+	// washMinTransfers — у адреса с парой переводов любая доля случайна, такие не показываются.
+	washMinTransfers = 4
+)
+
+// flowKey — направленный поток токена от одного адреса к другому.
+type flowKey struct {
+	Token, From, To common.Address
+}
+
+// washSuspect — адрес, чьи переводы в основном уходят самому себе или возвращаются обратно.
+type washSuspect struct {
+	Address common.Address
+	// Transfers — все переводы адреса в обе стороны; перевод самому себе считается один раз.
+	Transfers int
+	// Self — переводы самому себе; Looped — переводы в круговых парах A→B и B→A одного токена.
+	Self, Looped int
+	// Partner — контрагент с наибольшим числом круговых переводов.
+	Partner common.Address
+	Score   float64
+}
+
+// trackFlow запоминает перевод для -wash и -graph; переводы с нулевого и на нулевой адрес (минт и сжигание)
+// петлями не бывают и учитываются только с -count-zero.
+func (c *transferCounter) trackFlow(token common.Address, transferEvent metric.TransferEvents) {
+	if !c.opts.CountZero && (transferEvent.From == (common.Address{}) || transferEvent.To == (common.Address{})) {
+		return
+	}
+	c.flows[flowKey{token, transferEvent.From, transferEvent.To}]++
+}
+
+// WashSuspects оценивает каждый адрес долей переводов, которые не меняют владельца по сути:
+// переводы самому себе и встречные пары A→B→A (из n переводов A→B и m обратно в петле
+// min(n, m) пар, то есть 2·min(n, m) переводов). Возвращает адреса с оценкой не ниже threshold.
+func (c *transferCounter) WashSuspects(threshold float64) []washSuspect {
+	suspects := make(map[common.Address]*washSuspect)
+	suspect := func(address common.Address) *washSuspect {
+		s, ok := suspects[address]
+		if !ok {
+			s = &washSuspect{Address: address}
+			suspects[address] = s
+		}
+		return s
+	}
+
+	partners := make(map[common.Address]map[common.Address]int)
+	for flow, n := range c.flows {
+		if flow.From == flow.To {
+			s := suspect(flow.From)
+			s.Transfers += n
+			s.Self += n
+			continue
+		}
+		suspect(flow.From).Transfers += n
+		suspect(flow.To).Transfers += n
+
+		back := c.flows[flowKey{flow.Token, flow.To, flow.From}]
+		if back == 0 {
+			continue
+		}
+		// Каждая петля видна с обеих сторон: здесь засчитываются переводы одного направления.
+		looped := n
+		if back < looped {
+			looped = back
+		}
+		for _, address := range []common.Address{flow.From, flow.To} {
+			other := flow.To
+			if address == flow.To {
+				other = flow.From
+			}
+			suspect(address).Looped += looped
+			if partners[address] == nil {
+				partners[address] = make(map[common.Address]int)
+			}
+			partners[address][other] += looped
+		}
+	}
+
+	var out []washSuspect
+	for address, s := range suspects {
+		if s.Transfers < washMinTransfers {
+			continue
+		}
+		s.Score = float64(s.Self+s.Looped) / float64(s.Transfers)
+		if s.Score < threshold {
+			continue
+		}
+		best := 0
+		for partner, n := range partners[address] {
+			if n > best || n == best && partner.Hex() < s.Partner.Hex() {
+				s.Partner, best = partner, n
+			}
+		}
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		if out[i].Transfers != out[j].Transfers {
+			return out[i].Transfers > out[j].Transfers
+		}
+		return out[i].Address.Hex() < out[j].Address.Hex()
+	})
+	return out
+}
+
+// writeWash печатает topN подозрительных адресов после рейтинга.
+func writeWash(w io.Writer, suspects []washSuspect, threshold float64, opts outputOptions) error {
+	if len(suspects) > topN {
+		suspects = suspects[:topN]
+	}
+
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Address", "Score", "Transfers", "Self", "Looped", "Main partner"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, s := range suspects {
+			partner := ""
+			if s.Looped > 0 {
+				partner = s.Partner.Hex()
+			}
+			if err := writeMarkdownRow(w, []string{s.Address.Hex(), fmt.Sprintf("%.2f", s.Score), fmt.Sprint(s.Transfers), fmt.Sprint(s.Self), fmt.Sprint(s.Looped), partner}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(suspects) == 0 {
+		_, err := fmt.Fprintf(w, "no wash trading suspects with a score of at least %.2f\n", threshold)
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "wash trading suspects (score of at least %.2f):\n", threshold); err != nil {
+		return err
+	}
+	for _, s := range suspects {
+		line := fmt.Sprintf("  %v: score %.2f, %d self-transfers and %d looped of %d transfers", s.Address.Hex(), s.Score, s.Self, s.Looped, s.Transfers)
+		if s.Looped > 0 {
+			line += fmt.Sprintf(", mostly with %v", s.Partner.Hex())
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func isWebSocketURL(rawURL string) (bool, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false, fmt.Errorf("invalid RPC URL: %w", err)
+	}
+
+	switch parsed.Scheme {
+	case "ws", "wss":
+		return true, nil
+	case "http", "https":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported RPC URL scheme %q", parsed.Scheme)
+	}
+}
+
+// watch подписывается на новые Transfer логи и печатает рейтинг после каждых every блоков
+// с переводами; блоки без переводов в подписку не попадают и не считаются. С dash вместо печати
+// рейтинга раз в dashboardRefresh перерисовывается экран -tui.
+func watch(ctx context.Context, client ChainReader, counter *transferCounter, every int, report func([]Metric) error, dash *dashboard) error {
+	query := ethereum.FilterQuery{
+		Addresses: counter.opts.Contracts,
+		Topics:    counter.opts.transferTopics(),
+	}
+
+	logsCh := make(chan types.Log)
+	sub, err := client.SubscribeFilterLogs(ctx, query, logsCh)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to transfer logs: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Нулевой канал без -tui никогда не срабатывает.
+	var refresh <-chan time.Time
+	if dash != nil {
+		ticker := time.NewTicker(dashboardRefresh)
+		defer ticker.Stop()
+		refresh = ticker.C
+		dash.Draw(counter)
+	}
+
+	var lastBlock uint64
+	completed := 0
+	for {
+		select {
+		case <-refresh:
+			dash.Draw(counter)
+		case err := <-sub.Err():
+			return fmt.Errorf("transfer logs subscription failed: %w", err)
+		case vLog := <-logsCh:
+			if vLog.Removed {
+				// Реорганизация: узел повторно присылает снятые логи с Removed, а затем логи новой ветки.
+				if err := counter.Remove(ctx, vLog); err != nil {
+					return err
+				}
+				continue
+			}
+			if lastBlock != 0 && vLog.BlockNumber != lastBlock {
+				completed++
+			}
+			if completed >= every && dash == nil {
+				completed = 0
+				metrics, err := counter.Metrics()
+				if err == nil {
+					logger.Info("ranking updated", "block", lastBlock)
+					if err := report(metrics); err != nil {
+						return err
+					}
+				}
+			}
+			lastBlock = vLog.BlockNumber
+			if lastBlock > counter.toBlock {
+				counter.SetRange(counter.fromBlock, lastBlock)
+			}
+			if err := counter.Add(ctx, vLog); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"getBlock/metric"
+)
+
+// whaleTransfer — отдельный перевод не меньше -min-value в единицах токена.
+type whaleTransfer struct {
+	Block    uint64
+	TxHash   common.Hash
+	Index    uint
+	Token    common.Address
+	From     common.Address
+	To       common.Address
+	RawValue *big.Int
+	Value    *big.Rat
+}
+
+func parseMinValue(raw string) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(raw)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid -min-value %q: expected a positive amount in token units", raw)
+	}
+	return value, nil
+}
+
+// recordWhale запоминает перевод, если его значение с учётом decimals не меньше порога.
+// Перевод без известных decimals пропускается: порог в единицах токена к нему не применим.
+func (c *transferCounter) recordWhale(vLog types.Log, transferEvent metric.TransferEvents, scaled *big.Rat) {
+	if scaled == nil || scaled.Cmp(c.opts.MinValue) < 0 {
+		return
+	}
+	c.whales = append(c.whales, whaleTransfer{
+		Block:    vLog.BlockNumber,
+		TxHash:   vLog.TxHash,
+		Index:    vLog.Index,
+		Token:    vLog.Address,
+		From:     transferEvent.From,
+		To:       transferEvent.To,
+		RawValue: transferEvent.Value,
+		Value:    scaled,
+	})
+}
+
+// Whales возвращает крупные переводы от большего значения к меньшему, при равенстве — по блоку.
+func (c *transferCounter) Whales() []whaleTransfer {
+	whales := append([]whaleTransfer(nil), c.whales...)
+	sort.SliceStable(whales, func(i, j int) bool {
+		if cmp := whales[i].Value.Cmp(whales[j].Value); cmp != 0 {
+			return cmp > 0
+		}
+		if whales[i].Block != whales[j].Block {
+			return whales[i].Block < whales[j].Block
+		}
+		return whales[i].Index < whales[j].Index
+	})
+	return whales
+}
+
+func writeWhales(w io.Writer, whales []whaleTransfer, minValue *big.Rat, opts outputOptions) error {
+	if opts.Format == formatMarkdown {
+		if err := writeMarkdownRow(w, []string{"Block", "Tx", "Token", "From", "To", "Value"}); err != nil {
+			return err
+		}
+		if err := writeMarkdownRow(w, []string{"---", "---", "---", "---", "---", "---"}); err != nil {
+			return err
+		}
+		for _, t := range whales {
+			if err := writeMarkdownRow(w, []string{fmt.Sprint(t.Block), t.TxHash.Hex(), opts.Tokens.Label(t.Token), t.From.Hex(), t.To.Hex(), formatDecimal(t.Value)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "%d whale transfers of at least %v:\n", len(whales), formatDecimal(minValue)); err != nil {
+		return err
+	}
+	for _, t := range whales {
+		if _, err := fmt.Fprintf(w, "block %v tx %v: %v token %v from %v to %v\n",
+			t.Block, t.TxHash.Hex(), formatDecimal(t.Value), opts.Tokens.Label(t.Token), t.From.Hex(), t.To.Hex()); err != nil {
+			return err
+		}
+	}
+	return nil
+}